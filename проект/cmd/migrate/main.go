@@ -1,25 +1,153 @@
-package main
-
-import (
-	"log"
-
-	"github.com/opr1234/calculator/internal/storage"
-)
-
-func main() {
-	store, err := storage.New("calc.db")
-	if err != nil {
-		log.Fatalf("Storage initialization failed: %v", err)
-	}
-
-	if err := store.Migrate(); err != nil {
-		log.Fatalf("Migrations failed: %v", err)
-	}
-
-	log.Println("✅ Database migrations completed successfully")
-
-	if err := store.VerifySchema(); err != nil {
-		log.Fatalf("Schema verification failed: %v", err)
-	}
-	log.Println("✅ Database schema is valid")
-}
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/opr1234/calculator/internal/storage"
+)
+
+const usage = `Usage: migrate [-dir DIR] COMMAND
+
+Commands:
+  up            apply every pending migration and verify the schema
+                (the default)
+  down [N]      roll back the last N migrations, 1 by default
+  status        list the migrations and whether they are applied
+  redo          roll back the last migration and apply it again
+  to VERSION    migrate up or down to VERSION, 0 for an empty database
+  verify        compare the schema with the migrations, exit 1 on drift
+  create NAME   add up and down scripts of a new migration to DIR
+`
+
+func main() {
+	dir := flag.String("dir", "internal/storage/migrations", "migrations source directory, for create")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command, args := "up", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	if command == "create" {
+		if len(args) != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		paths, err := storage.CreateMigration(*dir, args[0])
+		if err != nil {
+			log.Fatalf("Create migration failed: %v", err)
+		}
+		for _, p := range paths {
+			log.Printf("Created %s", p)
+		}
+		return
+	}
+
+	store, err := storage.New(storage.DSNFromEnv())
+	if err != nil {
+		log.Fatalf("Storage initialization failed: %v", err)
+	}
+	defer store.Close()
+
+	switch command {
+	case "up":
+		if err := store.Migrate(); err != nil {
+			log.Fatalf("Migrations failed: %v", err)
+		}
+		log.Println("✅ Database migrations completed successfully")
+		verify(store)
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			n = number(args[0])
+		}
+		if err := store.MigrateDown(n); err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		log.Printf("✅ Rolled back %d migration(s)", n)
+	case "redo":
+		if err := store.RedoMigration(); err != nil {
+			log.Fatalf("Redo failed: %v", err)
+		}
+		log.Println("✅ Last migration redone")
+	case "to":
+		if len(args) != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		version := number(args[0])
+		if err := store.MigrateTo(version); err != nil {
+			log.Fatalf("Migrations failed: %v", err)
+		}
+		log.Printf("✅ Database migrated to version %d", version)
+	case "verify":
+		verify(store)
+	case "status":
+		statuses, err := store.MigrationStatuses()
+		if err != nil {
+			log.Fatalf("Migration status failed: %v", err)
+		}
+		printStatuses(statuses)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// verify prints the drift of the schema from the migrations and exits
+// with 1 if there is any.
+func verify(store *storage.Storage) {
+	err := store.VerifySchema()
+	var drift *storage.SchemaDriftError
+	if errors.As(err, &drift) {
+		fmt.Println("Schema drift:")
+		for _, d := range drift.Drift {
+			fmt.Println("  " + d.String())
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("Schema verification failed: %v", err)
+	}
+	log.Println("✅ Database schema is valid")
+}
+
+// number parses a count or version argument.
+func number(arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid number %q", arg)
+	}
+	return n
+}
+
+func printStatuses(statuses []storage.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		switch {
+		case s.Missing:
+			state = "missing"
+		case s.Modified:
+			state = "modified"
+		case s.Applied:
+			state = "applied"
+		}
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
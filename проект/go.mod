@@ -1,21 +1,25 @@
-module github.com/yourusername/calculator
-
-go 1.21
-
-require (
-    github.com/golang-jwt/jwt/v5 v5.2.0
-    github.com/gorilla/mux v1.8.1
-    github.com/joho/godotenv v1.5.1
-    github.com/lib/pq v1.10.9
-    golang.org/x/crypto v0.14.0
-    google.golang.org/grpc v1.59.0
-    google.golang.org/protobuf v1.31.0
-)
-
-require (
-    github.com/golang/protobuf v1.5.3 // indirect
-    golang.org/x/net v0.17.0 // indirect
-    golang.org/x/sys v0.13.0 // indirect
-    golang.org/x/text v0.13.0 // indirect
-    google.golang.org/genproto/googleapis/rpc v0.0.0-20231030173426-d783a09b4405 // indirect
-)
\ No newline at end of file
+module github.com/yourusername/calculator
+
+go 1.21
+
+require (
+	github.com/fergusstrange/embedded-postgres v1.34.0
+	github.com/golang-jwt/jwt/v5 v5.2.0
+	github.com/gorilla/mux v1.8.1
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.52
+	github.com/tetratelabs/wazero v1.5.0
+	golang.org/x/crypto v0.14.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231030173426-d783a09b4405
+	google.golang.org/grpc v1.59.0
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+)
@@ -0,0 +1,132 @@
+// Package calculator is the service's view of the public evaluator in
+// pkg/calculator. It only re-exports what the server and the agent use, so
+// the service keeps one import path while the engine lives in the
+// supported package.
+package calculator
+
+import (
+	"github.com/opr1234/calculator/pkg/calculator"
+)
+
+type (
+	Evaluator   = calculator.Evaluator
+	Options     = calculator.Options
+	FunctionDef = calculator.FunctionDef
+	WasmLimits  = calculator.WasmLimits
+	Dialect     = calculator.Dialect
+	Notation    = calculator.Notation
+	Format      = calculator.Format
+	Language    = calculator.Language
+	Error       = calculator.Error
+	Code        = calculator.Code
+
+	Value       = calculator.Value
+	Number      = calculator.Number
+	Integer     = calculator.Integer
+	Complex     = calculator.Complex
+	Interval    = calculator.Interval
+	Uncertain   = calculator.Uncertain
+	List        = calculator.List
+	IntegerList = calculator.IntegerList
+	Matrix      = calculator.Matrix
+	DateTime    = calculator.DateTime
+	Duration    = calculator.Duration
+)
+
+const (
+	NotationInfix  = calculator.NotationInfix
+	NotationRPN    = calculator.NotationRPN
+	NotationPrefix = calculator.NotationPrefix
+
+	FormatLaTeX  = calculator.FormatLaTeX
+	FormatMathML = calculator.FormatMathML
+
+	LanguageEnglish = calculator.LanguageEnglish
+	LanguageRussian = calculator.LanguageRussian
+
+	CodeSyntax            = calculator.CodeSyntax
+	CodeUnknownIdentifier = calculator.CodeUnknownIdentifier
+	CodeUnknownFunction   = calculator.CodeUnknownFunction
+	CodeInvalidArgument   = calculator.CodeInvalidArgument
+	CodeDivisionByZero    = calculator.CodeDivisionByZero
+	CodeDomain            = calculator.CodeDomain
+	CodeOverflow          = calculator.CodeOverflow
+	CodeTimeout           = calculator.CodeTimeout
+	CodeLimitExceeded     = calculator.CodeLimitExceeded
+	CodeUnsupported       = calculator.CodeUnsupported
+	CodeInvalidDefinition = calculator.CodeInvalidDefinition
+	CodeInternal          = calculator.CodeInternal
+)
+
+var (
+	ErrUnknownDialect  = calculator.ErrUnknownDialect
+	ErrUnknownNotation = calculator.ErrUnknownNotation
+	ErrUnknownFormat   = calculator.ErrUnknownFormat
+	ErrUnknownTimezone = calculator.ErrUnknownTimezone
+	ErrUnknownLanguage = calculator.ErrUnknownLanguage
+
+	ErrInvalidExpression = calculator.ErrInvalidExpression
+
+	Standard          = calculator.Standard
+	DefaultWasmLimits = calculator.DefaultWasmLimits
+)
+
+func NewEvaluator(opts ...calculator.Option) *Evaluator {
+	return calculator.NewEvaluator(opts...)
+}
+
+func Classify(err error) *Error {
+	return calculator.Classify(err)
+}
+
+func NewError(code Code, message string) *Error {
+	return calculator.NewError(code, message)
+}
+
+func LookupDialect(name string) (*Dialect, error) {
+	return calculator.LookupDialect(name)
+}
+
+func Standardize(expr, dialect string) (string, error) {
+	return calculator.Standardize(expr, dialect)
+}
+
+func Convert(expr string, from, to Notation) (string, error) {
+	return calculator.Convert(expr, from, to)
+}
+
+func Render(expr string, format Format) (string, error) {
+	return calculator.Render(expr, format)
+}
+
+func RenderFunction(def FunctionDef, format Format) (string, error) {
+	return calculator.RenderFunction(def, format)
+}
+
+func ParseFunction(definition string) (FunctionDef, error) {
+	return calculator.ParseFunction(definition)
+}
+
+func CalledFunctions(expr string) ([]string, error) {
+	return calculator.CalledFunctions(expr)
+}
+
+func CheckCycles(defs []FunctionDef) error {
+	return calculator.CheckCycles(defs)
+}
+
+func Translate(text string) (string, error) {
+	return calculator.Translate(text)
+}
+
+func SpellOut(v Value, lang Language) (string, error) {
+	return calculator.SpellOut(v, lang)
+}
+
+func SplitSeries(expr string, chunk int64) ([]string, string, bool) {
+	return calculator.SplitSeries(expr, chunk)
+}
+
+func Discontinuities(ys []float64) []int {
+	return calculator.Discontinuities(ys)
+}
@@ -1,41 +1,190 @@
-package models
-
-import (
-	"time"
-)
-
-type User struct {
-	ID           int       `json:"id" db:"id"`
-	Login        string    `json:"login" db:"login"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-}
-
-type Expression struct {
-	ID         int64     `json:"id" db:"id"`
-	UserID     int       `json:"user_id" db:"user_id"`
-	Expression string    `json:"expression" db:"expression"`
-	Status     string    `json:"status" db:"status"`
-	Result     float64   `json:"result,omitempty" db:"result"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
-}
-
-type APIError struct {
-	Error      string `json:"error"`
-	StatusCode int    `json:"-"`
-}
-
-type JWTResponse struct {
-	Token string `json:"token"`
-}
-
-type CalculationRequest struct {
-	Expression string `json:"expression"`
-}
-
-type CalculationResponse struct {
-	ID     int64   `json:"id"`
-	Status string  `json:"status"`
-	Result float64 `json:"result,omitempty"`
-}
+package models
+
+import (
+	"time"
+)
+
+type User struct {
+	ID           int       `json:"id" db:"id"`
+	Login        string    `json:"login" db:"login"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+type Expression struct {
+	ID          int64        `json:"id" db:"id"`
+	UserID      int          `json:"user_id" db:"user_id"`
+	Expression  string       `json:"expression" db:"expression"`
+	Notation    string       `json:"notation" db:"notation"`
+	Dialect     string       `json:"dialect" db:"dialect"`
+	Seed        int64        `json:"seed" db:"seed"`
+	Status      string       `json:"status" db:"status"`
+	Result      float64      `json:"result,omitempty" db:"result"`
+	Imag        float64      `json:"imag,omitempty" db:"result_imag"`
+	Exact       string       `json:"exact,omitempty" db:"result_exact"`
+	Digits      int          `json:"digits,omitempty" db:"result_digits"`
+	Uncertainty float64      `json:"uncertainty,omitempty" db:"result_uncertainty"`
+	Words       string       `json:"words,omitempty" db:"result_words"`
+	ISO         string       `json:"iso,omitempty" db:"result_iso"`
+	Value       *Value       `json:"value,omitempty" db:"result_value"`
+	Assignments []Assignment `json:"assignments,omitempty" db:"assignments"`
+	ErrorCode   string       `json:"error_code,omitempty" db:"error_code"`
+	Error       string       `json:"error,omitempty" db:"error_message"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+	StartedAt   *time.Time   `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt  *time.Time   `json:"finished_at,omitempty" db:"finished_at"`
+	DurationMs  int64        `json:"duration_ms,omitempty" db:"duration_ms"`
+	Agent       string       `json:"agent,omitempty" db:"agent"`
+	Attempts    int          `json:"attempts" db:"attempts"`
+	Version     int          `json:"version" db:"version"`
+	// Rendered is the expression typeset as LaTeX or MathML, when asked
+	// for with format=latex or format=mathml.
+	Rendered string `json:"rendered,omitempty" db:"-"`
+}
+
+// ExpressionList is a page of the expression history.
+type ExpressionList struct {
+	Expressions []Expression `json:"expressions"`
+	// NextCursor fetches the next page; it is absent on the last one.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Function is a version of a user-defined function.
+type Function struct {
+	Name       string    `json:"name"`
+	Params     []string  `json:"params"`
+	Body       string    `json:"body"`
+	Definition string    `json:"definition"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Rendered is the definition typeset as LaTeX or MathML, when asked
+	// for with format=latex or format=mathml.
+	Rendered string `json:"rendered,omitempty"`
+}
+
+type FunctionRequest struct {
+	Definition string `json:"definition"`
+}
+
+// Settings are a user's preferences.
+type Settings struct {
+	// Dialect is the infix dialect expressions are read in when a request
+	// names none: "standard", "math", "python" or "spreadsheet".
+	Dialect string `json:"dialect"`
+}
+
+// ConversionRequest asks for an expression to be rewritten from one
+// notation into another: "infix" (default), "rpn" or "prefix".
+type ConversionRequest struct {
+	Expression string `json:"expression"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to"`
+}
+
+type APIError struct {
+	Error string `json:"error"`
+	// Code is the machine-readable class of the error: a code of the
+	// calculator, such as SYNTAX or DIVISION_BY_ZERO, or one of the request
+	// codes below.
+	Code       string `json:"code,omitempty"`
+	StatusCode int    `json:"-"`
+}
+
+// Codes of errors about the request itself rather than the expression.
+const (
+	CodeInvalidRequest = "INVALID_REQUEST"
+	CodeUnauthorized   = "UNAUTHORIZED"
+	CodeForbidden      = "FORBIDDEN"
+	CodeNotFound       = "NOT_FOUND"
+	CodeConflict       = "CONFLICT"
+)
+
+type JWTResponse struct {
+	Token string `json:"token"`
+}
+
+type CalculationRequest struct {
+	Expression string `json:"expression"`
+	// Complex allows complex results such as sqrt(-1) = i.
+	Complex bool `json:"complex,omitempty"`
+	// Words marks an expression written out in words, such as "двести
+	// плюс тридцать пять".
+	Words bool `json:"words,omitempty"`
+	// SpellOut asks for the result written out in words as well, in
+	// English ("en") or Russian ("ru").
+	SpellOut string `json:"spell_out,omitempty"`
+	// Notation is the notation the expression is written in: "infix"
+	// (default), "rpn" or "prefix".
+	Notation string `json:"notation,omitempty"`
+	// Dialect is the infix dialect the expression is written in, the
+	// user's default when empty.
+	Dialect string `json:"dialect,omitempty"`
+	// Timezone is the IANA timezone dates without an offset and today are
+	// in, UTC when empty.
+	Timezone string `json:"timezone,omitempty"`
+	// Seed seeds rand, randint, normal and dice, the expression ID when
+	// empty. It is stored with the expression, so running it again gives
+	// the same values.
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// CalculationResponse acknowledges an expression sent to /calculate. The
+// result, with the imaginary part of a complex one in Imag, is fetched
+// later from /expressions/{id}.
+type CalculationResponse struct {
+	ID         int64  `json:"id"`
+	Status     string `json:"status"`
+	Expression string `json:"expression,omitempty"`
+	// Rendered is the expression typeset in the requested format, with i
+	// set apart as the imaginary unit.
+	Rendered string `json:"rendered,omitempty"`
+}
+
+// Assignment is a binding made by a multi-statement expression such as
+// "a = 2; b = a * 3; b ^ 2".
+type Assignment struct {
+	Name        string  `json:"name"`
+	Result      float64 `json:"result,omitempty"`
+	Imag        float64 `json:"imag,omitempty"`
+	Exact       string  `json:"exact,omitempty"`
+	Digits      int     `json:"digits,omitempty"`
+	Uncertainty float64 `json:"uncertainty,omitempty"`
+	ISO         string  `json:"iso,omitempty"`
+	Value       *Value  `json:"value,omitempty"`
+}
+
+// Plot is an expression of one variable sampled over a range.
+type Plot struct {
+	Expression string      `json:"expression"`
+	Variable   string      `json:"variable"`
+	From       float64     `json:"from"`
+	To         float64     `json:"to"`
+	Points     []PlotPoint `json:"points"`
+	// Discontinuities are the x positions between two samples where the
+	// curve jumps, such as the poles of tan(x).
+	Discontinuities []float64 `json:"discontinuities"`
+	// Rendered is the expression typeset as LaTeX or MathML, when asked
+	// for with format=latex or format=mathml.
+	Rendered string `json:"rendered,omitempty"`
+}
+
+// PlotPoint is a sample of a plot. Y is null where the expression is
+// undefined.
+type PlotPoint struct {
+	X float64  `json:"x"`
+	Y *float64 `json:"y"`
+}
+
+// Value holds a calculation result that does not fit into a single number.
+// Scalar results are carried by the Result and Imag fields alone; integers
+// beyond float64 precision also come as Exact decimal digits, as do the
+// integer lists of factor, and measured values with their Uncertainty. An
+// interval has Kind "interval" and its bounds as the two Values, Result is
+// then its midpoint. Dates and durations come as ISO 8601 text, with
+// Result holding Unix seconds or the length in seconds.
+type Value struct {
+	Kind   string      `json:"kind"`
+	Values []float64   `json:"values,omitempty"`
+	Matrix [][]float64 `json:"matrix,omitempty"`
+}
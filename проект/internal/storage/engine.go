@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// engine is what differs between the databases the storage runs on.
+// Queries are written with ? placeholders and rebound for engines that
+// number them.
+type engine struct {
+	// name is also the directory of the engine's migrations.
+	name   string
+	driver string
+	// numbered engines write placeholders as $1, $2, ...
+	numbered bool
+	// position is the function finding a string in another, called with
+	// the text first like instr and strpos.
+	position string
+}
+
+var (
+	sqliteEngine   = engine{name: "sqlite", driver: "sqlite3", position: "instr"}
+	postgresEngine = engine{name: "postgres", driver: "postgres", numbered: true, position: "strpos"}
+)
+
+// engineFor picks the engine by the DSN: a postgres:// URL or a libpq
+// key=value string is PostgreSQL, anything else an SQLite file.
+func engineFor(dsn string) engine {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresEngine
+	}
+	for _, field := range strings.Fields(dsn) {
+		if key, _, ok := strings.Cut(field, "="); ok && (key == "host" || key == "dbname") {
+			return postgresEngine
+		}
+	}
+	return sqliteEngine
+}
+
+// rebind rewrites the ? placeholders of a query for the engine.
+func (e engine) rebind(query string) string {
+	if !e.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// timestamp is t as an argument compared with timestamp columns. SQLite
+// keeps them as text in UTC, so t is written the way CURRENT_TIMESTAMP
+// writes it; PostgreSQL keeps them as TIMESTAMPTZ, which compare as
+// instants whatever the time zone of the session.
+func (e engine) timestamp(t time.Time) interface{} {
+	if e == sqliteEngine {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return t
+}
+
+// database is an *sql.DB whose queries are rebound for its engine.
+type database struct {
+	*sql.DB
+	engine engine
+}
+
+func (db *database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.engine.rebind(query), args...)
+}
+
+func (db *database) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.engine.rebind(query), args...)
+}
+
+func (db *database) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.engine.rebind(query), args...)
+}
+
+func (db *database) Begin() (*transaction, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{Tx: tx, engine: db.engine}, nil
+}
+
+// transaction is an *sql.Tx whose queries are rebound for its engine.
+type transaction struct {
+	*sql.Tx
+	engine engine
+}
+
+func (tx *transaction) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.engine.rebind(query), args...)
+}
+
+func (tx *transaction) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.engine.rebind(query), args...)
+}
+
+func (tx *transaction) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.engine.rebind(query), args...)
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrFunctionNotFound = errors.New("function not found")
+
+// Function is one version of a user-defined function. Editing a function
+// adds a new version, so expressions keep the definitions they were
+// calculated with.
+type Function struct {
+	ID        int64
+	UserID    int
+	Name      string
+	Version   int
+	Params    []string
+	Body      string
+	CreatedAt time.Time
+}
+
+const functionColumns = "id, user_id, name, version, params, body, created_at"
+
+func (s *Storage) SaveFunction(userID int, name string, params []string, body string) (*Function, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("transaction begin failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRow(
+		"SELECT COALESCE(MAX(version), 0) FROM functions WHERE user_id = ? AND name = ?",
+		userID, name,
+	).Scan(&version)
+	if err != nil {
+		return nil, fmt.Errorf("function version query failed: %w", err)
+	}
+
+	fn, err := scanFunction(tx.QueryRow(
+		"INSERT INTO functions (user_id, name, version, params, body) VALUES (?, ?, ?, ?, ?) RETURNING "+functionColumns,
+		userID, name, version+1, strings.Join(params, ","), body,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("transaction commit failed: %w", err)
+	}
+
+	return fn, nil
+}
+
+// GetUserFunctions returns the latest version of every function of a user.
+func (s *Storage) GetUserFunctions(userID int) ([]Function, error) {
+	return s.queryFunctions(
+		"SELECT "+functionColumns+" FROM functions f WHERE user_id = ? AND version = "+
+			"(SELECT MAX(version) FROM functions WHERE user_id = f.user_id AND name = f.name) ORDER BY name",
+		userID,
+	)
+}
+
+// GetFunctionVersions returns every version of a function, oldest first.
+func (s *Storage) GetFunctionVersions(userID int, name string) ([]Function, error) {
+	functions, err := s.queryFunctions(
+		"SELECT "+functionColumns+" FROM functions WHERE user_id = ? AND name = ? ORDER BY version",
+		userID, name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(functions) == 0 {
+		return nil, ErrFunctionNotFound
+	}
+	return functions, nil
+}
+
+// GetExpressionFunctions returns the function versions pinned to an
+// expression when it was submitted.
+func (s *Storage) GetExpressionFunctions(exprID int64) ([]Function, error) {
+	return s.queryFunctions(
+		"SELECT "+functionColumns+" FROM functions WHERE id IN "+
+			"(SELECT function_id FROM expression_functions WHERE expression_id = ?) ORDER BY name",
+		exprID,
+	)
+}
+
+func (s *Storage) queryFunctions(query string, args ...interface{}) ([]Function, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("functions query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var functions []Function
+	for rows.Next() {
+		fn, err := scanFunction(rows)
+		if err != nil {
+			return nil, err
+		}
+		functions = append(functions, *fn)
+	}
+
+	return functions, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFunction(row scanner) (*Function, error) {
+	var fn Function
+	var params string
+	err := row.Scan(
+		&fn.ID,
+		&fn.UserID,
+		&fn.Name,
+		&fn.Version,
+		&params,
+		&fn.Body,
+		&fn.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFunctionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("function scan failed: %w", err)
+	}
+
+	if params != "" {
+		fn.Params = strings.Split(params, ",")
+	}
+	return &fn, nil
+}
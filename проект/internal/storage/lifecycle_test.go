@@ -0,0 +1,216 @@
+package storage_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opr1234/calculator/internal/storage"
+)
+
+// repositories are the backends the lifecycle is checked on.
+var repositories = []struct {
+	name string
+	open func(t *testing.T) storage.Repository
+}{
+	{"Memory", func(t *testing.T) storage.Repository { return storage.NewMemory() }},
+	{"SQLite", func(t *testing.T) storage.Repository { return migrated(t, openSQLite) }},
+}
+
+// A step moves an expression at version on and returns the new version.
+type step func(repo storage.Repository, id int64, version int) (int, error)
+
+func start(repo storage.Repository, id int64, _ int) (int, error) {
+	return repo.StartExpression(id)
+}
+
+func moveTo(status string) step {
+	return func(repo storage.Repository, id int64, version int) (int, error) {
+		return repo.TransitionExpression(id, version, status)
+	}
+}
+
+func finish(res storage.ExpressionResult) step {
+	return func(repo storage.Repository, id int64, version int) (int, error) {
+		return version + 1, repo.UpdateExpressionStatus(id, version, res)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	completed := storage.ExpressionResult{
+		Status:   storage.StatusCompleted,
+		Result:   4,
+		Duration: 250*time.Millisecond + 700*time.Microsecond,
+		Agent:    "agent-2",
+	}
+	failed := storage.ExpressionResult{
+		Status:       storage.StatusError,
+		ErrorCode:    "TIMEOUT",
+		ErrorMessage: "evaluation timed out",
+		Duration:     30 * time.Second,
+		Agent:        "agent-1",
+	}
+
+	tests := []struct {
+		name     string
+		steps    []step
+		status   string
+		attempts int
+		started  bool
+		finished bool
+		duration time.Duration
+		agent    string
+	}{
+		{name: "saved", status: storage.StatusPending},
+		{name: "started", steps: []step{start}, status: storage.StatusProcessing, attempts: 1, started: true},
+		{
+			name:   "cancelled before it starts",
+			steps:  []step{moveTo(storage.StatusCancelled)},
+			status: storage.StatusCancelled,
+		},
+		{
+			name:     "cancelled while processing",
+			steps:    []step{start, moveTo(storage.StatusCancelled)},
+			status:   storage.StatusCancelled,
+			attempts: 1,
+			started:  true,
+		},
+		{
+			name:     "completed",
+			steps:    []step{start, finish(completed)},
+			status:   storage.StatusCompleted,
+			attempts: 1,
+			started:  true,
+			finished: true,
+			duration: 250 * time.Millisecond,
+			agent:    "agent-2",
+		},
+		{
+			name:     "queued again and completed",
+			steps:    []step{start, moveTo(storage.StatusQueued), start, finish(completed)},
+			status:   storage.StatusCompleted,
+			attempts: 2,
+			started:  true,
+			finished: true,
+			duration: 250 * time.Millisecond,
+			agent:    "agent-2",
+		},
+		{
+			name:     "failed",
+			steps:    []step{start, finish(failed)},
+			status:   storage.StatusError,
+			attempts: 1,
+			started:  true,
+			finished: true,
+			duration: 30 * time.Second,
+			agent:    "agent-1",
+		},
+	}
+	for _, r := range repositories {
+		t.Run(r.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					repo := r.open(t)
+					user, err := repo.CreateUser("alice", "hash")
+					if err != nil {
+						t.Fatalf("CreateUser: %v", err)
+					}
+					id, err := repo.SaveExpression(int(user), "2+2", "infix", "standard", nil)
+					if err != nil {
+						t.Fatalf("SaveExpression: %v", err)
+					}
+
+					version := 1
+					for i, step := range tt.steps {
+						if version, err = step(repo, id, version); err != nil {
+							t.Fatalf("step %d: %v", i+1, err)
+						}
+					}
+
+					e, err := repo.GetExpression(int(user), id)
+					if err != nil {
+						t.Fatalf("GetExpression: %v", err)
+					}
+					if e.Status != tt.status || e.Attempts != tt.attempts || e.Version != len(tt.steps)+1 {
+						t.Errorf("status, attempts, version = %s, %d, %d; want %s, %d, %d",
+							e.Status, e.Attempts, e.Version, tt.status, tt.attempts, len(tt.steps)+1)
+					}
+					if e.StartedAt.IsZero() == tt.started || e.FinishedAt.IsZero() == tt.finished {
+						t.Errorf("started %v, finished %v; want started: %v, finished: %v",
+							e.StartedAt, e.FinishedAt, tt.started, tt.finished)
+					}
+					if e.UpdatedAt.Before(e.CreatedAt) || tt.started && e.UpdatedAt.Before(e.StartedAt) {
+						t.Errorf("updated %v before created %v or started %v", e.UpdatedAt, e.CreatedAt, e.StartedAt)
+					}
+					if e.Duration != tt.duration || e.Agent != tt.agent {
+						t.Errorf("duration, agent = %v, %q; want %v, %q", e.Duration, e.Agent, tt.duration, tt.agent)
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestConflictingUpdates records outcomes of one expression from several
+// writers at once, all based on the same version: exactly one wins and
+// the others get ErrVersionConflict or, once the winner made the
+// expression final, a TransitionError.
+func TestConflictingUpdates(t *testing.T) {
+	const writers = 8
+	for _, r := range repositories {
+		t.Run(r.name, func(t *testing.T) {
+			repo := r.open(t)
+			user, err := repo.CreateUser("alice", "hash")
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			id, err := repo.SaveExpression(int(user), "2+2", "infix", "standard", nil)
+			if err != nil {
+				t.Fatalf("SaveExpression: %v", err)
+			}
+			version, err := repo.StartExpression(id)
+			if err != nil {
+				t.Fatalf("StartExpression: %v", err)
+			}
+
+			errs := make([]error, writers)
+			var wg sync.WaitGroup
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = repo.UpdateExpressionStatus(id, version, storage.ExpressionResult{
+						Status: storage.StatusCompleted,
+						Result: float64(i),
+					})
+				}(i)
+			}
+			wg.Wait()
+
+			winner := -1
+			for i, err := range errs {
+				switch {
+				case err == nil && winner >= 0:
+					t.Errorf("writers %d and %d both won", winner, i)
+				case err == nil:
+					winner = i
+				case !errors.Is(err, storage.ErrVersionConflict) && !errors.Is(err, storage.ErrIllegalTransition):
+					t.Errorf("writer %d: %v, want a conflict", i, err)
+				}
+			}
+			if winner < 0 {
+				t.Fatal("no writer won")
+			}
+
+			e, err := repo.GetExpression(int(user), id)
+			if err != nil {
+				t.Fatalf("GetExpression: %v", err)
+			}
+			if e.Status != storage.StatusCompleted || e.Result != float64(winner) || e.Version != version+1 {
+				t.Errorf("status, result, version = %s, %v, %d; want completed, %d, %d",
+					e.Status, e.Result, e.Version, winner, version+1)
+			}
+		})
+	}
+}
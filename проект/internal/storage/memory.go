@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a Repository kept in memory, for tests and throwaway
+// development servers. It behaves like Storage: logins are unique, IDs
+// count up from 1 per table and expressions start out pending.
+type Memory struct {
+	mu          sync.Mutex
+	users       []User
+	expressions []Expression
+	// pinned holds the function versions of every expression.
+	pinned    map[int64][]int64
+	functions []Function
+	modules   []Module
+}
+
+func NewMemory() *Memory {
+	return &Memory{pinned: make(map[int64][]int64)}
+}
+
+// now is the timestamp of new rows, in the precision of the databases.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func (m *Memory) CreateUser(login, passwordHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Login == login {
+			return 0, ErrUserExists
+		}
+	}
+	user := User{
+		ID:           len(m.users) + 1,
+		Login:        login,
+		PasswordHash: passwordHash,
+		Dialect:      "standard",
+	}
+	m.users = append(m.users, user)
+	return int64(user.ID), nil
+}
+
+func (m *Memory) GetUserByLogin(login string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Login == login {
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (m *Memory) GetUserByID(id int) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > len(m.users) {
+		return nil, ErrUserNotFound
+	}
+	user := m.users[id-1]
+	return &user, nil
+}
+
+func (m *Memory) SetUserDialect(userID int, dialect string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if userID < 1 || userID > len(m.users) {
+		return ErrUserNotFound
+	}
+	m.users[userID-1].Dialect = dialect
+	return nil
+}
+
+func (m *Memory) SaveExpression(userID int, expr, notation, dialect string, seed *int64, functionIDs ...int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := int64(len(m.expressions) + 1)
+	e := Expression{
+		ID:         id,
+		UserID:     userID,
+		Expression: expr,
+		Notation:   notation,
+		Dialect:    dialect,
+		Seed:       id,
+		Status:     StatusPending,
+		Version:    1,
+		CreatedAt:  now(),
+	}
+	e.UpdatedAt = e.CreatedAt
+	if seed != nil {
+		e.Seed = *seed
+	}
+	m.expressions = append(m.expressions, e)
+	m.pinned[id] = append([]int64(nil), functionIDs...)
+	return id, nil
+}
+
+func (m *Memory) StartExpression(id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.transition(id, 0, StatusProcessing)
+	if err != nil {
+		return 0, err
+	}
+	e.StartedAt = e.UpdatedAt
+	e.Attempts++
+	return e.Version, nil
+}
+
+func (m *Memory) TransitionExpression(id int64, version int, status string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.transition(id, version, status)
+	if err != nil {
+		return 0, err
+	}
+	return e.Version, nil
+}
+
+func (m *Memory) UpdateExpressionStatus(id int64, version int, res ExpressionResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.transition(id, version, res.Status)
+	if err != nil {
+		return err
+	}
+	e.Result = res.Result
+	e.ResultImag = res.ResultImag
+	e.ResultExact = res.ResultExact
+	e.ResultDigits = res.ResultDigits
+	e.ResultUncertainty = res.ResultUncertainty
+	e.ResultWords = res.ResultWords
+	e.ResultISO = res.ResultISO
+	e.ResultValue = res.ResultValue
+	e.Assignments = res.Assignments
+	e.ErrorCode = res.ErrorCode
+	e.ErrorMessage = res.ErrorMessage
+	// The databases keep the duration in milliseconds.
+	e.Duration = res.Duration.Truncate(time.Millisecond)
+	e.Agent = res.Agent
+	e.FinishedAt = e.UpdatedAt
+	return nil
+}
+
+// transition moves an expression at version, the current one when zero,
+// to status and bumps its version. The caller holds the lock.
+func (m *Memory) transition(id int64, version int, status string) (*Expression, error) {
+	if id < 1 || id > int64(len(m.expressions)) {
+		return nil, ErrExpressionNotFound
+	}
+	e := &m.expressions[id-1]
+	if version == 0 {
+		version = e.Version
+	}
+	if err := checkTransition(id, e.Status, e.Version, version, status); err != nil {
+		return nil, err
+	}
+	e.Status = status
+	e.Version++
+	e.UpdatedAt = now()
+	return e, nil
+}
+
+func (m *Memory) GetUserExpressions(userID int) ([]Expression, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expressions []Expression
+	for _, e := range m.expressions {
+		if e.UserID == userID {
+			expressions = append(expressions, e)
+		}
+	}
+	return expressions, nil
+}
+
+func (m *Memory) ListExpressions(q ExpressionQuery) (*ExpressionPage, error) {
+	after, err := q.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expressions []Expression
+	for _, e := range m.expressions {
+		if e.UserID != q.UserID ||
+			q.Status != "" && e.Status != q.Status ||
+			!q.From.IsZero() && e.CreatedAt.Before(q.From) ||
+			!q.To.IsZero() && !e.CreatedAt.Before(q.To) ||
+			!strings.Contains(e.Expression, q.Contains) {
+			continue
+		}
+		if after != nil && !q.follows(e, after) {
+			continue
+		}
+		expressions = append(expressions, e)
+	}
+	sort.Slice(expressions, func(i, j int) bool {
+		c := q.compare(expressions[i], positionOf(expressions[j]))
+		return (c < 0) != q.Descending
+	})
+	if len(expressions) > q.Limit+1 {
+		expressions = expressions[:q.Limit+1]
+	}
+	return q.paginate(&ExpressionPage{Expressions: expressions}), nil
+}
+
+// compare orders e and the position c the way q sorts.
+func (q *ExpressionQuery) compare(e Expression, c cursor) int {
+	switch {
+	case q.Sort == SortCreatedAt && !e.CreatedAt.Equal(c.time):
+		if e.CreatedAt.Before(c.time) {
+			return -1
+		}
+		return 1
+	case q.Sort == SortStatus && e.Status != c.Value:
+		return strings.Compare(e.Status, c.Value)
+	case e.ID < c.ID:
+		return -1
+	case e.ID > c.ID:
+		return 1
+	}
+	return 0
+}
+
+func positionOf(e Expression) cursor {
+	return cursor{Value: e.Status, ID: e.ID, time: e.CreatedAt}
+}
+
+// follows reports whether e comes after the cursor c.
+func (q *ExpressionQuery) follows(e Expression, c *cursor) bool {
+	if q.Descending {
+		return q.compare(e, *c) < 0
+	}
+	return q.compare(e, *c) > 0
+}
+
+func (m *Memory) GetExpression(userID int, id int64) (*Expression, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > int64(len(m.expressions)) || m.expressions[id-1].UserID != userID {
+		return nil, ErrExpressionNotFound
+	}
+	e := m.expressions[id-1]
+	return &e, nil
+}
+
+func (m *Memory) GetPendingExpressions() ([]Expression, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expressions []Expression
+	for _, e := range m.expressions {
+		if e.Status == StatusPending {
+			expressions = append(expressions, Expression{
+				ID:         e.ID,
+				UserID:     e.UserID,
+				Expression: e.Expression,
+			})
+		}
+	}
+	return expressions, nil
+}
+
+func (m *Memory) SaveFunction(userID int, name string, params []string, body string) (*Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	version := 0
+	for _, fn := range m.functions {
+		if fn.UserID == userID && fn.Name == name && fn.Version > version {
+			version = fn.Version
+		}
+	}
+	fn := Function{
+		ID:        int64(len(m.functions) + 1),
+		UserID:    userID,
+		Name:      name,
+		Version:   version + 1,
+		Params:    append([]string(nil), params...),
+		Body:      body,
+		CreatedAt: now(),
+	}
+	m.functions = append(m.functions, fn)
+	return &fn, nil
+}
+
+func (m *Memory) GetUserFunctions(userID int) ([]Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latest := make(map[string]Function)
+	for _, fn := range m.functions {
+		if fn.UserID == userID && fn.Version > latest[fn.Name].Version {
+			latest[fn.Name] = fn
+		}
+	}
+	functions := make([]Function, 0, len(latest))
+	for _, fn := range latest {
+		functions = append(functions, fn)
+	}
+	sortByName(functions)
+	return functions, nil
+}
+
+func (m *Memory) GetFunctionVersions(userID int, name string) ([]Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var functions []Function
+	for _, fn := range m.functions {
+		if fn.UserID == userID && fn.Name == name {
+			functions = append(functions, fn)
+		}
+	}
+	if len(functions) == 0 {
+		return nil, ErrFunctionNotFound
+	}
+	return functions, nil
+}
+
+func (m *Memory) GetExpressionFunctions(exprID int64) ([]Function, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var functions []Function
+	for _, id := range m.pinned[exprID] {
+		if id >= 1 && id <= int64(len(m.functions)) {
+			functions = append(functions, m.functions[id-1])
+		}
+	}
+	sortByName(functions)
+	return functions, nil
+}
+
+func sortByName(functions []Function) {
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].Name < functions[j].Name
+	})
+}
+
+func (m *Memory) SaveModule(name string, binary []byte, exports []string, uploadedBy int) (*Module, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sum := sha256.Sum256(binary)
+	module := Module{
+		ID:         int64(len(m.modules) + 1),
+		Name:       name,
+		Binary:     append([]byte(nil), binary...),
+		Checksum:   hex.EncodeToString(sum[:]),
+		Exports:    append([]string(nil), exports...),
+		UploadedBy: uploadedBy,
+		CreatedAt:  now(),
+	}
+	for i, existing := range m.modules {
+		if existing.Name == name {
+			module.ID = existing.ID
+			m.modules[i] = module
+			return &module, nil
+		}
+	}
+	m.modules = append(m.modules, module)
+	return &module, nil
+}
+
+func (m *Memory) GetModules() ([]Module, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	modules := append([]Module(nil), m.modules...)
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	return modules, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
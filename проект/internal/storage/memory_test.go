@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/opr1234/calculator/internal/storage"
+	"github.com/opr1234/calculator/internal/storage/storagetest"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Repository {
+		return storage.NewMemory()
+	})
+}
@@ -1,135 +1,524 @@
-package storage
-
-import (
-	"embed"
-	"fmt"
-	"io/fs"
-	"path/filepath"
-	"sort"
-	"strconv"
-	"strings"
-)
-
-const migrationsTable = "schema_migrations"
-
-type Migration struct {
-	Version int
-	Query   string
-}
-
-func (s *Storage) Migrate() error {
-	if err := s.createMigrationsTable(); err != nil {
-		return fmt.Errorf("create migrations table failed: %w", err)
-	}
-
-	applied, err := s.getAppliedMigrations()
-	if err != nil {
-		return fmt.Errorf("get applied migrations failed: %w", err)
-	}
-
-	migrations, err := s.loadMigrations()
-	if err != nil {
-		return fmt.Errorf("load migrations failed: %w", err)
-	}
-
-	for _, m := range migrations {
-		if _, ok := applied[m.Version]; !ok {
-			if err := s.applyMigration(m); err != nil {
-				return fmt.Errorf("apply migration %d failed: %w", m.Version, err)
-			}
-		}
-	}
-
-	return nil
-}
-
-func (s *Storage) createMigrationsTable() error {
-	_, err := s.db.Exec(fmt.Sprintf(`
-        CREATE TABLE IF NOT EXISTS %s (
-            version INTEGER PRIMARY KEY,
-            applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
-        )
-    `, migrationsTable))
-	return err
-}
-
-func (s *Storage) getAppliedMigrations() (map[int]struct{}, error) {
-	rows, err := s.db.Query(fmt.Sprintf(
-		"SELECT version FROM %s ORDER BY version ASC",
-		migrationsTable,
-	))
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	migrations := make(map[int]struct{})
-	for rows.Next() {
-		var version int
-		if err := rows.Scan(&version); err != nil {
-			return nil, err
-		}
-		migrations[version] = struct{}{}
-	}
-	return migrations, nil
-}
-
-func (s *Storage) loadMigrations() ([]Migration, error) {
-	files, err := fs.ReadDir(migrationsFS, "migrations")
-	if err != nil {
-		return nil, err
-	}
-
-	var migrations []Migration
-	for _, f := range files {
-		if filepath.Ext(f.Name()) != ".sql" {
-			continue
-		}
-
-		base := filepath.Base(f.Name())
-		parts := strings.SplitN(base, "_", 2)
-		version, err := strconv.Atoi(parts[0])
-		if err != nil {
-			return nil, fmt.Errorf("invalid migration name %s: %w", f.Name(), err)
-		}
-
-		content, err := fs.ReadFile(migrationsFS, filepath.Join("migrations", f.Name()))
-		if err != nil {
-			return nil, err
-		}
-
-		migrations = append(migrations, Migration{
-			Version: version,
-			Query:   string(content),
-		})
-	}
-
-	sort.Slice(migrations, func(i, j int) bool {
-		return migrations[i].Version < migrations[j].Version
-	})
-
-	return migrations, nil
-}
-
-func (s *Storage) applyMigration(m Migration) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(m.Query); err != nil {
-		return fmt.Errorf("execute query failed: %w", err)
-	}
-
-	if _, err := tx.Exec(
-		fmt.Sprintf("INSERT INTO %s (version) VALUES (?)", migrationsTable),
-		m.Version,
-	); err != nil {
-		return fmt.Errorf("record migration failed: %w", err)
-	}
-
-	return tx.Commit()
-}
-
-var migrationsFS embed.FS
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMigrationModified    = errors.New("applied migration was modified")
+	ErrMigrationMissing     = errors.New("applied migration is unknown")
+	ErrMigrationLocked      = errors.New("migrations are locked by another process")
+	ErrUnknownMigration     = errors.New("unknown migration version")
+	ErrInvalidMigrationName = errors.New("invalid migration name")
+)
+
+const (
+	migrationsTable     = "schema_migrations"
+	migrationsLockTable = "schema_migrations_lock"
+	// migrationsLockKey is the PostgreSQL advisory lock taken while
+	// migrating; the number is arbitrary but must never change.
+	migrationsLockKey = 4620221
+	// lockTimeout is how long to wait for the migrations of another
+	// process before giving up.
+	lockTimeout = time.Minute
+)
+
+// migrationsFS holds the migrations of every engine, as
+// migrations/<engine>/NNN_name.up.sql with a NNN_name.down.sql undoing it.
+//
+//go:embed migrations
+var migrationsFS embed.FS
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the SHA-256 of Up, recorded when the migration is
+	// applied and checked on every later run.
+	Checksum string
+}
+
+// MigrationStatus is the state of one migration, known to this build or
+// applied to the database.
+type MigrationStatus struct {
+	Version int
+	Name    string
+	Applied bool
+	// AppliedAt is zero for pending migrations.
+	AppliedAt time.Time
+	// Modified is an applied migration whose file changed since.
+	Modified bool
+	// Missing is an applied migration this build has no file for, left by
+	// a newer version of the server.
+	Missing bool
+}
+
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrate applies every pending migration. It never rolls back, so an
+// older server can start on a database migrated by a newer one.
+func (s *Storage) Migrate() error {
+	return s.migrate(false, func(known []Migration, applied map[int]appliedMigration) error {
+		return s.up(known, applied, known[len(known)-1].Version)
+	})
+}
+
+// MigrateTo applies or rolls back migrations until version is the last one
+// applied; version 0 rolls back everything.
+func (s *Storage) MigrateTo(version int) error {
+	return s.migrate(false, func(known []Migration, applied map[int]appliedMigration) error {
+		if version != 0 && findMigration(known, version) == nil {
+			return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+		}
+		if err := s.down(known, applied, version, len(applied)); err != nil {
+			return err
+		}
+		return s.up(known, applied, version)
+	})
+}
+
+// MigrateDown rolls back the last n applied migrations.
+func (s *Storage) MigrateDown(n int) error {
+	return s.migrate(false, func(known []Migration, applied map[int]appliedMigration) error {
+		return s.down(known, applied, 0, n)
+	})
+}
+
+// RedoMigration rolls back the last applied migration and applies it
+// again. Its file may have changed since: redoing is how an edited
+// migration is tried out during development.
+func (s *Storage) RedoMigration() error {
+	return s.migrate(true, func(known []Migration, applied map[int]appliedMigration) error {
+		versions := appliedVersions(applied)
+		if len(versions) == 0 {
+			return nil
+		}
+		last := versions[len(versions)-1]
+		if err := s.down(known, applied, 0, 1); err != nil {
+			return err
+		}
+		return s.applyMigration(*findMigration(known, last), true)
+	})
+}
+
+// MigrationStatuses lists every migration known or applied, by version.
+func (s *Storage) MigrationStatuses() ([]MigrationStatus, error) {
+	if err := s.createMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("create migrations table failed: %w", err)
+	}
+	known, err := s.loadMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("load migrations failed: %w", err)
+	}
+	applied, err := s.getAppliedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("get applied migrations failed: %w", err)
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range known {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.appliedAt
+			status.Modified = a.checksum != "" && a.checksum != m.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for _, version := range appliedVersions(applied) {
+		if findMigration(known, version) == nil {
+			a := applied[version]
+			statuses = append(statuses, MigrationStatus{
+				Version:   version,
+				Name:      a.name,
+				Applied:   true,
+				AppliedAt: a.appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// migrate runs step under the migrations lock, once the applied migrations
+// are known to match their files. With redo the last applied migration is
+// not checked, as it is about to be applied again.
+func (s *Storage) migrate(redo bool, step func(known []Migration, applied map[int]appliedMigration) error) error {
+	unlock, err := s.lockMigrations()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.createMigrationsTable(); err != nil {
+		return fmt.Errorf("create migrations table failed: %w", err)
+	}
+	known, err := s.loadMigrations()
+	if err != nil {
+		return fmt.Errorf("load migrations failed: %w", err)
+	}
+	if len(known) == 0 {
+		return fmt.Errorf("load migrations failed: no migrations for %s", s.db.engine.name)
+	}
+	applied, err := s.getAppliedMigrations()
+	if err != nil {
+		return fmt.Errorf("get applied migrations failed: %w", err)
+	}
+
+	skip := 0
+	if redo {
+		if versions := appliedVersions(applied); len(versions) > 0 {
+			skip = versions[len(versions)-1]
+		}
+	}
+	if err := s.verifyChecksums(known, applied, skip); err != nil {
+		return err
+	}
+	return step(known, applied)
+}
+
+// up applies the pending migrations up to version, in order.
+func (s *Storage) up(known []Migration, applied map[int]appliedMigration, version int) error {
+	for _, m := range known {
+		if m.Version > version {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := s.applyMigration(m, true); err != nil {
+			return fmt.Errorf("apply migration %d failed: %w", m.Version, err)
+		}
+		applied[m.Version] = appliedMigration{version: m.Version, name: m.Name, checksum: m.Checksum}
+	}
+	return nil
+}
+
+// down rolls back at most n applied migrations above version, the last
+// applied first.
+func (s *Storage) down(known []Migration, applied map[int]appliedMigration, version, n int) error {
+	versions := appliedVersions(applied)
+	for i := len(versions) - 1; i >= 0 && n > 0; i-- {
+		if versions[i] <= version {
+			break
+		}
+		m := findMigration(known, versions[i])
+		if m == nil {
+			return fmt.Errorf("%w: %d", ErrMigrationMissing, versions[i])
+		}
+		if err := s.applyMigration(*m, false); err != nil {
+			return fmt.Errorf("roll back migration %d failed: %w", m.Version, err)
+		}
+		delete(applied, m.Version)
+		n--
+	}
+	return nil
+}
+
+// verifyChecksums makes sure no applied migration but skip was edited
+// after it ran. Migrations applied before checksums were recorded get the
+// checksum of their file.
+func (s *Storage) verifyChecksums(known []Migration, applied map[int]appliedMigration, skip int) error {
+	for _, m := range known {
+		a, ok := applied[m.Version]
+		if !ok || m.Version == skip {
+			continue
+		}
+		if a.checksum == "" {
+			if _, err := s.db.Exec(
+				fmt.Sprintf("UPDATE %s SET name = ?, checksum = ? WHERE version = ?", migrationsTable),
+				m.Name, m.Checksum, m.Version,
+			); err != nil {
+				return fmt.Errorf("record checksum of migration %d failed: %w", m.Version, err)
+			}
+			continue
+		}
+		if a.checksum != m.Checksum {
+			return fmt.Errorf("%w: %03d_%s", ErrMigrationModified, m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) createMigrationsTable() error {
+	if _, err := s.db.Exec(fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS %s (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL DEFAULT '',
+            checksum TEXT NOT NULL DEFAULT '',
+            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )
+    `, migrationsTable)); err != nil {
+		return err
+	}
+
+	// Tables created before checksums only have version and applied_at.
+	if _, err := s.db.Exec(fmt.Sprintf("SELECT checksum FROM %s WHERE 1 = 0", migrationsTable)); err == nil {
+		return nil
+	}
+	for _, column := range []string{"name", "checksum"} {
+		if _, err := s.db.Exec(fmt.Sprintf(
+			"ALTER TABLE %s ADD COLUMN %s TEXT NOT NULL DEFAULT ''",
+			migrationsTable, column,
+		)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) getAppliedMigrations() (map[int]appliedMigration, error) {
+	rows, err := s.db.Query(fmt.Sprintf(
+		"SELECT version, name, checksum, applied_at FROM %s ORDER BY version ASC",
+		migrationsTable,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	migrations := make(map[int]appliedMigration)
+	for rows.Next() {
+		var m appliedMigration
+		if err := rows.Scan(&m.version, &m.name, &m.checksum, &m.appliedAt); err != nil {
+			return nil, err
+		}
+		migrations[m.version] = m
+	}
+	return migrations, rows.Err()
+}
+
+// loadMigrations reads the migrations of the storage's engine, which live
+// in migrations/<engine>.
+func (s *Storage) loadMigrations() ([]Migration, error) {
+	return readMigrations(migrationsFS, path.Join("migrations", s.db.engine.name))
+}
+
+func readMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".sql" {
+			continue
+		}
+
+		parts := migrationFile.FindStringSubmatch(f.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, f.Name())
+		}
+		version, _ := strconv.Atoi(parts[1])
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[2] {
+			return nil, fmt.Errorf("%w: version %d is both %s and %s", ErrInvalidMigrationName, version, m.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.Up = string(content)
+			m.Checksum = checksum(m.Up)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %03d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// checksum hashes a migration script. Line endings are normalised first,
+// so a checkout with CRLF line endings does not count as an edit.
+func checksum(script string) string {
+	sum := sha256.Sum256([]byte(strings.ReplaceAll(script, "\r\n", "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func findMigration(migrations []Migration, version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+func appliedVersions(applied map[int]appliedMigration) []int {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// applyMigration runs the up or down script of a migration and records it,
+// in one transaction.
+func (s *Storage) applyMigration(m Migration, up bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("execute query failed: %w", err)
+	}
+
+	if up {
+		_, err = tx.Exec(
+			fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES (?, ?, ?)", migrationsTable),
+			m.Version, m.Name, m.Checksum,
+		)
+	} else {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = ?", migrationsTable), m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration failed: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// lockMigrations keeps two processes from migrating at once. PostgreSQL
+// has advisory locks, released with the session even if the process
+// dies; SQLite gets a one-row table, which a crashed process leaves
+// behind and has to be cleared by hand.
+func (s *Storage) lockMigrations() (unlock func(), err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	if s.db.engine == postgresEngine {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("lock migrations failed: %w", err)
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return nil, ErrMigrationLocked
+			}
+			return nil, fmt.Errorf("lock migrations failed: %w", err)
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+			conn.Close()
+		}, nil
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS %s (
+            id INTEGER PRIMARY KEY CHECK (id = 1),
+            locked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )
+    `, migrationsLockTable)); err != nil {
+		return nil, fmt.Errorf("lock migrations failed: %w", err)
+	}
+	for {
+		if _, err := s.db.Exec(fmt.Sprintf("INSERT INTO %s (id) VALUES (1)", migrationsLockTable)); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: remove the row of %s if no migration is running", ErrMigrationLocked, migrationsLockTable)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return func() {
+		s.db.Exec(fmt.Sprintf("DELETE FROM %s", migrationsLockTable))
+	}, nil
+}
+
+// CreateMigration adds empty up and down scripts of a new migration to
+// the migrations of every engine under dir, numbered after the last one.
+// It returns the paths of the files created.
+func CreateMigration(dir, name string) ([]string, error) {
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q, use lower case letters, digits and underscores", ErrInvalidMigrationName, name)
+	}
+
+	engines := []engine{sqliteEngine, postgresEngine}
+	version := 0
+	for _, e := range engines {
+		migrations, err := readMigrations(os.DirFS(dir), e.name)
+		if err != nil {
+			return nil, fmt.Errorf("read migrations failed: %w", err)
+		}
+		if n := len(migrations); n > 0 && migrations[n-1].Version > version {
+			version = migrations[n-1].Version
+		}
+	}
+	version++
+
+	var paths []string
+	for _, e := range engines {
+		for _, direction := range []string{"up", "down"} {
+			p := filepath.Join(dir, e.name, fmt.Sprintf("%03d_%s.%s.sql", version, name, direction))
+			f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return paths, err
+			}
+			_, err = fmt.Fprintf(f, "-- %s: %s migration %03d for %s.\n", name, direction, version, e.name)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return paths, err
+			}
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
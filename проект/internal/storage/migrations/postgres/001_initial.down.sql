@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_expressions_user;
+DROP TABLE IF EXISTS expressions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    login TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS expressions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expression TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    result DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_expressions_user ON expressions(user_id);
@@ -0,0 +1,2 @@
+-- Non-scalar results (lists) are stored as JSON next to the scalar column.
+ALTER TABLE expressions ADD COLUMN result_value TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS expression_functions;
+DROP TABLE IF EXISTS functions;
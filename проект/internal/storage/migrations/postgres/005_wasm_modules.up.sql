@@ -0,0 +1,13 @@
+-- Administrators may upload WebAssembly modules with custom functions.
+ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS wasm_modules (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    "binary" BYTEA NOT NULL,
+    checksum TEXT NOT NULL,
+    exports TEXT NOT NULL,
+    uploaded_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(uploaded_by) REFERENCES users(id)
+);
@@ -0,0 +1,2 @@
+-- Imaginary part of complex results; the real part stays in result.
+ALTER TABLE expressions ADD COLUMN result_imag DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE expressions DROP COLUMN result_words;
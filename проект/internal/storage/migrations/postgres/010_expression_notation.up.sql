@@ -0,0 +1,2 @@
+-- Notation the expression is written in: infix, rpn or prefix.
+ALTER TABLE expressions ADD COLUMN notation TEXT NOT NULL DEFAULT 'infix';
@@ -0,0 +1,2 @@
+ALTER TABLE expressions DROP COLUMN dialect;
+ALTER TABLE users DROP COLUMN dialect;
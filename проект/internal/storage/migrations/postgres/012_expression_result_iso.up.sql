@@ -0,0 +1,2 @@
+-- ISO 8601 text of a date, time or duration result.
+ALTER TABLE expressions ADD COLUMN result_iso TEXT;
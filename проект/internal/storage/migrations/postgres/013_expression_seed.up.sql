@@ -0,0 +1,4 @@
+-- Seed of the random functions, so an expression gives the same values
+-- when it is run again. Existing expressions are seeded with their ID.
+ALTER TABLE expressions ADD COLUMN seed BIGINT;
+UPDATE expressions SET seed = id WHERE seed IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE expressions DROP COLUMN error_message;
+ALTER TABLE expressions DROP COLUMN error_code;
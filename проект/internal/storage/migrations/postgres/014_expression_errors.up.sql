@@ -0,0 +1,4 @@
+-- Why a failed expression failed: a stable code such as DIVISION_BY_ZERO
+-- and a message for people.
+ALTER TABLE expressions ADD COLUMN error_code TEXT;
+ALTER TABLE expressions ADD COLUMN error_message TEXT;
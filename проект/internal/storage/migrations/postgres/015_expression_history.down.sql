@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_expressions_user_status;
+DROP INDEX IF EXISTS idx_expressions_user_created;
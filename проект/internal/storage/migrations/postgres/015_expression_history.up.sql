@@ -0,0 +1,4 @@
+-- Keyset pagination of the expression history: every sort order, by user,
+-- with the ID breaking ties.
+CREATE INDEX IF NOT EXISTS idx_expressions_user_created ON expressions(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_expressions_user_status ON expressions(user_id, status, id);
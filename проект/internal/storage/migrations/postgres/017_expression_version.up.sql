@@ -0,0 +1,3 @@
+-- Every change of an expression bumps its version; updates name the
+-- version they are based on and fail when it has moved on.
+ALTER TABLE expressions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
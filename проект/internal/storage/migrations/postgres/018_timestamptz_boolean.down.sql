@@ -0,0 +1,10 @@
+ALTER TABLE users ALTER COLUMN is_admin DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN is_admin TYPE INTEGER USING CASE WHEN is_admin THEN 1 ELSE 0 END;
+ALTER TABLE users ALTER COLUMN is_admin SET DEFAULT 0;
+
+ALTER TABLE wasm_modules ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+ALTER TABLE functions ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN finished_at TYPE TIMESTAMP USING finished_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN started_at TYPE TIMESTAMP USING started_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
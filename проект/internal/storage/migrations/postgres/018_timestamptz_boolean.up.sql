@@ -0,0 +1,12 @@
+-- Timestamps are points in time, written and compared in UTC whatever the
+-- time zone of the session. Existing values were written in UTC.
+ALTER TABLE expressions ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN started_at TYPE TIMESTAMPTZ USING started_at AT TIME ZONE 'UTC';
+ALTER TABLE expressions ALTER COLUMN finished_at TYPE TIMESTAMPTZ USING finished_at AT TIME ZONE 'UTC';
+ALTER TABLE functions ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+ALTER TABLE wasm_modules ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE users ALTER COLUMN is_admin DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN is_admin TYPE BOOLEAN USING is_admin <> 0;
+ALTER TABLE users ALTER COLUMN is_admin SET DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Bindings made by multi-statement expressions, stored as JSON.
+ALTER TABLE expressions ADD COLUMN assignments TEXT;
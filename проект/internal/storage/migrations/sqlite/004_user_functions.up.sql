@@ -0,0 +1,21 @@
+-- Every edit of a user function is a new version; expressions pin the
+-- versions they were submitted with.
+CREATE TABLE IF NOT EXISTS functions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    params TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id),
+    UNIQUE(user_id, name, version)
+);
+
+CREATE TABLE IF NOT EXISTS expression_functions (
+    expression_id INTEGER NOT NULL,
+    function_id INTEGER NOT NULL,
+    PRIMARY KEY(expression_id, function_id),
+    FOREIGN KEY(expression_id) REFERENCES expressions(id),
+    FOREIGN KEY(function_id) REFERENCES functions(id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wasm_modules;
+ALTER TABLE users DROP COLUMN is_admin;
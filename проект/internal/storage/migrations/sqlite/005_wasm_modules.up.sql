@@ -0,0 +1,13 @@
+-- Administrators may upload WebAssembly modules with custom functions.
+ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS wasm_modules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    binary BLOB NOT NULL,
+    checksum TEXT NOT NULL,
+    exports TEXT NOT NULL,
+    uploaded_by INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(uploaded_by) REFERENCES users(id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE expressions DROP COLUMN result_digits;
+ALTER TABLE expressions DROP COLUMN result_exact;
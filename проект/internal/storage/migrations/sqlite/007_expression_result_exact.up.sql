@@ -0,0 +1,3 @@
+-- Exact decimal digits of integer results that do not fit into a float64.
+ALTER TABLE expressions ADD COLUMN result_exact TEXT;
+ALTER TABLE expressions ADD COLUMN result_digits INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Standard uncertainty of measured results; the value stays in result.
+ALTER TABLE expressions ADD COLUMN result_uncertainty REAL NOT NULL DEFAULT 0;
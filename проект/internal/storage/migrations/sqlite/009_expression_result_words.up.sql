@@ -0,0 +1,2 @@
+-- Result written out in words, when the request asked for it.
+ALTER TABLE expressions ADD COLUMN result_words TEXT;
@@ -0,0 +1,4 @@
+-- Expression dialects: the one each expression was written in and the
+-- default of every user.
+ALTER TABLE users ADD COLUMN dialect TEXT NOT NULL DEFAULT 'standard';
+ALTER TABLE expressions ADD COLUMN dialect TEXT NOT NULL DEFAULT 'standard';
@@ -0,0 +1,6 @@
+ALTER TABLE expressions DROP COLUMN attempts;
+ALTER TABLE expressions DROP COLUMN agent;
+ALTER TABLE expressions DROP COLUMN duration_ms;
+ALTER TABLE expressions DROP COLUMN finished_at;
+ALTER TABLE expressions DROP COLUMN started_at;
+ALTER TABLE expressions DROP COLUMN updated_at;
@@ -0,0 +1,10 @@
+-- When an expression was last changed, started and finished, how long the
+-- evaluation took, which agent computed it and how many times it was
+-- tried. Existing expressions were last changed when they were created.
+ALTER TABLE expressions ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE expressions ADD COLUMN started_at TIMESTAMP;
+ALTER TABLE expressions ADD COLUMN finished_at TIMESTAMP;
+ALTER TABLE expressions ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE expressions ADD COLUMN agent TEXT;
+ALTER TABLE expressions ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+UPDATE expressions SET updated_at = created_at;
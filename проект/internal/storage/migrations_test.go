@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// newTestStorage opens an empty SQLite database in a temporary directory.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "calc.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func mustExec(t *testing.T, s *Storage, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := s.db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// appliedOf lists the versions of the applied migrations.
+func appliedOf(t *testing.T, s *Storage) []int {
+	t.Helper()
+	applied, err := s.getAppliedMigrations()
+	if err != nil {
+		t.Fatalf("getAppliedMigrations: %v", err)
+	}
+	return appliedVersions(applied)
+}
+
+func TestReadMigrations(t *testing.T) {
+	up := &fstest.MapFile{Data: []byte("CREATE TABLE t (id INTEGER);")}
+	down := &fstest.MapFile{Data: []byte("DROP TABLE t;")}
+
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		// err is the error expected, by sentinel or by text.
+		err error
+		msg string
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"m/010_later.up.sql":   up,
+				"m/010_later.down.sql": down,
+				"m/002_first.up.sql":   up,
+				"m/002_first.down.sql": down,
+				"m/README.md":          up,
+			},
+			versions: []int{2, 10},
+		},
+		{
+			name:  "invalid name",
+			files: fstest.MapFS{"m/2_First.up.sql": up},
+			err:   ErrInvalidMigrationName,
+		},
+		{
+			name: "one version, two names",
+			files: fstest.MapFS{
+				"m/001_a.up.sql":   up,
+				"m/001_b.down.sql": down,
+			},
+			err: ErrInvalidMigrationName,
+		},
+		{
+			name:  "no down script",
+			files: fstest.MapFS{"m/001_a.up.sql": up},
+			msg:   "needs both an up and a down script",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := readMigrations(tt.files, "m")
+			if tt.err != nil || tt.msg != "" {
+				if err == nil || tt.err != nil && !errors.Is(err, tt.err) || !strings.Contains(err.Error(), tt.msg) {
+					t.Fatalf("readMigrations = %v, want %v%s", err, tt.err, tt.msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readMigrations: %v", err)
+			}
+			if len(migrations) != len(tt.versions) {
+				t.Fatalf("readMigrations = %+v, want versions %v", migrations, tt.versions)
+			}
+			for i, m := range migrations {
+				if m.Version != tt.versions[i] || m.Up == "" || m.Down == "" || m.Checksum != checksum(m.Up) {
+					t.Errorf("migration %d = %+v", i, m)
+				}
+			}
+		})
+	}
+}
+
+func TestChecksumIgnoresLineEndings(t *testing.T) {
+	if checksum("CREATE TABLE t (\r\n  id INTEGER\r\n);\r\n") != checksum("CREATE TABLE t (\n  id INTEGER\n);\n") {
+		t.Error("checksums of CRLF and LF scripts differ")
+	}
+	if checksum("DROP TABLE a;") == checksum("DROP TABLE b;") {
+		t.Error("checksums of different scripts are equal")
+	}
+}
+
+func TestMigrateTo(t *testing.T) {
+	s := newTestStorage(t)
+	known, err := s.loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	last := known[len(known)-1].Version
+
+	tests := []struct {
+		version int
+		applied int
+		err     error
+	}{
+		{version: 5, applied: 5},
+		{version: last, applied: len(known)},
+		{version: 2, applied: 2},
+		{version: last + 1, applied: 2, err: ErrUnknownMigration},
+		{version: 0, applied: 0},
+	}
+	for _, tt := range tests {
+		err := s.MigrateTo(tt.version)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("MigrateTo(%d) = %v, want %v", tt.version, err, tt.err)
+		}
+		if got := appliedOf(t, s); len(got) != tt.applied {
+			t.Errorf("after MigrateTo(%d) applied %v, want %d migrations", tt.version, got, tt.applied)
+		}
+	}
+}
+
+func TestModifiedMigration(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	mustExec(t, s, "UPDATE schema_migrations SET checksum = ? WHERE version = ?", "edited", 1)
+
+	if err := s.Migrate(); !errors.Is(err, ErrMigrationModified) {
+		t.Errorf("Migrate = %v, want ErrMigrationModified", err)
+	}
+	if err := s.MigrateDown(1); !errors.Is(err, ErrMigrationModified) {
+		t.Errorf("MigrateDown = %v, want ErrMigrationModified", err)
+	}
+	statuses, err := s.MigrationStatuses()
+	if err != nil {
+		t.Fatalf("MigrationStatuses: %v", err)
+	}
+	for _, st := range statuses {
+		if st.Modified != (st.Version == 1) {
+			t.Errorf("migration %d modified = %v", st.Version, st.Modified)
+		}
+	}
+}
+
+func TestRedoMigration(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	versions := appliedOf(t, s)
+	last := versions[len(versions)-1]
+
+	// An edited last migration is what redoing is for.
+	mustExec(t, s, "UPDATE schema_migrations SET checksum = ? WHERE version = ?", "edited", last)
+	if err := s.RedoMigration(); err != nil {
+		t.Fatalf("RedoMigration: %v", err)
+	}
+	if got := appliedOf(t, s); len(got) != len(versions) {
+		t.Errorf("applied after redo = %v, want %v", got, versions)
+	}
+	if err := s.Migrate(); err != nil {
+		t.Errorf("Migrate after redo: %v", err)
+	}
+	if err := s.VerifySchema(); err != nil {
+		t.Errorf("VerifySchema after redo: %v", err)
+	}
+}
+
+func TestMissingMigration(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	// A newer server applied a migration this build does not know.
+	mustExec(t, s, "INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)", 999, "future", "sum")
+
+	if err := s.Migrate(); err != nil {
+		t.Errorf("Migrate with a newer migration applied: %v", err)
+	}
+	if err := s.MigrateDown(1); !errors.Is(err, ErrMigrationMissing) {
+		t.Errorf("MigrateDown = %v, want ErrMigrationMissing", err)
+	}
+	statuses, err := s.MigrationStatuses()
+	if err != nil {
+		t.Fatalf("MigrationStatuses: %v", err)
+	}
+	st := statuses[len(statuses)-1]
+	if st.Version != 999 || st.Name != "future" || !st.Applied || !st.Missing {
+		t.Errorf("status of the unknown migration = %+v", st)
+	}
+}
+
+func TestLegacyMigrationsGetChecksums(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MigrateTo(3); err != nil {
+		t.Fatalf("MigrateTo: %v", err)
+	}
+	// Migrations applied before checksums were recorded.
+	mustExec(t, s, "UPDATE schema_migrations SET name = '', checksum = ''")
+
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	applied, err := s.getAppliedMigrations()
+	if err != nil {
+		t.Fatalf("getAppliedMigrations: %v", err)
+	}
+	known, err := s.loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	for _, m := range known {
+		a := applied[m.Version]
+		if a.name != m.Name || a.checksum != m.Checksum {
+			t.Errorf("migration %d recorded as %q, %q; want %q, %q", m.Version, a.name, a.checksum, m.Name, m.Checksum)
+		}
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"sqlite", "postgres"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"001_initial.up.sql", "001_initial.down.sql"} {
+			if err := os.WriteFile(filepath.Join(dir, name, file), []byte("SELECT 1;"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "postgres", "002_extra.up.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "postgres", "002_extra.down.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateMigration(dir, "Add-Column"); !errors.Is(err, ErrInvalidMigrationName) {
+		t.Errorf("CreateMigration with an invalid name = %v, want ErrInvalidMigrationName", err)
+	}
+
+	paths, err := CreateMigration(dir, "add_column")
+	if err != nil {
+		t.Fatalf("CreateMigration: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "sqlite", "003_add_column.up.sql"),
+		filepath.Join(dir, "sqlite", "003_add_column.down.sql"),
+		filepath.Join(dir, "postgres", "003_add_column.up.sql"),
+		filepath.Join(dir, "postgres", "003_add_column.down.sql"),
+	}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Fatalf("CreateMigration = %v, want %v", paths, want)
+	}
+	for _, name := range []string{"sqlite", "postgres"} {
+		migrations, err := readMigrations(os.DirFS(dir), name)
+		if err != nil {
+			t.Fatalf("readMigrations(%s): %v", name, err)
+		}
+		if m := migrations[len(migrations)-1]; m.Version != 3 || m.Name != "add_column" {
+			t.Errorf("last %s migration = %+v", name, m)
+		}
+	}
+}
+
+func TestLifecycleOfExistingExpressions(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MigrateTo(15); err != nil {
+		t.Fatalf("MigrateTo: %v", err)
+	}
+	mustExec(t, s, "INSERT INTO users (login, password_hash) VALUES (?, ?)", "alice", "hash")
+	mustExec(t, s, "INSERT INTO expressions (user_id, expression, status, result, seed) VALUES (?, ?, ?, ?, ?)",
+		1, "1+1", StatusCompleted, 2, 1)
+
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	e, err := s.GetExpression(1, 1)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+	// Expressions saved before the lifecycle was recorded were last
+	// changed when they were created and have no attempts on record.
+	if !e.UpdatedAt.Equal(e.CreatedAt) || !e.StartedAt.IsZero() || !e.FinishedAt.IsZero() {
+		t.Errorf("created %v, updated %v, started %v, finished %v", e.CreatedAt, e.UpdatedAt, e.StartedAt, e.FinishedAt)
+	}
+	if e.Status != StatusCompleted || e.Result != 2 || e.Attempts != 0 || e.Duration != 0 || e.Agent != "" || e.Version != 1 {
+		t.Errorf("existing expression = %+v", e)
+	}
+}
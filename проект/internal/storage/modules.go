@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Module is an uploaded WebAssembly module. Exports lists the functions
+// the agent registered from it.
+type Module struct {
+	ID         int64
+	Name       string
+	Binary     []byte
+	Checksum   string
+	Exports    []string
+	UploadedBy int
+	CreatedAt  time.Time
+}
+
+// SaveModule stores a module, replacing an earlier upload with the same name.
+func (s *Storage) SaveModule(name string, binary []byte, exports []string, uploadedBy int) (*Module, error) {
+	sum := sha256.Sum256(binary)
+	module := &Module{
+		Name:       name,
+		Binary:     binary,
+		Checksum:   hex.EncodeToString(sum[:]),
+		Exports:    exports,
+		UploadedBy: uploadedBy,
+	}
+
+	err := s.db.QueryRow(`
+        INSERT INTO wasm_modules (name, "binary", checksum, exports, uploaded_by)
+        VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT(name) DO UPDATE SET
+            "binary" = excluded."binary",
+            checksum = excluded.checksum,
+            exports = excluded.exports,
+            uploaded_by = excluded.uploaded_by,
+            created_at = CURRENT_TIMESTAMP
+        RETURNING id, created_at`,
+		module.Name, module.Binary, module.Checksum, strings.Join(exports, ","), uploadedBy,
+	).Scan(&module.ID, &module.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("module upsert failed: %w", err)
+	}
+
+	return module, nil
+}
+
+func (s *Storage) GetModules() ([]Module, error) {
+	rows, err := s.db.Query(
+		`SELECT id, name, "binary", checksum, exports, uploaded_by, created_at FROM wasm_modules ORDER BY name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("modules query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var modules []Module
+	for rows.Next() {
+		var m Module
+		var exports string
+		if err := rows.Scan(
+			&m.ID,
+			&m.Name,
+			&m.Binary,
+			&m.Checksum,
+			&exports,
+			&m.UploadedBy,
+			&m.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("module scan failed: %w", err)
+		}
+		if exports != "" {
+			m.Exports = strings.Split(exports, ",")
+		}
+		modules = append(modules, m)
+	}
+
+	return modules, rows.Err()
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrUnknownSort   = errors.New("unknown sort")
+)
+
+// Expressions can be listed by creation time or by status; ties are
+// broken by ID, so pages never overlap.
+const (
+	SortCreatedAt = "created_at"
+	SortStatus    = "status"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ExpressionQuery selects a page of the expressions of a user. Zero
+// fields do not filter.
+type ExpressionQuery struct {
+	UserID int
+	Status string
+	// From and To bound the creation time: from From on, before To. They
+	// are compared to the second.
+	From time.Time
+	To   time.Time
+	// Contains is text the expression must contain, case-sensitive.
+	Contains   string
+	Sort       string
+	Descending bool
+	// Limit is the page size, DefaultPageSize when zero and at most
+	// MaxPageSize.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first.
+	// It is only valid with the same sort and order.
+	Cursor string
+}
+
+type ExpressionPage struct {
+	Expressions []Expression
+	// NextCursor fetches the page after this one, empty on the last page.
+	NextCursor string
+}
+
+// cursor is the position after the last expression of a page: its sort
+// value and ID. Clients get it base64-encoded and must not look inside.
+type cursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Value      string `json:"v"`
+	ID         int64  `json:"id"`
+	// time is Value parsed when sorting by creation time.
+	time time.Time
+}
+
+// normalize fills in the defaults of q, checks it and decodes its cursor,
+// nil for the first page.
+func (q *ExpressionQuery) normalize() (*cursor, error) {
+	if q.Sort == "" {
+		q.Sort = SortCreatedAt
+	}
+	if q.Sort != SortCreatedAt && q.Sort != SortStatus {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSort, q.Sort)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	q.From = q.From.Truncate(time.Second)
+	q.To = q.To.Truncate(time.Second)
+
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != q.Sort || c.Descending != q.Descending {
+		return nil, fmt.Errorf("%w: it belongs to another sort order", ErrInvalidCursor)
+	}
+	if c.Sort == SortCreatedAt {
+		if c.time, err = time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// cursorAfter is the cursor of the page following e.
+func (q *ExpressionQuery) cursorAfter(e Expression) string {
+	c := cursor{Sort: q.Sort, Descending: q.Descending, ID: e.ID, Value: e.Status}
+	if q.Sort == SortCreatedAt {
+		c.Value = e.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ListExpressions returns a page of the expressions of a user. Pages are
+// read by keyset, (sort column, id) after the cursor, on the indexes of
+// migration 015.
+func (s *Storage) ListExpressions(q ExpressionQuery) (*ExpressionPage, error) {
+	after, err := q.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"user_id = ?"}
+	args := []interface{}{q.UserID}
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, s.db.engine.timestamp(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, s.db.engine.timestamp(q.To))
+	}
+	if q.Contains != "" {
+		where = append(where, s.db.engine.position+"(expression, ?) > 0")
+		args = append(args, q.Contains)
+	}
+	order, op := "ASC", ">"
+	if q.Descending {
+		order, op = "DESC", "<"
+	}
+	if after != nil {
+		where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", q.Sort, op))
+		if q.Sort == SortCreatedAt {
+			args = append(args, s.db.engine.timestamp(after.time))
+		} else {
+			args = append(args, after.Value)
+		}
+		args = append(args, after.ID)
+	}
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.Query(
+		"SELECT "+expressionColumns+" FROM expressions WHERE "+strings.Join(where, " AND ")+
+			fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", q.Sort, order, order),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("expressions query failed: %w", err)
+	}
+	defer rows.Close()
+
+	page := &ExpressionPage{}
+	for rows.Next() {
+		expr, err := scanExpression(rows)
+		if err != nil {
+			return nil, fmt.Errorf("expression scan failed: %w", err)
+		}
+		page.Expressions = append(page.Expressions, *expr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.paginate(page), nil
+}
+
+// paginate cuts the extra expression fetched to see whether another page
+// follows, and points the cursor at it.
+func (q *ExpressionQuery) paginate(page *ExpressionPage) *ExpressionPage {
+	if len(page.Expressions) > q.Limit {
+		page.Expressions = page.Expressions[:q.Limit]
+		page.NextCursor = q.cursorAfter(page.Expressions[q.Limit-1])
+	}
+	return page
+}
+
+// GetExpression returns an expression of a user, ErrExpressionNotFound
+// when it does not exist or belongs to someone else.
+func (s *Storage) GetExpression(userID int, id int64) (*Expression, error) {
+	expr, err := scanExpression(s.db.QueryRow(
+		"SELECT "+expressionColumns+" FROM expressions WHERE id = ? AND user_id = ?",
+		id, userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExpressionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression query failed: %w", err)
+	}
+	return expr, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	created := time.Date(2026, 10, 18, 12, 30, 0, 500, time.UTC)
+	byTime := ExpressionQuery{}
+	byTime.normalize()
+	byStatusDesc := ExpressionQuery{Sort: SortStatus, Descending: true}
+	byStatusDesc.normalize()
+
+	tests := []struct {
+		name  string
+		query ExpressionQuery
+		sort  string
+		limit int
+		after *cursor
+		err   error
+	}{
+		{name: "defaults", sort: SortCreatedAt, limit: DefaultPageSize},
+		{name: "limit kept", query: ExpressionQuery{Limit: 5}, sort: SortCreatedAt, limit: 5},
+		{name: "limit capped", query: ExpressionQuery{Limit: MaxPageSize + 1}, sort: SortCreatedAt, limit: MaxPageSize},
+		{name: "unknown sort", query: ExpressionQuery{Sort: "id"}, err: ErrUnknownSort},
+		{
+			name:  "time cursor",
+			query: ExpressionQuery{Cursor: byTime.cursorAfter(Expression{ID: 7, CreatedAt: created})},
+			sort:  SortCreatedAt,
+			limit: DefaultPageSize,
+			after: &cursor{Sort: SortCreatedAt, ID: 7, time: created},
+		},
+		{
+			name: "status cursor",
+			query: ExpressionQuery{Sort: SortStatus, Descending: true,
+				Cursor: byStatusDesc.cursorAfter(Expression{ID: 3, Status: StatusError})},
+			sort:  SortStatus,
+			limit: DefaultPageSize,
+			after: &cursor{Sort: SortStatus, Descending: true, Value: StatusError, ID: 3},
+		},
+		{
+			name:  "cursor of another order",
+			query: ExpressionQuery{Sort: SortStatus, Cursor: byStatusDesc.cursorAfter(Expression{ID: 3})},
+			err:   ErrInvalidCursor,
+		},
+		{name: "not base64", query: ExpressionQuery{Cursor: "???"}, err: ErrInvalidCursor},
+		{
+			name:  "not JSON",
+			query: ExpressionQuery{Cursor: base64.RawURLEncoding.EncodeToString([]byte("[1, 2]"))},
+			err:   ErrInvalidCursor,
+		},
+		{
+			name:  "bad time",
+			query: ExpressionQuery{Cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","v":"yesterday","id":1}`))},
+			err:   ErrInvalidCursor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			after, err := q.normalize()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("normalize = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if q.Sort != tt.sort || q.Limit != tt.limit {
+				t.Errorf("sort, limit = %s, %d; want %s, %d", q.Sort, q.Limit, tt.sort, tt.limit)
+			}
+			if (after == nil) != (tt.after == nil) {
+				t.Fatalf("cursor = %+v, want %+v", after, tt.after)
+			}
+			if after == nil {
+				return
+			}
+			if after.Sort != tt.after.Sort || after.Descending != tt.after.Descending || after.ID != tt.after.ID {
+				t.Errorf("cursor = %+v, want %+v", after, tt.after)
+			}
+			if tt.sort == SortStatus && after.Value != tt.after.Value {
+				t.Errorf("cursor value = %q, want %q", after.Value, tt.after.Value)
+			}
+			if !after.time.Equal(tt.after.time) {
+				t.Errorf("cursor time = %v, want %v", after.time, tt.after.time)
+			}
+		})
+	}
+}
+
+func TestNormalizeTruncatesRange(t *testing.T) {
+	q := ExpressionQuery{
+		From: time.Date(2026, 10, 18, 9, 0, 0, 999, time.UTC),
+		To:   time.Date(2026, 10, 19, 9, 0, 1, 1, time.UTC),
+	}
+	if _, err := q.normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if q.From.Nanosecond() != 0 || q.To.Nanosecond() != 0 || q.To.Second() != 1 {
+		t.Errorf("range = %v, %v; want whole seconds", q.From, q.To)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	expressions := func(n int) []Expression {
+		list := make([]Expression, n)
+		for i := range list {
+			list[i] = Expression{ID: int64(i + 1), Status: StatusPending}
+		}
+		return list
+	}
+
+	tests := []struct {
+		name    string
+		fetched int
+		limit   int
+		want    int
+		more    bool
+	}{
+		{name: "empty", fetched: 0, limit: 3, want: 0},
+		{name: "short page", fetched: 2, limit: 3, want: 2},
+		{name: "full last page", fetched: 3, limit: 3, want: 3},
+		{name: "more follow", fetched: 4, limit: 3, want: 3, more: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := ExpressionQuery{Sort: SortStatus, Limit: tt.limit}
+			page := q.paginate(&ExpressionPage{Expressions: expressions(tt.fetched)})
+			if len(page.Expressions) != tt.want {
+				t.Errorf("page of %d expressions, want %d", len(page.Expressions), tt.want)
+			}
+			if (page.NextCursor != "") != tt.more {
+				t.Fatalf("NextCursor = %q, want one: %v", page.NextCursor, tt.more)
+			}
+			if !tt.more {
+				return
+			}
+			q.Cursor = page.NextCursor
+			after, err := q.normalize()
+			if err != nil {
+				t.Fatalf("normalize of the next cursor: %v", err)
+			}
+			if after.ID != int64(tt.limit) {
+				t.Errorf("next cursor after expression %d, want %d", after.ID, tt.limit)
+			}
+		})
+	}
+}
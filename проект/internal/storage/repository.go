@@ -0,0 +1,59 @@
+package storage
+
+// Repository is what the server keeps: users, their expressions and the
+// queue of pending ones, user functions and WebAssembly modules. Storage
+// keeps it in SQLite or PostgreSQL and Memory in memory; both pass the
+// conformance suite in storagetest.
+type Repository interface {
+	// CreateUser adds a user and returns its ID, ErrUserExists when the
+	// login is taken.
+	CreateUser(login, passwordHash string) (int64, error)
+	// GetUserByLogin and GetUserByID return ErrUserNotFound for unknown
+	// users.
+	GetUserByLogin(login string) (*User, error)
+	GetUserByID(id int) (*User, error)
+	SetUserDialect(userID int, dialect string) error
+
+	// SaveExpression queues a new pending expression, see
+	// Storage.SaveExpression.
+	SaveExpression(userID int, expr, notation, dialect string, seed *int64, functionIDs ...int64) (int64, error)
+	// StartExpression moves a pending or queued expression to processing,
+	// counts the attempt, stamps its start and returns the new version.
+	StartExpression(id int64) (int, error)
+	// TransitionExpression moves an expression at a version to another
+	// state and returns the new version.
+	TransitionExpression(id int64, version int, status string) (int, error)
+	// UpdateExpressionStatus records the outcome of an expression at a
+	// version and stamps its finish.
+	//
+	// All three return ErrExpressionNotFound for unknown expressions, a
+	// *TransitionError when the state may not follow the current one and
+	// ErrVersionConflict when the expression changed since the version.
+	UpdateExpressionStatus(id int64, version int, res ExpressionResult) error
+	// GetUserExpressions returns the expressions of a user, oldest first.
+	GetUserExpressions(userID int) ([]Expression, error)
+	// ListExpressions returns a page of the expressions of a user, see
+	// ExpressionQuery.
+	ListExpressions(q ExpressionQuery) (*ExpressionPage, error)
+	// GetExpression returns an expression of a user, ErrExpressionNotFound
+	// when it is not theirs.
+	GetExpression(userID int, id int64) (*Expression, error)
+	// GetPendingExpressions returns the queue: the ID, user and text of
+	// every pending expression, oldest first.
+	GetPendingExpressions() ([]Expression, error)
+
+	SaveFunction(userID int, name string, params []string, body string) (*Function, error)
+	GetUserFunctions(userID int) ([]Function, error)
+	GetFunctionVersions(userID int, name string) ([]Function, error)
+	GetExpressionFunctions(exprID int64) ([]Function, error)
+
+	SaveModule(name string, binary []byte, exports []string, uploadedBy int) (*Module, error)
+	GetModules() ([]Module, error)
+
+	Close() error
+}
+
+var (
+	_ Repository = (*Storage)(nil)
+	_ Repository = (*Memory)(nil)
+)
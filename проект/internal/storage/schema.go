@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrSchemaDrift is the error of a database whose schema differs from the
+// one its migrations create; errors.As finds the *SchemaDriftError.
+var ErrSchemaDrift = errors.New("schema drift")
+
+// verifySchemaName is the PostgreSQL schema the expected schema is built
+// in, inside a transaction that is rolled back.
+const verifySchemaName = "schema_verify"
+
+// Drift is one difference between the live schema and the expected one.
+type Drift struct {
+	// Kind is what differs: "missing table", "extra column", "type
+	// mismatch" and so on.
+	Kind  string
+	Table string
+	// Object is the column, index or foreign key, empty for tables.
+	Object   string
+	Expected string
+	Actual   string
+}
+
+func (d Drift) String() string {
+	s := d.Kind + " " + d.Table
+	if d.Object != "" {
+		s = d.Kind + " " + d.Object + " on " + d.Table
+	}
+	if d.Expected != "" || d.Actual != "" {
+		s += fmt.Sprintf(": expected %s, got %s", d.Expected, d.Actual)
+	}
+	return s
+}
+
+// SchemaDriftError lists the drift VerifySchema found.
+type SchemaDriftError struct {
+	Drift []Drift
+}
+
+func (e *SchemaDriftError) Error() string {
+	lines := make([]string, len(e.Drift))
+	for i, d := range e.Drift {
+		lines[i] = d.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrSchemaDrift, strings.Join(lines, "; "))
+}
+
+func (e *SchemaDriftError) Unwrap() error { return ErrSchemaDrift }
+
+type column struct {
+	typ     string
+	notNull bool
+}
+
+type index struct {
+	columns string
+	unique  bool
+}
+
+type table struct {
+	columns map[string]column
+	indexes map[string]index
+	// foreignKeys are keyed by "columns -> table(columns)".
+	foreignKeys map[string]bool
+}
+
+type schema map[string]*table
+
+func (s schema) table(name string) *table {
+	t := s[name]
+	if t == nil {
+		t = &table{
+			columns:     make(map[string]column),
+			indexes:     make(map[string]index),
+			foreignKeys: make(map[string]bool),
+		}
+		s[name] = t
+	}
+	return t
+}
+
+// VerifySchema compares the tables, columns, types, indexes and foreign
+// keys of the database with the ones its applied migrations create, and
+// returns a *SchemaDriftError listing every difference.
+func (s *Storage) VerifySchema() error {
+	if err := s.createMigrationsTable(); err != nil {
+		return fmt.Errorf("create migrations table failed: %w", err)
+	}
+	known, err := s.loadMigrations()
+	if err != nil {
+		return fmt.Errorf("load migrations failed: %w", err)
+	}
+	applied, err := s.getAppliedMigrations()
+	if err != nil {
+		return fmt.Errorf("get applied migrations failed: %w", err)
+	}
+	var scripts []string
+	for _, version := range appliedVersions(applied) {
+		m := findMigration(known, version)
+		if m == nil {
+			return fmt.Errorf("%w: %d", ErrMigrationMissing, version)
+		}
+		scripts = append(scripts, m.Up)
+	}
+
+	expected, err := s.expectedSchema(scripts)
+	if err != nil {
+		return fmt.Errorf("build expected schema failed: %w", err)
+	}
+	live, err := inspectSchema(s.db, s.db.engine, "")
+	if err != nil {
+		return fmt.Errorf("inspect schema failed: %w", err)
+	}
+
+	if drift := compareSchemas(expected, live); len(drift) > 0 {
+		return &SchemaDriftError{Drift: drift}
+	}
+	return nil
+}
+
+// expectedSchema runs the migration scripts on an empty database and
+// inspects the result: an in-memory database for SQLite, a scratch schema
+// in a transaction that is rolled back for PostgreSQL.
+func (s *Storage) expectedSchema(scripts []string) (schema, error) {
+	if s.db.engine == postgresEngine {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("CREATE SCHEMA " + verifySchemaName); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("SET LOCAL search_path TO " + verifySchemaName); err != nil {
+			return nil, err
+		}
+		for _, script := range scripts {
+			if _, err := tx.Exec(script); err != nil {
+				return nil, err
+			}
+		}
+		return inspectSchema(tx, postgresEngine, verifySchemaName)
+	}
+
+	mem, err := sql.Open(sqliteEngine.driver, ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer mem.Close()
+	// Every connection to :memory: is a database of its own.
+	mem.SetMaxOpenConns(1)
+	db := &database{DB: mem, engine: sqliteEngine}
+	for _, script := range scripts {
+		if _, err := db.Exec(script); err != nil {
+			return nil, err
+		}
+	}
+	return inspectSchema(db, sqliteEngine, "")
+}
+
+// querier is a database or a transaction.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// inspectSchema reads the schema of a database, leaving out the tables of
+// the migrations themselves. For PostgreSQL name is the schema to read,
+// the current one when empty.
+func inspectSchema(q querier, e engine, name string) (schema, error) {
+	if e == postgresEngine {
+		return inspectPostgres(q, name)
+	}
+	return inspectSQLite(q)
+}
+
+func inspectSQLite(q querier) (schema, error) {
+	var names []string
+	if err := queryEach(q, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		names = append(names, name)
+		return nil
+	}, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"); err != nil {
+		return nil, err
+	}
+
+	s := make(schema)
+	for _, name := range names {
+		if isMigrationsTable(name) {
+			continue
+		}
+		t := s.table(name)
+
+		if err := queryEach(q, func(rows *sql.Rows) error {
+			var column, typ string
+			var notNull, pk bool
+			if err := rows.Scan(&column, &typ, &notNull, &pk); err != nil {
+				return err
+			}
+			t.columns[column] = columnOf(typ, notNull || pk)
+			return nil
+		}, `SELECT name, type, "notnull", pk > 0 FROM pragma_table_info(?)`, name); err != nil {
+			return nil, err
+		}
+
+		var indexes []string
+		unique := make(map[string]bool)
+		if err := queryEach(q, func(rows *sql.Rows) error {
+			var name string
+			var isUnique bool
+			if err := rows.Scan(&name, &isUnique); err != nil {
+				return err
+			}
+			indexes = append(indexes, name)
+			unique[name] = isUnique
+			return nil
+		}, `SELECT name, "unique" FROM pragma_index_list(?) WHERE origin <> 'pk'`, name); err != nil {
+			return nil, err
+		}
+		for _, idx := range indexes {
+			var columns []string
+			if err := queryEach(q, func(rows *sql.Rows) error {
+				var column string
+				if err := rows.Scan(&column); err != nil {
+					return err
+				}
+				columns = append(columns, column)
+				return nil
+			}, "SELECT name FROM pragma_index_info(?) ORDER BY seqno", idx); err != nil {
+				return nil, err
+			}
+			t.indexes[idx] = index{columns: strings.Join(columns, ", "), unique: unique[idx]}
+		}
+
+		keys := make(map[int]*foreignKey)
+		if err := queryEach(q, func(rows *sql.Rows) error {
+			var id int
+			var ref, from string
+			var to sql.NullString
+			if err := rows.Scan(&id, &ref, &from, &to); err != nil {
+				return err
+			}
+			if keys[id] == nil {
+				keys[id] = &foreignKey{ref: ref}
+			}
+			keys[id].add(from, to.String)
+			return nil
+		}, `SELECT id, "table", "from", "to" FROM pragma_foreign_key_list(?) ORDER BY id, seq`, name); err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			t.foreignKeys[k.String()] = true
+		}
+	}
+	return s, nil
+}
+
+func inspectPostgres(q querier, name string) (schema, error) {
+	where := "= current_schema()"
+	var args []interface{}
+	if name != "" {
+		where = "= ?"
+		args = []interface{}{name}
+	}
+
+	s := make(schema)
+	if err := queryEach(q, func(rows *sql.Rows) error {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		if !isMigrationsTable(table) {
+			s.table(table)
+		}
+		return nil
+	}, "SELECT table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND table_schema "+where, args...); err != nil {
+		return nil, err
+	}
+
+	if err := queryEach(q, func(rows *sql.Rows) error {
+		var table, name, typ, nullable string
+		if err := rows.Scan(&table, &name, &typ, &nullable); err != nil {
+			return err
+		}
+		if t, ok := s[table]; ok {
+			t.columns[name] = columnOf(typ, nullable == "NO")
+		}
+		return nil
+	}, "SELECT table_name, column_name, data_type, is_nullable FROM information_schema.columns WHERE table_schema "+where, args...); err != nil {
+		return nil, err
+	}
+
+	indexColumns := make(map[[2]string][]string)
+	if err := queryEach(q, func(rows *sql.Rows) error {
+		var table, name, column string
+		var unique bool
+		if err := rows.Scan(&table, &name, &unique, &column); err != nil {
+			return err
+		}
+		t, ok := s[table]
+		if !ok {
+			return nil
+		}
+		key := [2]string{table, name}
+		indexColumns[key] = append(indexColumns[key], column)
+		t.indexes[name] = index{columns: strings.Join(indexColumns[key], ", "), unique: unique}
+		return nil
+	}, `SELECT t.relname, i.relname, ix.indisunique, a.attname
+        FROM pg_index ix
+        JOIN pg_class i ON i.oid = ix.indexrelid
+        JOIN pg_class t ON t.oid = ix.indrelid
+        JOIN pg_namespace n ON n.oid = t.relnamespace
+        JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord) ON true
+        JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+        WHERE NOT ix.indisprimary AND n.nspname `+where+`
+        ORDER BY t.relname, i.relname, k.ord`, args...); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[[2]string]*foreignKey)
+	if err := queryEach(q, func(rows *sql.Rows) error {
+		var table, name, column, ref, refColumn string
+		if err := rows.Scan(&table, &name, &column, &ref, &refColumn); err != nil {
+			return err
+		}
+		key := [2]string{table, name}
+		if keys[key] == nil {
+			keys[key] = &foreignKey{ref: ref}
+		}
+		keys[key].add(column, refColumn)
+		return nil
+	}, `SELECT t.relname, c.conname, a.attname, rt.relname, ra.attname
+        FROM pg_constraint c
+        JOIN pg_class t ON t.oid = c.conrelid
+        JOIN pg_class rt ON rt.oid = c.confrelid
+        JOIN pg_namespace n ON n.oid = t.relnamespace
+        JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord) ON true
+        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+        JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.refattnum
+        WHERE c.contype = 'f' AND n.nspname `+where+`
+        ORDER BY t.relname, c.conname, k.ord`, args...); err != nil {
+		return nil, err
+	}
+	for key, k := range keys {
+		if t, ok := s[key[0]]; ok {
+			t.foreignKeys[k.String()] = true
+		}
+	}
+	return s, nil
+}
+
+func queryEach(q querier, scan func(rows *sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func isMigrationsTable(name string) bool {
+	return name == migrationsTable || name == migrationsLockTable
+}
+
+func columnOf(typ string, notNull bool) column {
+	return column{typ: strings.ToUpper(typ), notNull: notNull}
+}
+
+type foreignKey struct {
+	ref                 string
+	columns, refColumns []string
+}
+
+func (k *foreignKey) add(column, refColumn string) {
+	k.columns = append(k.columns, column)
+	k.refColumns = append(k.refColumns, refColumn)
+}
+
+func (k *foreignKey) String() string {
+	return fmt.Sprintf("(%s) -> %s(%s)", strings.Join(k.columns, ", "), k.ref, strings.Join(k.refColumns, ", "))
+}
+
+func nullability(notNull bool) string {
+	if notNull {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+func (i index) String() string {
+	if i.unique {
+		return "UNIQUE (" + i.columns + ")"
+	}
+	return "(" + i.columns + ")"
+}
+
+// compareSchemas lists the drift of live from expected, by table.
+func compareSchemas(expected, live schema) []Drift {
+	var drift []Drift
+	for name, want := range expected {
+		got, ok := live[name]
+		if !ok {
+			drift = append(drift, Drift{Kind: "missing table", Table: name})
+			continue
+		}
+
+		for col, w := range want.columns {
+			g, ok := got.columns[col]
+			switch {
+			case !ok:
+				drift = append(drift, Drift{Kind: "missing column", Table: name, Object: col})
+			case g.typ != w.typ:
+				drift = append(drift, Drift{Kind: "type mismatch", Table: name, Object: col, Expected: w.typ, Actual: g.typ})
+			case g.notNull != w.notNull:
+				drift = append(drift, Drift{Kind: "nullability mismatch", Table: name, Object: col,
+					Expected: nullability(w.notNull), Actual: nullability(g.notNull)})
+			}
+		}
+		for col := range got.columns {
+			if _, ok := want.columns[col]; !ok {
+				drift = append(drift, Drift{Kind: "extra column", Table: name, Object: col})
+			}
+		}
+
+		for idx, w := range want.indexes {
+			g, ok := got.indexes[idx]
+			switch {
+			case !ok:
+				drift = append(drift, Drift{Kind: "missing index", Table: name, Object: idx})
+			case g != w:
+				drift = append(drift, Drift{Kind: "index mismatch", Table: name, Object: idx, Expected: w.String(), Actual: g.String()})
+			}
+		}
+		for idx := range got.indexes {
+			if _, ok := want.indexes[idx]; !ok {
+				drift = append(drift, Drift{Kind: "extra index", Table: name, Object: idx})
+			}
+		}
+
+		for fk := range want.foreignKeys {
+			if !got.foreignKeys[fk] {
+				drift = append(drift, Drift{Kind: "missing foreign key", Table: name, Object: fk})
+			}
+		}
+		for fk := range got.foreignKeys {
+			if !want.foreignKeys[fk] {
+				drift = append(drift, Drift{Kind: "extra foreign key", Table: name, Object: fk})
+			}
+		}
+	}
+	for name := range live {
+		if _, ok := expected[name]; !ok {
+			drift = append(drift, Drift{Kind: "extra table", Table: name})
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		a, b := drift[i], drift[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Object < b.Object
+	})
+	return drift
+}
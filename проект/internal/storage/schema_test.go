@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifySchemaDrift(t *testing.T) {
+	tests := []struct {
+		name  string
+		alter string
+		want  Drift
+	}{
+		{
+			name:  "extra table",
+			alter: "CREATE TABLE notes (id INTEGER PRIMARY KEY)",
+			want:  Drift{Kind: "extra table", Table: "notes"},
+		},
+		{
+			name:  "extra column",
+			alter: "ALTER TABLE users ADD COLUMN nickname TEXT",
+			want:  Drift{Kind: "extra column", Table: "users", Object: "nickname"},
+		},
+		{
+			name:  "missing index",
+			alter: "DROP INDEX idx_expressions_user_status",
+			want:  Drift{Kind: "missing index", Table: "expressions", Object: "idx_expressions_user_status"},
+		},
+		{
+			name:  "extra index",
+			alter: "CREATE INDEX idx_users_dialect ON users(dialect)",
+			want:  Drift{Kind: "extra index", Table: "users", Object: "idx_users_dialect"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			if err := s.Migrate(); err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			if err := s.VerifySchema(); err != nil {
+				t.Fatalf("VerifySchema of a migrated database: %v", err)
+			}
+
+			mustExec(t, s, tt.alter)
+			err := s.VerifySchema()
+			var drift *SchemaDriftError
+			if !errors.As(err, &drift) || !errors.Is(err, ErrSchemaDrift) {
+				t.Fatalf("VerifySchema = %v, want a SchemaDriftError", err)
+			}
+			if len(drift.Drift) != 1 || drift.Drift[0] != tt.want {
+				t.Errorf("drift = %+v, want %+v", drift.Drift, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySchemaPartlyMigrated(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.MigrateTo(4); err != nil {
+		t.Fatalf("MigrateTo: %v", err)
+	}
+	// The expected schema is the one of the applied migrations only.
+	if err := s.VerifySchema(); err != nil {
+		t.Errorf("VerifySchema at migration 4: %v", err)
+	}
+}
+
+func TestCompareSchemas(t *testing.T) {
+	// users is the table every case starts from: an ID, a unique login
+	// and a reference to a team.
+	users := func() *table {
+		return &table{
+			columns: map[string]column{
+				"id":      {typ: "integer", notNull: true},
+				"login":   {typ: "text", notNull: true},
+				"team_id": {typ: "integer"},
+			},
+			indexes:     map[string]index{"idx_users_login": {columns: "login", unique: true}},
+			foreignKeys: map[string]bool{"(team_id) -> teams(id)": true},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(live schema)
+		want   []Drift
+	}{
+		{
+			name:   "same",
+			change: func(schema) {},
+		},
+		{
+			name:   "missing table",
+			change: func(live schema) { delete(live, "users") },
+			want:   []Drift{{Kind: "missing table", Table: "users"}},
+		},
+		{
+			name:   "missing column",
+			change: func(live schema) { delete(live["users"].columns, "team_id") },
+			want:   []Drift{{Kind: "missing column", Table: "users", Object: "team_id"}},
+		},
+		{
+			name:   "type mismatch",
+			change: func(live schema) { live["users"].columns["login"] = column{typ: "varchar", notNull: true} },
+			want:   []Drift{{Kind: "type mismatch", Table: "users", Object: "login", Expected: "text", Actual: "varchar"}},
+		},
+		{
+			name:   "nullability mismatch",
+			change: func(live schema) { live["users"].columns["login"] = column{typ: "text"} },
+			want: []Drift{{Kind: "nullability mismatch", Table: "users", Object: "login",
+				Expected: "NOT NULL", Actual: "NULL"}},
+		},
+		{
+			name:   "index mismatch",
+			change: func(live schema) { live["users"].indexes["idx_users_login"] = index{columns: "login"} },
+			want: []Drift{{Kind: "index mismatch", Table: "users", Object: "idx_users_login",
+				Expected: "UNIQUE (login)", Actual: "(login)"}},
+		},
+		{
+			name: "foreign key replaced",
+			change: func(live schema) {
+				live["users"].foreignKeys = map[string]bool{"(team_id) -> groups(id)": true}
+			},
+			// Drift is sorted by table, kind and object.
+			want: []Drift{
+				{Kind: "extra foreign key", Table: "users", Object: "(team_id) -> groups(id)"},
+				{Kind: "missing foreign key", Table: "users", Object: "(team_id) -> teams(id)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := schema{"users": users()}
+			tt.change(live)
+			got := compareSchemas(schema{"users": users()}, live)
+			if len(got) != len(tt.want) {
+				t.Fatalf("compareSchemas = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("drift %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDriftString(t *testing.T) {
+	tests := []struct {
+		drift Drift
+		want  string
+	}{
+		{Drift{Kind: "extra table", Table: "notes"}, "extra table notes"},
+		{Drift{Kind: "missing column", Table: "users", Object: "login"}, "missing column login on users"},
+		{
+			Drift{Kind: "type mismatch", Table: "users", Object: "id", Expected: "integer", Actual: "text"},
+			"type mismatch id on users: expected integer, got text",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.drift.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The states of an expression. It is saved pending, is processing while an
+// agent evaluates it, goes back to queued while no agent is reachable and
+// ends completed, error or cancelled.
+const (
+	StatusPending    = "pending"
+	StatusQueued     = "queued"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusError      = "error"
+	StatusCancelled  = "cancelled"
+)
+
+var (
+	ErrIllegalTransition = errors.New("illegal status transition")
+	// ErrVersionConflict is an update of an expression that was changed
+	// since the version the update is based on was read.
+	ErrVersionConflict = errors.New("expression was changed concurrently")
+)
+
+// transitions lists the states each state may move to. Completed, error
+// and cancelled are final, so a late or duplicate reply of an agent
+// cannot change the outcome.
+var transitions = map[string][]string{
+	StatusPending:    {StatusQueued, StatusProcessing, StatusCancelled},
+	StatusQueued:     {StatusPending, StatusProcessing, StatusCancelled},
+	StatusProcessing: {StatusQueued, StatusCompleted, StatusError, StatusCancelled},
+}
+
+// CanTransition reports whether an expression may move from one state to
+// another.
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether an expression in the state will not change.
+// Unknown states are not final.
+func IsFinal(status string) bool {
+	switch status {
+	case StatusCompleted, StatusError, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// TransitionError is an attempt to move an expression to a state it may
+// not move to; it matches ErrIllegalTransition.
+type TransitionError struct {
+	ID   int64
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: expression %d cannot go from %s to %s", ErrIllegalTransition, e.ID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error { return ErrIllegalTransition }
+
+// checkTransition vets moving expression id, which is in state from at
+// version current, to state to by an update based on version.
+func checkTransition(id int64, from string, current, version int, to string) error {
+	if !CanTransition(from, to) {
+		return &TransitionError{ID: id, From: from, To: to}
+	}
+	if current != version {
+		return versionConflict(id, version)
+	}
+	return nil
+}
+
+func versionConflict(id int64, version int) error {
+	return fmt.Errorf("%w: expression %d is no longer at version %d", ErrVersionConflict, id, version)
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransitions(t *testing.T) {
+	states := []string{StatusPending, StatusQueued, StatusProcessing, StatusCompleted, StatusError, StatusCancelled, "done"}
+	// allowed lists every legal transition; all other pairs are illegal.
+	allowed := map[[2]string]bool{
+		{StatusPending, StatusQueued}:       true,
+		{StatusPending, StatusProcessing}:   true,
+		{StatusPending, StatusCancelled}:    true,
+		{StatusQueued, StatusPending}:       true,
+		{StatusQueued, StatusProcessing}:    true,
+		{StatusQueued, StatusCancelled}:     true,
+		{StatusProcessing, StatusQueued}:    true,
+		{StatusProcessing, StatusCompleted}: true,
+		{StatusProcessing, StatusError}:     true,
+		{StatusProcessing, StatusCancelled}: true,
+	}
+	for _, from := range states {
+		for _, to := range states {
+			if got, want := CanTransition(from, to), allowed[[2]string{from, to}]; got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	tests := []struct {
+		status string
+		final  bool
+	}{
+		{StatusPending, false},
+		{StatusQueued, false},
+		{StatusProcessing, false},
+		{StatusCompleted, true},
+		{StatusError, true},
+		{StatusCancelled, true},
+		{"done", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsFinal(tt.status); got != tt.final {
+			t.Errorf("IsFinal(%q) = %v, want %v", tt.status, got, tt.final)
+		}
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		current int
+		version int
+		to      string
+		err     error
+	}{
+		{name: "legal", from: StatusPending, current: 1, version: 1, to: StatusProcessing},
+		{name: "stale version", from: StatusProcessing, current: 3, version: 2, to: StatusCompleted, err: ErrVersionConflict},
+		{name: "illegal", from: StatusCompleted, current: 3, version: 3, to: StatusError, err: ErrIllegalTransition},
+		// The state is checked first: a final expression stays final
+		// whatever version the update is based on.
+		{name: "illegal and stale", from: StatusCancelled, current: 4, version: 2, to: StatusCompleted, err: ErrIllegalTransition},
+		{name: "unknown state", from: StatusPending, current: 1, version: 1, to: "done", err: ErrIllegalTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransition(7, tt.from, tt.current, tt.version, tt.to)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("checkTransition = %v, want %v", err, tt.err)
+			}
+			var transition *TransitionError
+			if errors.As(err, &transition) && (transition.ID != 7 || transition.From != tt.from || transition.To != tt.to) {
+				t.Errorf("TransitionError = %+v", transition)
+			}
+		})
+	}
+}
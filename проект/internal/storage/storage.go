@@ -1,198 +1,455 @@
-package storage
-
-import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"time"
-
-	_ "github.com/mattn/go-sqlite3"
-)
-
-var (
-	ErrUserExists         = errors.New("user already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrExpressionNotFound = errors.New("expression not found")
-)
-
-type Storage struct {
-	db *sql.DB
-}
-
-type User struct {
-	ID           int
-	Login        string
-	PasswordHash string
-}
-
-type Expression struct {
-	ID         int64
-	UserID     int
-	Expression string
-	Status     string
-	Result     float64
-	CreatedAt  time.Time
-}
-
-func New(path string) (*Storage, error) {
-	db, err := sql.Open("sqlite3", path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("database ping failed: %w", err)
-	}
-
-	return &Storage{db: db}, nil
-}
-
-func (s *Storage) migrate() error {
-	query := `
-    CREATE TABLE IF NOT EXISTS users (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        login TEXT NOT NULL UNIQUE,
-        password_hash TEXT NOT NULL
-    );
-    
-    CREATE TABLE IF NOT EXISTS expressions (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        user_id INTEGER NOT NULL,
-        expression TEXT NOT NULL,
-        status TEXT NOT NULL DEFAULT 'pending',
-        result REAL,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY(user_id) REFERENCES users(id)
-    );
-    
-    CREATE INDEX IF NOT EXISTS idx_expressions_user ON expressions(user_id);
-    
-    `
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *Storage) CreateUser(login, passwordHash string) (int64, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return 0, fmt.Errorf("transaction begin failed: %w", err)
-	}
-	defer tx.Rollback()
-
-	var existingID int
-	err = tx.QueryRow("SELECT id FROM users WHERE login = ?", login).Scan(&existingID)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return 0, fmt.Errorf("user check failed: %w", err)
-	}
-	if existingID > 0 {
-		return 0, ErrUserExists
-	}
-
-	res, err := tx.Exec(
-		"INSERT INTO users (login, password_hash) VALUES (?, ?)",
-		login, passwordHash,
-	)
-	if err != nil {
-		return 0, fmt.Errorf("user insert failed: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("transaction commit failed: %w", err)
-	}
-
-	return res.LastInsertId()
-}
-
-func (s *Storage) GetUserByLogin(login string) (*User, error) {
-	var user User
-	err := s.db.QueryRow(
-		"SELECT id, login, password_hash FROM users WHERE login = ?",
-		login,
-	).Scan(&user.ID, &user.Login, &user.PasswordHash)
-
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrUserNotFound
-	}
-	if err != nil {
-		return nil, fmt.Errorf("user query failed: %w", err)
-	}
-
-	return &user, nil
-}
-
-func (s *Storage) SaveExpression(userID int, expr string) (int64, error) {
-	res, err := s.db.Exec(
-		"INSERT INTO expressions (user_id, expression) VALUES (?, ?)",
-		userID, expr,
-	)
-	if err != nil {
-		return 0, fmt.Errorf("expression insert failed: %w", err)
-	}
-
-	return res.LastInsertId()
-}
-
-func (s *Storage) UpdateExpressionStatus(id int64, status string, result float64) error {
-	_, err := s.db.Exec(
-		"UPDATE expressions SET status = ?, result = ? WHERE id = ?",
-		status, result, id,
-	)
-	return err
-}
-
-func (s *Storage) GetUserExpressions(userID int) ([]Expression, error) {
-	rows, err := s.db.Query(
-		"SELECT id, expression, status, result, created_at FROM expressions WHERE user_id = ?",
-		userID,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("expressions query failed: %w", err)
-	}
-	defer rows.Close()
-	var expressions []Expression
-	for rows.Next() {
-		var expr Expression
-		if err := rows.Scan(
-			&expr.ID,
-			&expr.Expression,
-			&expr.Status,
-			&expr.Result,
-			&expr.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("expression scan failed: %w", err)
-		}
-		expr.UserID = userID
-		expressions = append(expressions, expr)
-	}
-
-	return expressions, nil
-}
-
-func (s *Storage) GetPendingExpressions() ([]Expression, error) {
-	rows, err := s.db.Query(
-		"SELECT id, user_id, expression FROM expressions WHERE status = 'pending'",
-	)
-	if err != nil {
-		return nil, fmt.Errorf("pending expressions query failed: %w", err)
-	}
-	defer rows.Close()
-
-	var expressions []Expression
-	for rows.Next() {
-		var expr Expression
-		if err := rows.Scan(
-			&expr.ID,
-			&expr.UserID,
-			&expr.Expression,
-		); err != nil {
-			return nil, fmt.Errorf("pending expression scan failed: %w", err)
-		}
-		expressions = append(expressions, expr)
-	}
-
-	return expressions, nil
-}
-
-func (s *Storage) Close() error {
-	return s.db.Close()
-}
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserExists         = errors.New("user already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrExpressionNotFound = errors.New("expression not found")
+)
+
+type Storage struct {
+	db *database
+}
+
+type User struct {
+	ID           int
+	Login        string
+	PasswordHash string
+	IsAdmin      bool
+	// Dialect is the expression dialect used when a request names none.
+	Dialect string
+}
+
+type Expression struct {
+	ID         int64
+	UserID     int
+	Expression string
+	// Notation and Dialect are how the expression is written.
+	Notation string
+	Dialect  string
+	// Seed seeds the random functions the expression calls.
+	Seed   int64
+	Status string
+	Result float64
+	// ResultImag is the imaginary part of a complex result.
+	ResultImag float64
+	// ResultExact holds the decimal digits of an integer result too large
+	// for float64, ResultDigits their count.
+	ResultExact  string
+	ResultDigits int
+	// ResultUncertainty is the standard uncertainty of a measured result.
+	ResultUncertainty float64
+	// ResultWords is the result written out in words, if asked for.
+	ResultWords string
+	// ResultISO is the ISO 8601 text of a date, time or duration result.
+	ResultISO string
+	// ResultValue is the JSON encoding of a non-scalar result, empty for
+	// plain numbers.
+	ResultValue string
+	// Assignments is the JSON encoding of the bindings made by a
+	// multi-statement expression.
+	Assignments string
+	// ErrorCode and ErrorMessage say why a failed expression failed.
+	ErrorCode    string
+	ErrorMessage string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// StartedAt is when the last attempt to evaluate the expression began,
+	// FinishedAt when its outcome was recorded; both are zero until then.
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Duration is how long the evaluation took, Agent the agent that
+	// answered and Attempts how many times evaluation was started.
+	Duration time.Duration
+	Agent    string
+	Attempts int
+	// Version counts the changes of the expression; updates are made
+	// against the version they read.
+	Version int
+}
+
+// ExpressionResult is the outcome of an evaluation written back to an
+// expression. The JSON fields are stored as NULL when empty.
+type ExpressionResult struct {
+	Status            string
+	Result            float64
+	ResultImag        float64
+	ResultExact       string
+	ResultDigits      int
+	ResultUncertainty float64
+	ResultWords       string
+	ResultISO         string
+	ResultValue       string
+	Assignments       string
+	ErrorCode         string
+	ErrorMessage      string
+	Duration          time.Duration
+	Agent             string
+}
+
+// New opens the database of a DSN: a postgres:// URL or libpq key=value
+// string for PostgreSQL, otherwise the path of an SQLite file.
+func New(dsn string) (*Storage, error) {
+	e := engineFor(dsn)
+	db, err := sql.Open(e.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("database ping failed: %w", err)
+	}
+
+	return &Storage{db: &database{DB: db, engine: e}}, nil
+}
+
+// DSNFromEnv returns the database configured in the environment:
+// DATABASE_URL, else a PostgreSQL database described by DB_HOST, DB_PORT,
+// DB_USER, DB_PASSWORD, DB_NAME and DB_SSLMODE, else the SQLite file
+// calc.db.
+func DSNFromEnv() string {
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		return url
+	}
+	if os.Getenv("DB_HOST") == "" {
+		return "calc.db"
+	}
+
+	var fields []string
+	for _, v := range []struct{ key, env string }{
+		{"host", "DB_HOST"},
+		{"port", "DB_PORT"},
+		{"user", "DB_USER"},
+		{"password", "DB_PASSWORD"},
+		{"dbname", "DB_NAME"},
+		{"sslmode", "DB_SSLMODE"},
+	} {
+		if value := os.Getenv(v.env); value != "" {
+			fields = append(fields, v.key+"="+value)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func (s *Storage) CreateUser(login, passwordHash string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("transaction begin failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existingID int
+	err = tx.QueryRow("SELECT id FROM users WHERE login = ?", login).Scan(&existingID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("user check failed: %w", err)
+	}
+	if existingID > 0 {
+		return 0, ErrUserExists
+	}
+
+	var id int64
+	err = tx.QueryRow(
+		"INSERT INTO users (login, password_hash) VALUES (?, ?) RETURNING id",
+		login, passwordHash,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("user insert failed: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("transaction commit failed: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetUserByLogin(login string) (*User, error) {
+	return s.getUser("login = ?", login)
+}
+
+func (s *Storage) GetUserByID(id int) (*User, error) {
+	return s.getUser("id = ?", id)
+}
+
+func (s *Storage) getUser(where string, arg interface{}) (*User, error) {
+	var user User
+	err := s.db.QueryRow(
+		"SELECT id, login, password_hash, is_admin, dialect FROM users WHERE "+where,
+		arg,
+	).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.IsAdmin, &user.Dialect)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user query failed: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (s *Storage) SetUserDialect(userID int, dialect string) error {
+	res, err := s.db.Exec("UPDATE users SET dialect = ? WHERE id = ?", dialect, userID)
+	if err != nil {
+		return fmt.Errorf("user update failed: %w", err)
+	}
+	return expectRow(res, ErrUserNotFound)
+}
+
+// SaveExpression stores a new expression together with the versions of
+// the user functions it was submitted with. Without a seed the expression
+// is seeded with its own ID.
+func (s *Storage) SaveExpression(userID int, expr, notation, dialect string, seed *int64, functionIDs ...int64) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("transaction begin failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(
+		`INSERT INTO expressions (user_id, expression, notation, dialect, seed, updated_at)
+        VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP) RETURNING id`,
+		userID, expr, notation, dialect, seed,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("expression insert failed: %w", err)
+	}
+
+	if seed == nil {
+		if _, err := tx.Exec("UPDATE expressions SET seed = id WHERE id = ?", id); err != nil {
+			return 0, fmt.Errorf("expression seed update failed: %w", err)
+		}
+	}
+
+	for _, fnID := range functionIDs {
+		if _, err := tx.Exec(
+			"INSERT INTO expression_functions (expression_id, function_id) VALUES (?, ?)",
+			id, fnID,
+		); err != nil {
+			return 0, fmt.Errorf("expression function insert failed: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("transaction commit failed: %w", err)
+	}
+
+	return id, nil
+}
+
+// StartExpression moves a pending or queued expression to processing,
+// records that an attempt to evaluate it begins and returns its new
+// version.
+func (s *Storage) StartExpression(id int64) (int, error) {
+	version, err := s.checkTransition(id, 0, StatusProcessing)
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.db.Exec(
+		`UPDATE expressions SET status = ?, started_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP,
+        attempts = attempts + 1, version = version + 1 WHERE id = ? AND version = ?`,
+		StatusProcessing, id, version,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("expression update failed: %w", err)
+	}
+	if err := expectRow(result, versionConflict(id, version)); err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// TransitionExpression moves an expression at the given version to
+// another state and returns its new version. It fails with a
+// *TransitionError when the state may not follow the current one and with
+// ErrVersionConflict when the expression has changed since.
+func (s *Storage) TransitionExpression(id int64, version int, status string) (int, error) {
+	if _, err := s.checkTransition(id, version, status); err != nil {
+		return 0, err
+	}
+	result, err := s.db.Exec(
+		`UPDATE expressions SET status = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id = ? AND version = ?`,
+		status, id, version,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("expression update failed: %w", err)
+	}
+	if err := expectRow(result, versionConflict(id, version)); err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// UpdateExpressionStatus records the outcome of an expression at the given
+// version, failing like TransitionExpression.
+func (s *Storage) UpdateExpressionStatus(id int64, version int, res ExpressionResult) error {
+	if _, err := s.checkTransition(id, version, res.Status); err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		`UPDATE expressions SET status = ?, result = ?, result_imag = ?, result_exact = ?, result_digits = ?,
+        result_uncertainty = ?, result_words = ?, result_iso = ?, result_value = ?, assignments = ?,
+        error_code = ?, error_message = ?, duration_ms = ?, agent = ?,
+        finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id = ? AND version = ?`,
+		res.Status, res.Result, res.ResultImag, nullString(res.ResultExact), res.ResultDigits,
+		res.ResultUncertainty, nullString(res.ResultWords), nullString(res.ResultISO), nullString(res.ResultValue),
+		nullString(res.Assignments), nullString(res.ErrorCode), nullString(res.ErrorMessage),
+		res.Duration.Milliseconds(), nullString(res.Agent), id, version,
+	)
+	if err != nil {
+		return fmt.Errorf("expression update failed: %w", err)
+	}
+	return expectRow(result, versionConflict(id, version))
+}
+
+// checkTransition reads the state of an expression and vets moving it to
+// status. A zero version takes the current one, which is returned.
+func (s *Storage) checkTransition(id int64, version int, status string) (int, error) {
+	var from string
+	var current int
+	err := s.db.QueryRow("SELECT status, version FROM expressions WHERE id = ?", id).Scan(&from, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrExpressionNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("expression query failed: %w", err)
+	}
+	if version == 0 {
+		version = current
+	}
+	return version, checkTransition(id, from, current, version, status)
+}
+
+// expectRow returns notFound when an update changed no row.
+func expectRow(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected failed: %w", err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// expressionColumns are the columns scanExpression reads.
+const expressionColumns = `id, user_id, expression, notation, dialect, seed, status, COALESCE(result, 0),
+        result_imag, result_exact, result_digits, result_uncertainty, result_words, result_iso, result_value,
+        assignments, error_code, error_message, created_at, updated_at, started_at, finished_at, duration_ms,
+        agent, attempts, version`
+
+func (s *Storage) GetUserExpressions(userID int) ([]Expression, error) {
+	rows, err := s.db.Query(
+		"SELECT "+expressionColumns+" FROM expressions WHERE user_id = ? ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("expressions query failed: %w", err)
+	}
+	defer rows.Close()
+	var expressions []Expression
+	for rows.Next() {
+		expr, err := scanExpression(rows)
+		if err != nil {
+			return nil, fmt.Errorf("expression scan failed: %w", err)
+		}
+		expressions = append(expressions, *expr)
+	}
+
+	return expressions, rows.Err()
+}
+
+func scanExpression(row scanner) (*Expression, error) {
+	var expr Expression
+	var exact, words, iso, value, assignments, errorCode, errorMessage, agent sql.NullString
+	var updatedAt, startedAt, finishedAt sql.NullTime
+	var durationMs int64
+	if err := row.Scan(
+		&expr.ID,
+		&expr.UserID,
+		&expr.Expression,
+		&expr.Notation,
+		&expr.Dialect,
+		&expr.Seed,
+		&expr.Status,
+		&expr.Result,
+		&expr.ResultImag,
+		&exact,
+		&expr.ResultDigits,
+		&expr.ResultUncertainty,
+		&words,
+		&iso,
+		&value,
+		&assignments,
+		&errorCode,
+		&errorMessage,
+		&expr.CreatedAt,
+		&updatedAt,
+		&startedAt,
+		&finishedAt,
+		&durationMs,
+		&agent,
+		&expr.Attempts,
+		&expr.Version,
+	); err != nil {
+		return nil, err
+	}
+	expr.ResultExact = exact.String
+	expr.ResultWords = words.String
+	expr.ResultISO = iso.String
+	expr.ResultValue = value.String
+	expr.Assignments = assignments.String
+	expr.ErrorCode = errorCode.String
+	expr.ErrorMessage = errorMessage.String
+	expr.UpdatedAt = updatedAt.Time
+	expr.StartedAt = startedAt.Time
+	expr.FinishedAt = finishedAt.Time
+	expr.Duration = time.Duration(durationMs) * time.Millisecond
+	expr.Agent = agent.String
+	return &expr, nil
+}
+
+func (s *Storage) GetPendingExpressions() ([]Expression, error) {
+	rows, err := s.db.Query(
+		"SELECT id, user_id, expression FROM expressions WHERE status = ? ORDER BY id",
+		StatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("pending expressions query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var expressions []Expression
+	for rows.Next() {
+		var expr Expression
+		if err := rows.Scan(
+			&expr.ID,
+			&expr.UserID,
+			&expr.Expression,
+		); err != nil {
+			return nil, fmt.Errorf("pending expression scan failed: %w", err)
+		}
+		expressions = append(expressions, expr)
+	}
+
+	return expressions, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,684 @@
+// Package storagetest is the conformance suite of storage.Repository. Every
+// backend runs it from its own tests:
+//
+//	storagetest.Run(t, func(t *testing.T) storage.Repository {
+//		return storage.NewMemory()
+//	})
+package storagetest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opr1234/calculator/internal/storage"
+)
+
+// Run runs the suite against the repositories open returns. Every subtest
+// opens its own, empty repository and closes it when done.
+func Run(t *testing.T, open func(t *testing.T) storage.Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo storage.Repository)
+	}{
+		{"Users", testUsers},
+		{"UniqueLogins", testUniqueLogins},
+		{"UnknownUser", testUnknownUser},
+		{"Expressions", testExpressions},
+		{"Seed", testSeed},
+		{"StatusTransitions", testStatusTransitions},
+		{"Lifecycle", testLifecycle},
+		{"IllegalTransitions", testIllegalTransitions},
+		{"VersionConflicts", testVersionConflicts},
+		{"Cancel", testCancel},
+		{"Queue", testQueue},
+		{"History", testHistory},
+		{"HistoryFilters", testHistoryFilters},
+		{"GetExpression", testGetExpression},
+		{"Functions", testFunctions},
+		{"Modules", testModules},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := open(t)
+			t.Cleanup(func() { repo.Close() })
+			tt.test(t, repo)
+		})
+	}
+}
+
+func mustCreateUser(t *testing.T, repo storage.Repository, login string) int {
+	t.Helper()
+	id, err := repo.CreateUser(login, "hash-"+login)
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", login, err)
+	}
+	return int(id)
+}
+
+func mustSaveExpression(t *testing.T, repo storage.Repository, userID int, expr string) int64 {
+	t.Helper()
+	id, err := repo.SaveExpression(userID, expr, "infix", "standard", nil)
+	if err != nil {
+		t.Fatalf("SaveExpression(%q): %v", expr, err)
+	}
+	return id
+}
+
+// mustFinish starts an expression and records its outcome.
+func mustFinish(t *testing.T, repo storage.Repository, id int64, res storage.ExpressionResult) {
+	t.Helper()
+	version, err := repo.StartExpression(id)
+	if err != nil {
+		t.Fatalf("StartExpression: %v", err)
+	}
+	if err := repo.UpdateExpressionStatus(id, version, res); err != nil {
+		t.Fatalf("UpdateExpressionStatus: %v", err)
+	}
+}
+
+func testUsers(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+	if alice != 1 || bob != 2 {
+		t.Fatalf("user IDs = %d, %d, want 1, 2", alice, bob)
+	}
+
+	user, err := repo.GetUserByLogin("bob")
+	if err != nil {
+		t.Fatalf("GetUserByLogin: %v", err)
+	}
+	if user.ID != bob || user.Login != "bob" || user.PasswordHash != "hash-bob" {
+		t.Errorf("GetUserByLogin = %+v", user)
+	}
+	if user.IsAdmin || user.Dialect != "standard" {
+		t.Errorf("new user = %+v, want no admin and the standard dialect", user)
+	}
+
+	if err := repo.SetUserDialect(bob, "math"); err != nil {
+		t.Fatalf("SetUserDialect: %v", err)
+	}
+	user, err = repo.GetUserByID(bob)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.Login != "bob" || user.Dialect != "math" {
+		t.Errorf("GetUserByID = %+v, want bob with the math dialect", user)
+	}
+}
+
+func testUniqueLogins(t *testing.T, repo storage.Repository) {
+	mustCreateUser(t, repo, "alice")
+	if _, err := repo.CreateUser("alice", "other"); !errors.Is(err, storage.ErrUserExists) {
+		t.Fatalf("second CreateUser = %v, want ErrUserExists", err)
+	}
+	user, err := repo.GetUserByLogin("alice")
+	if err != nil {
+		t.Fatalf("GetUserByLogin: %v", err)
+	}
+	if user.PasswordHash != "hash-alice" {
+		t.Errorf("password hash = %q, the first user was overwritten", user.PasswordHash)
+	}
+	if id := mustCreateUser(t, repo, "bob"); id != 2 {
+		t.Errorf("ID after a rejected login = %d, want 2", id)
+	}
+}
+
+func testUnknownUser(t *testing.T, repo storage.Repository) {
+	if _, err := repo.GetUserByLogin("nobody"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("GetUserByLogin = %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.GetUserByID(42); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("GetUserByID = %v, want ErrUserNotFound", err)
+	}
+	if err := repo.SetUserDialect(42, "math"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("SetUserDialect = %v, want ErrUserNotFound", err)
+	}
+}
+
+func testExpressions(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+
+	first := mustSaveExpression(t, repo, alice, "1+1")
+	if _, err := repo.SaveExpression(bob, "+ 2 2", "prefix", "math", nil); err != nil {
+		t.Fatalf("SaveExpression: %v", err)
+	}
+	second := mustSaveExpression(t, repo, alice, "2*3")
+	if first != 1 || second != 3 {
+		t.Fatalf("expression IDs = %d, %d, want 1, 3", first, second)
+	}
+
+	expressions, err := repo.GetUserExpressions(alice)
+	if err != nil {
+		t.Fatalf("GetUserExpressions: %v", err)
+	}
+	if len(expressions) != 2 || expressions[0].ID != first || expressions[1].ID != second {
+		t.Fatalf("GetUserExpressions = %+v, want expressions %d and %d", expressions, first, second)
+	}
+	e := expressions[0]
+	if e.UserID != alice || e.Expression != "1+1" || e.Status != "pending" {
+		t.Errorf("saved expression = %+v", e)
+	}
+	if e.Notation != "infix" || e.Dialect != "standard" {
+		t.Errorf("notation, dialect = %q, %q, want infix, standard", e.Notation, e.Dialect)
+	}
+	if e.CreatedAt.IsZero() {
+		t.Error("CreatedAt is not set")
+	}
+
+	expressions, err = repo.GetUserExpressions(bob)
+	if err != nil {
+		t.Fatalf("GetUserExpressions: %v", err)
+	}
+	if len(expressions) != 1 || expressions[0].Notation != "prefix" || expressions[0].Dialect != "math" {
+		t.Errorf("GetUserExpressions(bob) = %+v", expressions)
+	}
+
+	expressions, err = repo.GetUserExpressions(42)
+	if err != nil {
+		t.Fatalf("GetUserExpressions: %v", err)
+	}
+	if len(expressions) != 0 {
+		t.Errorf("GetUserExpressions of an unknown user = %+v, want none", expressions)
+	}
+}
+
+func testSeed(t *testing.T, repo storage.Repository) {
+	user := mustCreateUser(t, repo, "alice")
+	seed := int64(7)
+	mustSaveExpression(t, repo, user, "rand()")
+	if _, err := repo.SaveExpression(user, "rand()", "infix", "standard", &seed); err != nil {
+		t.Fatalf("SaveExpression: %v", err)
+	}
+
+	expressions, err := repo.GetUserExpressions(user)
+	if err != nil {
+		t.Fatalf("GetUserExpressions: %v", err)
+	}
+	if len(expressions) != 2 {
+		t.Fatalf("GetUserExpressions = %+v, want 2 expressions", expressions)
+	}
+	if got := expressions[0].Seed; got != expressions[0].ID {
+		t.Errorf("default seed = %d, want the expression ID %d", got, expressions[0].ID)
+	}
+	if got := expressions[1].Seed; got != seed {
+		t.Errorf("seed = %d, want %d", got, seed)
+	}
+}
+
+func testStatusTransitions(t *testing.T, repo storage.Repository) {
+	user := mustCreateUser(t, repo, "alice")
+	done := mustSaveExpression(t, repo, user, "1/3")
+	failed := mustSaveExpression(t, repo, user, "1/0")
+
+	mustFinish(t, repo, done, storage.ExpressionResult{
+		Status:      storage.StatusCompleted,
+		Result:      0.5,
+		ResultExact: "1/2",
+		ResultValue: `[1,2]`,
+		Assignments: `{"x":1}`,
+	})
+	mustFinish(t, repo, failed, storage.ExpressionResult{
+		Status:       storage.StatusError,
+		ErrorCode:    "DIVISION_BY_ZERO",
+		ErrorMessage: "division by zero",
+	})
+	if err := repo.UpdateExpressionStatus(42, 1, storage.ExpressionResult{Status: storage.StatusCompleted}); !errors.Is(err, storage.ErrExpressionNotFound) {
+		t.Errorf("UpdateExpressionStatus of an unknown expression = %v, want ErrExpressionNotFound", err)
+	}
+
+	expressions, err := repo.GetUserExpressions(user)
+	if err != nil {
+		t.Fatalf("GetUserExpressions: %v", err)
+	}
+	if len(expressions) != 2 {
+		t.Fatalf("GetUserExpressions = %+v, want 2 expressions", expressions)
+	}
+	e := expressions[0]
+	if e.Status != "completed" || e.Result != 0.5 || e.ResultExact != "1/2" {
+		t.Errorf("completed expression = %+v", e)
+	}
+	if e.ResultValue != `[1,2]` || e.Assignments != `{"x":1}` {
+		t.Errorf("value, assignments = %q, %q", e.ResultValue, e.Assignments)
+	}
+	e = expressions[1]
+	if e.Status != "error" || e.ErrorCode != "DIVISION_BY_ZERO" || e.ErrorMessage != "division by zero" {
+		t.Errorf("failed expression = %+v", e)
+	}
+}
+
+func testLifecycle(t *testing.T, repo storage.Repository) {
+	user := mustCreateUser(t, repo, "alice")
+	id := mustSaveExpression(t, repo, user, "2^10")
+
+	e, err := repo.GetExpression(user, id)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+	if e.Status != storage.StatusPending || e.Version != 1 {
+		t.Errorf("new expression status, version = %s, %d; want pending, 1", e.Status, e.Version)
+	}
+	if e.UpdatedAt.IsZero() || !e.StartedAt.IsZero() || !e.FinishedAt.IsZero() || e.Attempts != 0 {
+		t.Errorf("new expression = %+v, want only created and updated times", e)
+	}
+
+	// The first attempt fails and the expression is queued for a retry.
+	version, err := repo.StartExpression(id)
+	if err != nil {
+		t.Fatalf("StartExpression: %v", err)
+	}
+	if version, err = repo.TransitionExpression(id, version, storage.StatusQueued); err != nil {
+		t.Fatalf("TransitionExpression to queued: %v", err)
+	}
+	if version, err = repo.StartExpression(id); err != nil {
+		t.Fatalf("StartExpression of a queued expression: %v", err)
+	}
+	if version != 4 {
+		t.Errorf("version after three transitions = %d, want 4", version)
+	}
+	e, err = repo.GetExpression(user, id)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+	if e.Status != storage.StatusProcessing || e.Version != version {
+		t.Errorf("started expression status, version = %s, %d; want processing, %d", e.Status, e.Version, version)
+	}
+	if e.StartedAt.IsZero() || !e.FinishedAt.IsZero() || e.Attempts != 2 {
+		t.Errorf("started expression = %+v, want a start time and 2 attempts", e)
+	}
+
+	if err := repo.UpdateExpressionStatus(id, version, storage.ExpressionResult{
+		Status:   storage.StatusCompleted,
+		Result:   1024,
+		Duration: 1500*time.Millisecond + 300*time.Microsecond,
+		Agent:    "agent-1",
+	}); err != nil {
+		t.Fatalf("UpdateExpressionStatus: %v", err)
+	}
+	e, err = repo.GetExpression(user, id)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+	if e.FinishedAt.IsZero() || e.FinishedAt.Before(e.StartedAt) || e.UpdatedAt.Before(e.StartedAt) {
+		t.Errorf("times = started %v, finished %v, updated %v", e.StartedAt, e.FinishedAt, e.UpdatedAt)
+	}
+	if e.Duration != 1500*time.Millisecond || e.Agent != "agent-1" || e.Attempts != 2 {
+		t.Errorf("duration, agent, attempts = %v, %q, %d; want 1.5s, agent-1, 2", e.Duration, e.Agent, e.Attempts)
+	}
+	if e.Status != storage.StatusCompleted || e.Version != version+1 {
+		t.Errorf("finished expression status, version = %s, %d; want completed, %d", e.Status, e.Version, version+1)
+	}
+
+	if _, err := repo.StartExpression(42); !errors.Is(err, storage.ErrExpressionNotFound) {
+		t.Errorf("StartExpression of an unknown expression = %v, want ErrExpressionNotFound", err)
+	}
+}
+
+func testIllegalTransitions(t *testing.T, repo storage.Repository) {
+	user := mustCreateUser(t, repo, "alice")
+	id := mustSaveExpression(t, repo, user, "1+1")
+
+	// A pending expression has not been evaluated, so it has no outcome.
+	err := repo.UpdateExpressionStatus(id, 1, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 2})
+	var transition *storage.TransitionError
+	if !errors.As(err, &transition) || !errors.Is(err, storage.ErrIllegalTransition) {
+		t.Fatalf("completing a pending expression = %v, want a TransitionError", err)
+	}
+	if transition.ID != id || transition.From != storage.StatusPending || transition.To != storage.StatusCompleted {
+		t.Errorf("TransitionError = %+v", transition)
+	}
+
+	mustFinish(t, repo, id, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 2})
+	e, err := repo.GetExpression(user, id)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+
+	// A late reply must not overwrite the outcome, even at the current
+	// version.
+	err = repo.UpdateExpressionStatus(id, e.Version, storage.ExpressionResult{Status: storage.StatusError, ErrorCode: "TIMEOUT"})
+	if !errors.As(err, &transition) || transition.From != storage.StatusCompleted || transition.To != storage.StatusError {
+		t.Errorf("failing a completed expression = %v, want a TransitionError from completed", err)
+	}
+	if _, err := repo.StartExpression(id); !errors.Is(err, storage.ErrIllegalTransition) {
+		t.Errorf("starting a completed expression = %v, want ErrIllegalTransition", err)
+	}
+	if _, err := repo.TransitionExpression(id, e.Version, "done"); !errors.Is(err, storage.ErrIllegalTransition) {
+		t.Errorf("moving to an unknown state = %v, want ErrIllegalTransition", err)
+	}
+
+	after, err := repo.GetExpression(user, id)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+	if after.Status != storage.StatusCompleted || after.Result != 2 || after.Version != e.Version {
+		t.Errorf("expression after illegal transitions = %+v, want it unchanged", after)
+	}
+}
+
+func testVersionConflicts(t *testing.T, repo storage.Repository) {
+	user := mustCreateUser(t, repo, "alice")
+	id := mustSaveExpression(t, repo, user, "1+1")
+
+	stale, err := repo.StartExpression(id)
+	if err != nil {
+		t.Fatalf("StartExpression: %v", err)
+	}
+	// Another worker requeues and picks up the expression in the meantime.
+	version, err := repo.TransitionExpression(id, stale, storage.StatusQueued)
+	if err != nil {
+		t.Fatalf("TransitionExpression: %v", err)
+	}
+	if version, err = repo.StartExpression(id); err != nil {
+		t.Fatalf("StartExpression: %v", err)
+	}
+
+	err = repo.UpdateExpressionStatus(id, stale, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 2})
+	if !errors.Is(err, storage.ErrVersionConflict) {
+		t.Errorf("update at a stale version = %v, want ErrVersionConflict", err)
+	}
+	if _, err := repo.TransitionExpression(id, stale, storage.StatusCancelled); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Errorf("transition at a stale version = %v, want ErrVersionConflict", err)
+	}
+	if err := repo.UpdateExpressionStatus(id, version, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 2}); err != nil {
+		t.Errorf("update at the current version: %v", err)
+	}
+	if _, err := repo.TransitionExpression(42, 1, storage.StatusCancelled); !errors.Is(err, storage.ErrExpressionNotFound) {
+		t.Errorf("TransitionExpression of an unknown expression = %v, want ErrExpressionNotFound", err)
+	}
+}
+
+func testCancel(t *testing.T, repo storage.Repository) {
+	user := mustCreateUser(t, repo, "alice")
+	id := mustSaveExpression(t, repo, user, "1+1")
+
+	version, err := repo.TransitionExpression(id, 1, storage.StatusCancelled)
+	if err != nil {
+		t.Fatalf("TransitionExpression to cancelled: %v", err)
+	}
+	if version != 2 {
+		t.Errorf("version = %d, want 2", version)
+	}
+	if _, err := repo.StartExpression(id); !errors.Is(err, storage.ErrIllegalTransition) {
+		t.Errorf("starting a cancelled expression = %v, want ErrIllegalTransition", err)
+	}
+	if _, err := repo.TransitionExpression(id, version, storage.StatusCancelled); !errors.Is(err, storage.ErrIllegalTransition) {
+		t.Errorf("cancelling twice = %v, want ErrIllegalTransition", err)
+	}
+
+	pending, err := repo.GetPendingExpressions()
+	if err != nil {
+		t.Fatalf("GetPendingExpressions: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("GetPendingExpressions = %+v, want none", pending)
+	}
+}
+
+func testQueue(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+	first := mustSaveExpression(t, repo, alice, "1+1")
+	done := mustSaveExpression(t, repo, bob, "2+2")
+	last := mustSaveExpression(t, repo, alice, "3+3")
+	mustFinish(t, repo, done, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 4})
+
+	pending, err := repo.GetPendingExpressions()
+	if err != nil {
+		t.Fatalf("GetPendingExpressions: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != first || pending[1].ID != last {
+		t.Fatalf("GetPendingExpressions = %+v, want expressions %d and %d", pending, first, last)
+	}
+	if pending[0].UserID != alice || pending[0].Expression != "1+1" {
+		t.Errorf("pending expression = %+v", pending[0])
+	}
+}
+
+// listAll reads every page of q and returns the IDs in order.
+func listAll(t *testing.T, repo storage.Repository, q storage.ExpressionQuery) []int64 {
+	t.Helper()
+	var ids []int64
+	for {
+		page, err := repo.ListExpressions(q)
+		if err != nil {
+			t.Fatalf("ListExpressions(%+v): %v", q, err)
+		}
+		if q.Limit > 0 && len(page.Expressions) > q.Limit {
+			t.Fatalf("page of %d expressions, limit %d", len(page.Expressions), q.Limit)
+		}
+		for _, e := range page.Expressions {
+			ids = append(ids, e.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testHistory(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+	var ids []int64
+	for _, expr := range []string{"1+1", "sin(0)", "2*3", "sin(1)", "4/0"} {
+		ids = append(ids, mustSaveExpression(t, repo, alice, expr))
+		mustSaveExpression(t, repo, bob, expr)
+	}
+	for i, status := range []string{"completed", "pending", "completed", "error", "error"} {
+		if status == "pending" {
+			continue
+		}
+		mustFinish(t, repo, ids[i], storage.ExpressionResult{Status: status})
+	}
+
+	// All expressions are created within the same second or two, so the
+	// pages also test the ID tie-break.
+	got := listAll(t, repo, storage.ExpressionQuery{UserID: alice, Limit: 2})
+	if !equalIDs(got, ids) {
+		t.Errorf("by creation time = %v, want %v", got, ids)
+	}
+	got = listAll(t, repo, storage.ExpressionQuery{UserID: alice, Limit: 2, Descending: true})
+	if want := []int64{ids[4], ids[3], ids[2], ids[1], ids[0]}; !equalIDs(got, want) {
+		t.Errorf("by creation time, descending = %v, want %v", got, want)
+	}
+	got = listAll(t, repo, storage.ExpressionQuery{UserID: alice, Limit: 2, Sort: storage.SortStatus})
+	if want := []int64{ids[0], ids[2], ids[3], ids[4], ids[1]}; !equalIDs(got, want) {
+		t.Errorf("by status = %v, want %v", got, want)
+	}
+	got = listAll(t, repo, storage.ExpressionQuery{UserID: alice, Limit: 3, Sort: storage.SortStatus, Descending: true})
+	if want := []int64{ids[1], ids[4], ids[3], ids[2], ids[0]}; !equalIDs(got, want) {
+		t.Errorf("by status, descending = %v, want %v", got, want)
+	}
+
+	page, err := repo.ListExpressions(storage.ExpressionQuery{UserID: alice})
+	if err != nil {
+		t.Fatalf("ListExpressions: %v", err)
+	}
+	if len(page.Expressions) != 5 || page.NextCursor != "" {
+		t.Errorf("default page = %d expressions, cursor %q; want all 5 and no cursor", len(page.Expressions), page.NextCursor)
+	}
+	if e := page.Expressions[0]; e.UserID != alice || e.Expression != "1+1" || e.Status != "completed" {
+		t.Errorf("listed expression = %+v", e)
+	}
+
+	page, err = repo.ListExpressions(storage.ExpressionQuery{UserID: alice, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListExpressions: %v", err)
+	}
+	if _, err := repo.ListExpressions(storage.ExpressionQuery{UserID: alice, Limit: 2, Descending: true, Cursor: page.NextCursor}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("cursor of another order = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := repo.ListExpressions(storage.ExpressionQuery{UserID: alice, Cursor: "not a cursor"}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("malformed cursor = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := repo.ListExpressions(storage.ExpressionQuery{UserID: alice, Sort: "result"}); !errors.Is(err, storage.ErrUnknownSort) {
+		t.Errorf("unknown sort = %v, want ErrUnknownSort", err)
+	}
+}
+
+func testHistoryFilters(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+	first := mustSaveExpression(t, repo, alice, "sin(0)")
+	second := mustSaveExpression(t, repo, alice, "cos(0)")
+	third := mustSaveExpression(t, repo, alice, "SIN(1)")
+	mustSaveExpression(t, repo, bob, "sin(2)")
+	mustFinish(t, repo, second, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 1})
+
+	tests := []struct {
+		name  string
+		query storage.ExpressionQuery
+		want  []int64
+	}{
+		{"status", storage.ExpressionQuery{Status: "pending"}, []int64{first, third}},
+		{"contains", storage.ExpressionQuery{Contains: "sin("}, []int64{first}},
+		{"contains and status", storage.ExpressionQuery{Contains: "(0)", Status: "completed"}, []int64{second}},
+		{"from the past", storage.ExpressionQuery{From: time.Now().Add(-time.Hour)}, []int64{first, second, third}},
+		{"from the future", storage.ExpressionQuery{From: time.Now().Add(time.Hour)}, nil},
+		{"to the past", storage.ExpressionQuery{To: time.Now().Add(-time.Hour)}, nil},
+		{"to the future", storage.ExpressionQuery{To: time.Now().Add(time.Hour)}, []int64{first, second, third}},
+	}
+	for _, tt := range tests {
+		tt.query.UserID = alice
+		if got := listAll(t, repo, tt.query); !equalIDs(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testGetExpression(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+	id := mustSaveExpression(t, repo, alice, "1+2")
+	mustFinish(t, repo, id, storage.ExpressionResult{Status: storage.StatusCompleted, Result: 3})
+
+	e, err := repo.GetExpression(alice, id)
+	if err != nil {
+		t.Fatalf("GetExpression: %v", err)
+	}
+	if e.ID != id || e.UserID != alice || e.Expression != "1+2" || e.Status != "completed" || e.Result != 3 {
+		t.Errorf("GetExpression = %+v", e)
+	}
+	if _, err := repo.GetExpression(bob, id); !errors.Is(err, storage.ErrExpressionNotFound) {
+		t.Errorf("GetExpression of another user's expression = %v, want ErrExpressionNotFound", err)
+	}
+	if _, err := repo.GetExpression(alice, 42); !errors.Is(err, storage.ErrExpressionNotFound) {
+		t.Errorf("GetExpression of an unknown expression = %v, want ErrExpressionNotFound", err)
+	}
+}
+
+func testFunctions(t *testing.T, repo storage.Repository) {
+	alice := mustCreateUser(t, repo, "alice")
+	bob := mustCreateUser(t, repo, "bob")
+
+	sq, err := repo.SaveFunction(alice, "sq", []string{"x"}, "x*x")
+	if err != nil {
+		t.Fatalf("SaveFunction: %v", err)
+	}
+	if sq.ID == 0 || sq.Version != 1 || sq.UserID != alice || len(sq.Params) != 1 || sq.CreatedAt.IsZero() {
+		t.Errorf("SaveFunction = %+v", sq)
+	}
+	sq2, err := repo.SaveFunction(alice, "sq", []string{"y"}, "y^2")
+	if err != nil {
+		t.Fatalf("SaveFunction: %v", err)
+	}
+	if sq2.Version != 2 {
+		t.Errorf("second version = %d, want 2", sq2.Version)
+	}
+	add, err := repo.SaveFunction(alice, "add", []string{"a", "b"}, "a+b")
+	if err != nil {
+		t.Fatalf("SaveFunction: %v", err)
+	}
+	other, err := repo.SaveFunction(bob, "sq", nil, "4")
+	if err != nil {
+		t.Fatalf("SaveFunction: %v", err)
+	}
+	if other.Version != 1 {
+		t.Errorf("version of another user's function = %d, want 1", other.Version)
+	}
+
+	latest, err := repo.GetUserFunctions(alice)
+	if err != nil {
+		t.Fatalf("GetUserFunctions: %v", err)
+	}
+	if len(latest) != 2 || latest[0].Name != "add" || latest[1].ID != sq2.ID {
+		t.Fatalf("GetUserFunctions = %+v, want add and sq version 2", latest)
+	}
+	if latest[1].Body != "y^2" || len(latest[1].Params) != 1 || latest[1].Params[0] != "y" {
+		t.Errorf("latest sq = %+v", latest[1])
+	}
+
+	versions, err := repo.GetFunctionVersions(alice, "sq")
+	if err != nil {
+		t.Fatalf("GetFunctionVersions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Errorf("GetFunctionVersions = %+v, want versions 1 and 2", versions)
+	}
+	if _, err := repo.GetFunctionVersions(alice, "cube"); !errors.Is(err, storage.ErrFunctionNotFound) {
+		t.Errorf("GetFunctionVersions of an unknown function = %v, want ErrFunctionNotFound", err)
+	}
+
+	expr, err := repo.SaveExpression(alice, "sq(2)+add(1,2)", "infix", "standard", nil, sq.ID, add.ID)
+	if err != nil {
+		t.Fatalf("SaveExpression: %v", err)
+	}
+	pinned, err := repo.GetExpressionFunctions(expr)
+	if err != nil {
+		t.Fatalf("GetExpressionFunctions: %v", err)
+	}
+	if len(pinned) != 2 || pinned[0].ID != add.ID || pinned[1].ID != sq.ID {
+		t.Errorf("GetExpressionFunctions = %+v, want add and sq version 1", pinned)
+	}
+}
+
+func testModules(t *testing.T, repo storage.Repository) {
+	admin := mustCreateUser(t, repo, "admin")
+
+	first, err := repo.SaveModule("stats", []byte("v1"), []string{"mean"}, admin)
+	if err != nil {
+		t.Fatalf("SaveModule: %v", err)
+	}
+	if first.ID == 0 || first.Checksum == "" || first.CreatedAt.IsZero() {
+		t.Errorf("SaveModule = %+v", first)
+	}
+	if _, err := repo.SaveModule("geo", []byte("g"), []string{"dist"}, admin); err != nil {
+		t.Fatalf("SaveModule: %v", err)
+	}
+	again, err := repo.SaveModule("stats", []byte("v2"), []string{"mean", "median"}, admin)
+	if err != nil {
+		t.Fatalf("SaveModule: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("replaced module ID = %d, want %d", again.ID, first.ID)
+	}
+	if again.Checksum == first.Checksum {
+		t.Error("checksum did not change with the binary")
+	}
+
+	modules, err := repo.GetModules()
+	if err != nil {
+		t.Fatalf("GetModules: %v", err)
+	}
+	if len(modules) != 2 || modules[0].Name != "geo" || modules[1].Name != "stats" {
+		t.Fatalf("GetModules = %+v, want geo and stats", modules)
+	}
+	m := modules[1]
+	if string(m.Binary) != "v2" || len(m.Exports) != 2 || m.UploadedBy != admin {
+		t.Errorf("replaced module = %+v", m)
+	}
+}
@@ -1,59 +1,192 @@
-package grpc
-
-import (
-	"context"
-	"fmt"
-	"time"
-
-	"github.com/opr1234/calculator/proto"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-)
-
-type Client struct {
-	conn   *grpc.ClientConn
-	client pb.CalculatorClient
-}
-
-func NewClient(addr string) (*Client, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	conn, err := grpc.DialContext(
-		ctx,
-		addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
-	}
-
-	return &Client{
-		conn:   conn,
-		client: pb.NewCalculatorClient(conn),
-	}, nil
-}
-
-func (c *Client) Evaluate(
-	ctx context.Context,
-	expr string,
-	userID int32,
-) (*pb.ExpressionResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	return c.client.Evaluate(ctx, &pb.ExpressionRequest{
-		Expression: expr,
-		UserId:     userID,
-	})
-}
-
-func (c *Client) Close() error {
-	return c.conn.Close()
-}
-
-func (c *Client) HealthCheck(ctx context.Context) error {
-	_, err := c.client.Ping(ctx, &pb.Empty{})
-	return err
-}
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opr1234/calculator/internal/calculator"
+	pb "github.com/opr1234/calculator/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+type Client struct {
+	conn   *grpc.ClientConn
+	client pb.CalculatorClient
+}
+
+func NewClient(addr string) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(
+		ctx,
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
+	}
+
+	return &Client{
+		conn:   conn,
+		client: pb.NewCalculatorClient(conn),
+	}, nil
+}
+
+func (c *Client) Evaluate(
+	ctx context.Context,
+	expr string,
+	userID int32,
+) (*pb.ExpressionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return c.client.Evaluate(ctx, &pb.ExpressionRequest{
+		Expression: expr,
+		UserId:     userID,
+	})
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) HealthCheck(ctx context.Context) error {
+	_, err := c.client.Ping(ctx, &pb.Empty{})
+	return err
+}
+
+// DefaultAgent is the address of the agent when AGENT_ADDRS is not set.
+const DefaultAgent = "localhost:50051"
+
+// AgentsFromEnv returns the addresses of the agents listed, comma-separated,
+// in AGENT_ADDRS, or DefaultAgent.
+func AgentsFromEnv() []string {
+	var addrs []string
+	for _, addr := range strings.Split(os.Getenv("AGENT_ADDRS"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return []string{DefaultAgent}
+	}
+	return addrs
+}
+
+// balancedConfig spreads the calls of a connection round robin over its
+// addresses instead of sending them all to the first one.
+const balancedConfig = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+
+// Agents are the connections of the server to its agents. Conns has one
+// connection to each agent, for modules, which every agent keeps its own
+// copy of. Balanced spreads evaluations over all of them round robin.
+type Agents struct {
+	Conns    []*grpc.ClientConn
+	Balanced *grpc.ClientConn
+}
+
+// DialAgents connects to the agents at addrs. Connections are made in the
+// background, so agents that are down are retried rather than fatal.
+func DialAgents(addrs []string) (*Agents, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no agents")
+	}
+
+	// The resolver hands the connection every address at once, so the
+	// balancer has all agents to pick from.
+	r := manual.NewBuilderWithScheme("agents")
+	state := resolver.State{}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+	r.InitialState(state)
+	balanced, err := grpc.Dial(r.Scheme()+":///calculator",
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(balancedConfig),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to agents: %w", err)
+	}
+
+	agents := &Agents{Balanced: balanced}
+	for _, addr := range addrs {
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			agents.Close()
+			return nil, fmt.Errorf("failed to connect to agent %s: %w", addr, err)
+		}
+		agents.Conns = append(agents.Conns, conn)
+	}
+	return agents, nil
+}
+
+// Calculator returns a client whose calls go to the agents in turn.
+func (a *Agents) Calculator() pb.CalculatorClient {
+	return pb.NewCalculatorClient(a.Balanced)
+}
+
+// Clients returns a client of each agent.
+func (a *Agents) Clients() []pb.CalculatorClient {
+	clients := make([]pb.CalculatorClient, len(a.Conns))
+	for i, conn := range a.Conns {
+		clients[i] = pb.NewCalculatorClient(conn)
+	}
+	return clients
+}
+
+// Close closes the connection to every agent.
+func (a *Agents) Close() error {
+	errs := []error{a.Balanced.Close()}
+	for _, conn := range a.Conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// AgentName returns the agent that answered a call, from the header of
+// its response; empty when no agent answered.
+func AgentName(header metadata.MD) string {
+	if values := header.Get(AgentHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// EvaluationError recovers the calculator error an agent call failed
+// with, nil for nil. Errors without a calculator code, such as an
+// unreachable agent, are classified by their gRPC code.
+func EvaluationError(err error) *calculator.Error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return calculator.Classify(err)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			return calculator.NewError(calculator.Code(info.Reason), st.Message())
+		}
+	}
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return calculator.NewError(calculator.CodeTimeout, st.Message())
+	case codes.ResourceExhausted:
+		return calculator.NewError(calculator.CodeLimitExceeded, st.Message())
+	}
+	return calculator.NewError(calculator.CodeInternal, st.Message())
+}
@@ -1,65 +1,230 @@
-package grpc
-
-import (
-	"context"
-	"log"
-	"time"
-
-	"github.com/yourusername/calculator/internal/calculator"
-	"github.com/opr1234/calculator/proto"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-)
-
-type Server struct {
-	pb.UnimplementedCalculatorServer
-	evaluator *calculator.Evaluator
-}
-
-func NewServer() *Server {
-	return &Server{
-		evaluator: calculator.NewEvaluator(),
-	}
-}
-
-func (s *Server) Evaluate(
-	ctx context.Context,
-	req *pb.ExpressionRequest,
-) (*pb.ExpressionResponse, error) {
-
-	if req.Expression == "" {
-		return nil, status.Error(codes.InvalidArgument, "empty expression")
-	}
-
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
-	defer cancel()
-
-	result, err := s.evaluator.Evaluate(ctx, req.Expression)
-	if err != nil {
-		log.Printf("Evaluation failed: %v", err)
-		return handleEvaluationError(err)
-	}
-
-	return &pb.ExpressionResponse{
-		Result: result,
-	}, nil
-}
-
-func (s *Server) Ping(ctx context.Context, _ *pb.Empty) (*pb.Pong, error) {
-	return &pb.Pong{Status: "OK"}, nil
-}
-
-func handleEvaluationError(err error) (*pb.ExpressionResponse, error) {
-	switch e := err.(type) {
-	case *calculator.EvaluationError:
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			"evaluation error: %s",
-			e.Message,
-		)
-	case *calculator.TimeoutError:
-		return nil, status.Error(codes.DeadlineExceeded, "calculation timeout")
-	default:
-		return nil, status.Error(codes.Internal, "internal server error")
-	}
-}
+package grpc
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/opr1234/calculator/internal/calculator"
+	pb "github.com/opr1234/calculator/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AgentHeader is the response header an agent names itself in, so the
+// server can record which agent computed an expression.
+const AgentHeader = "x-calculator-agent"
+
+type Server struct {
+	pb.UnimplementedCalculatorServer
+	evaluator  *calculator.Evaluator
+	wasmLimits calculator.WasmLimits
+	// name is sent in AgentHeader: AGENT_NAME, else the host name.
+	name string
+}
+
+func NewServer() *Server {
+	name := os.Getenv("AGENT_NAME")
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	return &Server{
+		evaluator:  calculator.NewEvaluator(),
+		wasmLimits: calculator.DefaultWasmLimits,
+		name:       name,
+	}
+}
+
+func (s *Server) Evaluate(
+	ctx context.Context,
+	req *pb.ExpressionRequest,
+) (*pb.ExpressionResponse, error) {
+	grpc.SetHeader(ctx, metadata.Pairs(AgentHeader, s.name))
+
+	if req.Expression == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty expression")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := s.evaluator.EvaluateWithOptions(ctx, req.Expression, requestOptions(req))
+	if err != nil {
+		log.Printf("Evaluation failed: %v", err)
+		return handleEvaluationError(err)
+	}
+
+	res := toResponse(result.Value)
+	for _, a := range result.Assignments {
+		res.Assignments = append(res.Assignments, &pb.Assignment{
+			Name:  a.Name,
+			Value: toResponse(a.Value),
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) Sample(ctx context.Context, req *pb.SampleRequest) (*pb.SampleResponse, error) {
+	if req.Expression == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty expression")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	opts := calculator.Options{
+		Functions: functionDefs(req.Functions),
+		Complex:   req.Complex,
+		Dialect:   req.Dialect,
+		Timezone:  req.Timezone,
+		Seed:      req.Seed,
+	}
+	ys, err := s.evaluator.Sample(ctx, req.Expression, req.Variable, req.Xs, opts)
+	if err != nil {
+		log.Printf("Sampling failed: %v", err)
+		return nil, statusOf(err)
+	}
+
+	return &pb.SampleResponse{Ys: ys}, nil
+}
+
+func requestOptions(req *pb.ExpressionRequest) calculator.Options {
+	return calculator.Options{
+		Functions: functionDefs(req.Functions),
+		Complex:   req.Complex,
+		Notation:  calculator.Notation(req.Notation),
+		Dialect:   req.Dialect,
+		Timezone:  req.Timezone,
+		Seed:      req.Seed,
+	}
+}
+
+func functionDefs(functions []*pb.FunctionDefinition) []calculator.FunctionDef {
+	var defs []calculator.FunctionDef
+	for _, fn := range functions {
+		defs = append(defs, calculator.FunctionDef{
+			Name:    fn.Name,
+			Params:  fn.Params,
+			Body:    fn.Body,
+			Version: int(fn.Version),
+		})
+	}
+	return defs
+}
+
+func toResponse(v calculator.Value) *pb.ExpressionResponse {
+	res := &pb.ExpressionResponse{Kind: string(v.Kind())}
+	switch v := v.(type) {
+	case calculator.Number:
+		res.Result = float64(v)
+	case calculator.Complex:
+		res.Result = real(v)
+		res.Imag = imag(v)
+	case calculator.Integer:
+		if f := v.Float(); !math.IsInf(f, 0) {
+			res.Result = f
+		}
+		res.Exact = v.String()
+		res.Digits = int32(v.Digits())
+	case calculator.Uncertain:
+		res.Result = v.Value
+		res.Uncertainty = v.Sigma()
+	case calculator.DateTime:
+		res.Result = float64(v.Time.UnixNano()) / 1e9
+		res.Iso = v.String()
+	case calculator.Duration:
+		res.Result, _ = v.Seconds()
+		res.Iso = v.String()
+	case calculator.Interval:
+		res.Result = v.Lo/2 + v.Hi/2
+		res.Values = []float64{v.Lo, v.Hi}
+	case calculator.List:
+		res.Values = v
+	case calculator.IntegerList:
+		res.Values = v.List()
+		res.Exact = v.String()
+	case calculator.Matrix:
+		for _, row := range v {
+			res.Matrix = append(res.Matrix, &pb.Row{Values: row})
+		}
+	}
+	return res
+}
+
+func (s *Server) Ping(ctx context.Context, _ *pb.Empty) (*pb.Pong, error) {
+	return &pb.Pong{Status: "OK"}, nil
+}
+
+func (s *Server) LoadModule(ctx context.Context, req *pb.WasmModule) (*pb.ModuleInfo, error) {
+	if req.Name == "" || len(req.Binary) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "module name and binary are required")
+	}
+
+	exports, err := s.evaluator.LoadModule(ctx, req.Name, req.Binary, s.wasmLimits)
+	if err != nil {
+		log.Printf("Loading module %s failed: %v", req.Name, err)
+		return nil, statusOf(err)
+	}
+
+	return &pb.ModuleInfo{
+		Name:    req.Name,
+		Exports: exports,
+	}, nil
+}
+
+// UnloadModule removes a module, which the server does when a module it
+// sent could not be stored. Unknown modules are not an error.
+func (s *Server) UnloadModule(ctx context.Context, req *pb.WasmModule) (*pb.Empty, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "module name is required")
+	}
+	s.evaluator.UnloadModule(ctx, req.Name)
+	return &pb.Empty{}, nil
+}
+
+func handleEvaluationError(err error) (*pb.ExpressionResponse, error) {
+	return nil, statusOf(err)
+}
+
+// errorDomain marks the ErrorInfo details that carry a calculator error
+// code.
+const errorDomain = "calculator"
+
+// statusCodes maps calculator error codes to gRPC codes. Codes missing
+// here are Internal.
+var statusCodes = map[calculator.Code]codes.Code{
+	calculator.CodeSyntax:            codes.InvalidArgument,
+	calculator.CodeUnknownIdentifier: codes.InvalidArgument,
+	calculator.CodeUnknownFunction:   codes.InvalidArgument,
+	calculator.CodeInvalidArgument:   codes.InvalidArgument,
+	calculator.CodeDivisionByZero:    codes.InvalidArgument,
+	calculator.CodeDomain:            codes.InvalidArgument,
+	calculator.CodeUnsupported:       codes.InvalidArgument,
+	calculator.CodeInvalidDefinition: codes.InvalidArgument,
+	calculator.CodeOverflow:          codes.OutOfRange,
+	calculator.CodeTimeout:           codes.DeadlineExceeded,
+	calculator.CodeLimitExceeded:     codes.ResourceExhausted,
+}
+
+// statusOf turns a calculator error into a gRPC status whose details hold
+// its code, see EvaluationError for the way back.
+func statusOf(err error) error {
+	e := calculator.Classify(err)
+	code, ok := statusCodes[e.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	st := status.New(code, e.Message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: string(e.Code),
+		Domain: errorDomain,
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,28 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/opr1234/calculator/internal/calculator"
+	"github.com/opr1234/calculator/internal/models"
+)
+
+// Convert rewrites an expression into another notation without
+// evaluating it.
+func (h *Handler) Convert(w http.ResponseWriter, r *http.Request) {
+	var req models.ConversionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	expr, err := calculator.Convert(req.Expression, calculator.Notation(req.From), calculator.Notation(req.To))
+	if err != nil {
+		sendCalculationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"expression": expr})
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/models"
+	"github.com/opr1234/calculator/internal/storage"
+	"github.com/opr1234/calculator/pkg/calculator"
+)
+
+// ListExpressions returns a page of the user's expressions. Query
+// parameters filter by status, creation time (from, to as RFC 3339) and
+// text (contains), sort by created_at or status, order asc or desc, page
+// with limit and the cursor of the previous page, and typeset the
+// expressions with format=latex or format=mathml.
+func (h *Handler) ListExpressions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+	query := r.URL.Query()
+
+	format, err := requestedFormat(r)
+	if err != nil {
+		sendCalculationError(w, calculator.ErrUnknownFormat)
+		return
+	}
+
+	q := storage.ExpressionQuery{
+		UserID:   userID,
+		Status:   query.Get("status"),
+		Contains: query.Get("contains"),
+		Sort:     query.Get("sort"),
+		Cursor:   query.Get("cursor"),
+	}
+
+	if v := query.Get("from"); v != "" {
+		q.From, err = time.Parse(time.RFC3339, v)
+	}
+	if v := query.Get("to"); v != "" && err == nil {
+		q.To, err = time.Parse(time.RFC3339, v)
+	}
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid time range")
+		return
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		sendError(w, http.StatusBadRequest, "Order must be asc or desc")
+		return
+	}
+
+	if v := query.Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit < 1 || q.Limit > storage.MaxPageSize {
+			sendError(w, http.StatusBadRequest, "Limit must be between 1 and "+strconv.Itoa(storage.MaxPageSize))
+			return
+		}
+	}
+
+	page, err := h.storage.ListExpressions(q)
+	switch {
+	case errors.Is(err, storage.ErrUnknownSort):
+		sendError(w, http.StatusBadRequest, "Sort must be created_at or status")
+		return
+	case errors.Is(err, storage.ErrInvalidCursor):
+		sendError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	case err != nil:
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	res := models.ExpressionList{
+		Expressions: make([]models.Expression, len(page.Expressions)),
+		NextCursor:  page.NextCursor,
+	}
+	for i, e := range page.Expressions {
+		if res.Expressions[i], err = toExpressionModel(e, format); err != nil {
+			sendError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// GetExpression returns one of the user's expressions, typeset like in
+// ListExpressions; the expressions of other users are not found.
+func (h *Handler) GetExpression(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	format, err := requestedFormat(r)
+	if err != nil {
+		sendCalculationError(w, calculator.ErrUnknownFormat)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendError(w, http.StatusNotFound, "Expression not found")
+		return
+	}
+
+	expr, err := h.storage.GetExpression(userID, id)
+	if errors.Is(err, storage.ErrExpressionNotFound) {
+		sendError(w, http.StatusNotFound, "Expression not found")
+		return
+	}
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	res, err := toExpressionModel(*expr, format)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// CancelExpression cancels one of the user's expressions that has not
+// finished yet. Finished expressions and ones that change while they are
+// cancelled are a conflict.
+func (h *Handler) CancelExpression(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	format, err := requestedFormat(r)
+	if err != nil {
+		sendCalculationError(w, calculator.ErrUnknownFormat)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendError(w, http.StatusNotFound, "Expression not found")
+		return
+	}
+
+	expr, err := h.storage.GetExpression(userID, id)
+	if errors.Is(err, storage.ErrExpressionNotFound) {
+		sendError(w, http.StatusNotFound, "Expression not found")
+		return
+	}
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	_, err = h.storage.TransitionExpression(id, expr.Version, storage.StatusCancelled)
+	var transition *storage.TransitionError
+	switch {
+	case errors.As(err, &transition):
+		sendError(w, http.StatusConflict, "Expression is already "+transition.From)
+		return
+	case errors.Is(err, storage.ErrVersionConflict):
+		sendError(w, http.StatusConflict, "Expression was changed, try again")
+		return
+	case err != nil:
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	expr, err = h.storage.GetExpression(userID, id)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	res, err := toExpressionModel(*expr, format)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// toExpressionModel decodes the JSON the storage keeps non-scalar results
+// and script bindings in, and typesets the expression in format unless it
+// is empty. An expression that cannot be typeset, such as one that failed
+// with a syntax error, is returned without markup.
+func toExpressionModel(e storage.Expression, format calculator.Format) (models.Expression, error) {
+	res := models.Expression{
+		ID:          e.ID,
+		UserID:      e.UserID,
+		Expression:  e.Expression,
+		Notation:    e.Notation,
+		Dialect:     e.Dialect,
+		Seed:        e.Seed,
+		Status:      e.Status,
+		Version:     e.Version,
+		Result:      e.Result,
+		Imag:        e.ResultImag,
+		Exact:       e.ResultExact,
+		Digits:      e.ResultDigits,
+		Uncertainty: e.ResultUncertainty,
+		Words:       e.ResultWords,
+		ISO:         e.ResultISO,
+		ErrorCode:   e.ErrorCode,
+		Error:       e.ErrorMessage,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		DurationMs:  e.Duration.Milliseconds(),
+		Agent:       e.Agent,
+		Attempts:    e.Attempts,
+	}
+	if format != "" {
+		if infix, err := standardInfix(e.Expression, calculator.Notation(e.Notation), e.Dialect); err == nil {
+			res.Rendered, _ = calculator.Render(infix, format)
+		}
+	}
+	if !e.StartedAt.IsZero() {
+		res.StartedAt = &e.StartedAt
+	}
+	if !e.FinishedAt.IsZero() {
+		res.FinishedAt = &e.FinishedAt
+	}
+	if e.ResultValue != "" {
+		if err := json.Unmarshal([]byte(e.ResultValue), &res.Value); err != nil {
+			return res, err
+		}
+	}
+	if e.Assignments != "" {
+		if err := json.Unmarshal([]byte(e.Assignments), &res.Assignments); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/models"
+	"github.com/opr1234/calculator/internal/storage"
+)
+
+// serve calls a handler as the given user, with the route variables a
+// router would set.
+func serve(handler http.HandlerFunc, userID int, target string, vars map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r = r.WithContext(context.WithValue(r.Context(), auth.ContextKeyUserID, userID))
+	r = mux.SetURLVars(r, vars)
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+func TestExpressionsRendered(t *testing.T) {
+	repo := storage.NewMemory()
+	h := NewHandler(repo, nil, nil, "")
+	userID, err := repo.CreateUser("alice", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// The same expression in infix and RPN, and one that cannot be read.
+	var ids []int64
+	for _, e := range []struct{ expr, notation string }{
+		{"a / b", "infix"},
+		{"a b /", "rpn"},
+		{"2 +", "infix"},
+	} {
+		id, err := repo.SaveExpression(int(userID), e.expr, e.notation, "standard", nil)
+		if err != nil {
+			t.Fatalf("SaveExpression(%q): %v", e.expr, err)
+		}
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", ""},
+		{"latex", `\frac{a}{b}`},
+		{"mathml", `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+	}
+	for _, tt := range tests {
+		t.Run("format="+tt.format, func(t *testing.T) {
+			rec := serve(h.ListExpressions, int(userID), "/api/v1/expressions?format="+tt.format, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("ListExpressions: status %d: %s", rec.Code, rec.Body)
+			}
+			var list models.ExpressionList
+			if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Expressions) != len(ids) {
+				t.Fatalf("ListExpressions: got %d expressions, want %d", len(list.Expressions), len(ids))
+			}
+			for _, e := range list.Expressions {
+				want := tt.want
+				if e.ID == ids[2] {
+					want = ""
+				}
+				if !strings.Contains(e.Rendered, want) || want == "" && e.Rendered != "" {
+					t.Errorf("ListExpressions: %s rendered as %q, want %q", e.Expression, e.Rendered, want)
+				}
+			}
+
+			id := strconv.FormatInt(ids[1], 10)
+			rec = serve(h.GetExpression, int(userID), "/api/v1/expressions/"+id+"?format="+tt.format, map[string]string{"id": id})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GetExpression: status %d: %s", rec.Code, rec.Body)
+			}
+			var e models.Expression
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(e.Rendered, tt.want) || tt.want == "" && e.Rendered != "" {
+				t.Errorf("GetExpression: rendered as %q, want %q", e.Rendered, tt.want)
+			}
+		})
+	}
+
+	id := strconv.FormatInt(ids[0], 10)
+	for _, c := range []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{"ListExpressions", h.ListExpressions, "/api/v1/expressions?format=svg"},
+		{"GetExpression", h.GetExpression, "/api/v1/expressions/" + id + "?format=svg"},
+	} {
+		rec := serve(c.handler, int(userID), c.target, map[string]string{"id": id})
+		var body models.APIError
+		json.NewDecoder(rec.Body).Decode(&body)
+		if rec.Code != http.StatusBadRequest || body.Code != "UNSUPPORTED" {
+			t.Errorf("%s with format=svg: status %d, code %q, want 400 UNSUPPORTED", c.name, rec.Code, body.Code)
+		}
+	}
+}
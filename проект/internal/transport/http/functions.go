@@ -0,0 +1,199 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/calculator"
+	"github.com/opr1234/calculator/internal/models"
+	"github.com/opr1234/calculator/internal/storage"
+	pb "github.com/opr1234/calculator/proto"
+)
+
+func (h *Handler) CreateFunction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	var req models.FunctionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if _, err := requestedFormat(r); err != nil {
+		sendCalculationError(w, calculator.ErrUnknownFormat)
+		return
+	}
+
+	def, err := calculator.ParseFunction(req.Definition)
+	if err != nil {
+		sendCalculationError(w, err)
+		return
+	}
+
+	existing, err := h.storage.GetUserFunctions(userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	defs := []calculator.FunctionDef{def}
+	for _, fn := range existing {
+		if fn.Name != def.Name {
+			defs = append(defs, toFunctionDef(fn))
+		}
+	}
+	if err := calculator.CheckCycles(defs); err != nil {
+		sendCalculationError(w, err)
+		return
+	}
+
+	fn, err := h.storage.SaveFunction(userID, def.Name, def.Params, def.Body)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	res := toFunctionModel(*fn)
+	if res.Rendered, err = renderFunction(r, *fn); err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) ListFunctions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	functions, err := h.storage.GetUserFunctions(userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	sendFunctions(w, r, functions)
+}
+
+// GetFunction returns every version of a function, oldest first.
+func (h *Handler) GetFunction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	versions, err := h.storage.GetFunctionVersions(userID, mux.Vars(r)["name"])
+	if err != nil {
+		if errors.Is(err, storage.ErrFunctionNotFound) {
+			sendError(w, http.StatusNotFound, "Function not found")
+			return
+		}
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	sendFunctions(w, r, versions)
+}
+
+func sendFunctions(w http.ResponseWriter, r *http.Request, functions []storage.Function) {
+	res := make([]models.Function, len(functions))
+	for i, fn := range functions {
+		res[i] = toFunctionModel(fn)
+		rendered, err := renderFunction(r, fn)
+		if err != nil {
+			sendCalculationError(w, calculator.ErrUnknownFormat)
+			return
+		}
+		res[i].Rendered = rendered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"functions": res,
+	})
+}
+
+// requiredFunctions returns the current versions of the user functions an
+// expression calls, directly or through other user functions.
+func (h *Handler) requiredFunctions(userID int, expr string) ([]storage.Function, error) {
+	calls, err := calculator.CalledFunctions(expr)
+	if err != nil || len(calls) == 0 {
+		// Syntax errors are reported by the agent.
+		return nil, nil
+	}
+
+	functions, err := h.storage.GetUserFunctions(userID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]storage.Function, len(functions))
+	for _, fn := range functions {
+		byName[fn.Name] = fn
+	}
+
+	var required []storage.Function
+	seen := make(map[string]bool)
+	for len(calls) > 0 {
+		name := calls[0]
+		calls = calls[1:]
+
+		fn, ok := byName[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		required = append(required, fn)
+
+		nested, err := calculator.CalledFunctions(fn.Body)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, nested...)
+	}
+
+	return required, nil
+}
+
+func toFunctionDef(fn storage.Function) calculator.FunctionDef {
+	return calculator.FunctionDef{
+		Name:    fn.Name,
+		Params:  fn.Params,
+		Body:    fn.Body,
+		Version: fn.Version,
+	}
+}
+
+func toFunctionModel(fn storage.Function) models.Function {
+	return models.Function{
+		Name:       fn.Name,
+		Params:     fn.Params,
+		Body:       fn.Body,
+		Definition: toFunctionDef(fn).String(),
+		Version:    fn.Version,
+		CreatedAt:  fn.CreatedAt,
+	}
+}
+
+// renderFunction typesets the definition of fn in the format the request
+// asks for, if any.
+func renderFunction(r *http.Request, fn storage.Function) (string, error) {
+	format, err := requestedFormat(r)
+	if err != nil || format == "" {
+		return "", err
+	}
+	return calculator.RenderFunction(toFunctionDef(fn), format)
+}
+
+func toFunctionDefinitions(functions []storage.Function) []*pb.FunctionDefinition {
+	defs := make([]*pb.FunctionDefinition, len(functions))
+	for i, fn := range functions {
+		defs[i] = &pb.FunctionDefinition{
+			Name:    fn.Name,
+			Params:  fn.Params,
+			Body:    fn.Body,
+			Version: int32(fn.Version),
+		}
+	}
+	return defs
+}
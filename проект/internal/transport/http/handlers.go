@@ -1,181 +1,494 @@
-package http
-
-import (
-	"context"
-	"encoding/json"
-	"errors"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/opr1234/calculator/internal/auth"
-	"github.com/opr1234/calculator/internal/storage"
-	pb "github.com/opr1234/calculator/proto"
-)
-
-type Handler struct {
-	storage    *storage.Storage
-	calculator pb.CalculatorClient
-	secret     string
-}
-
-func NewHandler(
-	storage *storage.Storage,
-	calculator pb.CalculatorClient,
-	secret string,
-) *Handler {
-	return &Handler{
-		storage:    storage,
-		calculator: calculator,
-		secret:     secret,
-	}
-}
-
-func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Login    string `json:"login"`
-		Password string `json:"password"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, http.StatusBadRequest, "Invalid request format")
-		return
-	}
-
-	if req.Login == "" || req.Password == "" {
-		sendError(w, http.StatusBadRequest, "Login and password are required")
-		return
-	}
-
-	hash, err := auth.HashPassword(req.Password)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	if _, err := h.storage.CreateUser(req.Login, hash); err != nil {
-		if errors.Is(err, storage.ErrUserExists) {
-			sendError(w, http.StatusConflict, "User already exists")
-			return
-		}
-		sendError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-	})
-}
-
-func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Login    string `json:"login"`
-		Password string `json:"password"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, http.StatusBadRequest, "Invalid request format")
-		return
-	}
-
-	user, err := h.storage.GetUserByLogin(req.Login)
-	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			sendError(w, http.StatusUnauthorized, "Invalid credentials")
-			return
-		}
-		sendError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	if !auth.CheckPassword(req.Password, user.PasswordHash) {
-		sendError(w, http.StatusUnauthorized, "Invalid credentials")
-		return
-	}
-
-	token, err := auth.GenerateToken(user.ID, h.secret)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": token,
-	})
-}
-
-func (h *Handler) Calculate(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(auth.ContextKeyUserID).(int)
-
-	var req struct {
-		Expression string `json:"expression"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, http.StatusBadRequest, "Invalid request format")
-		return
-	}
-
-	if !isValidExpression(req.Expression) {
-		sendError(w, http.StatusUnprocessableEntity, "Invalid expression")
-		return
-	}
-
-	exprID, err := h.storage.SaveExpression(userID, req.Expression)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	go h.processExpression(r.Context(), exprID, userID, req.Expression)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":     exprID,
-		"status": "pending",
-	})
-}
-
-func (h *Handler) processExpression(
-	ctx context.Context,
-	exprID int64,
-	userID int,
-	expression string,
-) {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	res, err := h.calculator.Evaluate(ctx, &pb.ExpressionRequest{
-		Expression: expression,
-		UserId:     int32(userID),
-	})
-
-	var status string
-	var result float64
-	if err != nil {
-		status = "error"
-	} else if res.Error != "" {
-		status = "error"
-	} else {
-		status = "completed"
-		result = res.Result
-	}
-
-	if err := h.storage.UpdateExpressionStatus(exprID, status, result); err != nil {
-		log.Printf("Failed to update expression status: %v", err)
-	}
-}
-
-func isValidExpression(expr string) bool {
-	return true
-}
-
-func sendError(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error": message,
-	})
-}
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/calculator"
+	"github.com/opr1234/calculator/internal/models"
+	"github.com/opr1234/calculator/internal/storage"
+	agent "github.com/opr1234/calculator/internal/transport/grpc"
+	pb "github.com/opr1234/calculator/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxAttempts is how many times an expression is sent to the agents
+	// while none of them is reachable, retryDelay the pause in between.
+	maxAttempts = 3
+	retryDelay  = time.Second
+)
+
+type Handler struct {
+	storage storage.Repository
+	// calculator evaluates expressions on the agents, balancing its calls
+	// round robin over them; agents reaches each of them for the modules
+	// every agent must load.
+	calculator pb.CalculatorClient
+	agents     []pb.CalculatorClient
+	secret     string
+}
+
+func NewHandler(
+	storage storage.Repository,
+	calculator pb.CalculatorClient,
+	agents []pb.CalculatorClient,
+	secret string,
+) *Handler {
+	return &Handler{
+		storage:    storage,
+		calculator: calculator,
+		agents:     agents,
+		secret:     secret,
+	}
+}
+
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.Login == "" || req.Password == "" {
+		sendError(w, http.StatusBadRequest, "Login and password are required")
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if _, err := h.storage.CreateUser(req.Login, hash); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			sendError(w, http.StatusConflict, "User already exists")
+			return
+		}
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+	})
+}
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	user, err := h.storage.GetUserByLogin(req.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			sendError(w, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if !auth.CheckPassword(req.Password, user.PasswordHash) {
+		sendError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, h.secret)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"token": token,
+	})
+}
+
+func (h *Handler) Calculate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	var req models.CalculationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.Words {
+		expr, err := calculator.Translate(req.Expression)
+		if err != nil {
+			sendCalculationError(w, err)
+			return
+		}
+		req.Expression = expr
+	}
+	switch calculator.Language(req.SpellOut) {
+	case "", calculator.LanguageEnglish, calculator.LanguageRussian:
+	default:
+		sendCalculationError(w, calculator.ErrUnknownLanguage)
+		return
+	}
+
+	notation := calculator.Notation(req.Notation)
+	switch notation {
+	case "":
+		notation = calculator.NotationInfix
+	case calculator.NotationInfix, calculator.NotationRPN, calculator.NotationPrefix:
+	default:
+		sendCalculationError(w, calculator.ErrUnknownNotation)
+		return
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		sendCalculationError(w, calculator.ErrUnknownTimezone)
+		return
+	}
+
+	dialect, err := h.dialectFor(userID, req.Dialect)
+	if errors.Is(err, calculator.ErrUnknownDialect) {
+		sendCalculationError(w, calculator.ErrUnknownDialect)
+		return
+	}
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	req.Dialect = dialect.Name
+
+	if !isValidExpression(req.Expression) {
+		sendCalculationError(w, calculator.ErrInvalidExpression)
+		return
+	}
+
+	// Functions are looked up and the expression typeset in its standard
+	// infix form.
+	infix, err := standardInfix(req.Expression, notation, dialect.Name)
+	if err != nil {
+		sendCalculationError(w, err)
+		return
+	}
+
+	format, err := requestedFormat(r)
+	if err != nil {
+		sendCalculationError(w, calculator.ErrUnknownFormat)
+		return
+	}
+	var rendered string
+	if format != "" {
+		rendered, err = calculator.Render(infix, format)
+		if err != nil {
+			sendCalculationError(w, err)
+			return
+		}
+	}
+
+	functions, err := h.requiredFunctions(userID, infix)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	functionIDs := make([]int64, len(functions))
+	for i, fn := range functions {
+		functionIDs[i] = fn.ID
+	}
+
+	exprID, err := h.storage.SaveExpression(userID, req.Expression, string(notation), dialect.Name, req.Seed, functionIDs...)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if req.Seed == nil {
+		req.Seed = &exprID
+	}
+
+	// The expression is evaluated after the response is sent, so it must
+	// outlive the request.
+	go h.processExpression(context.WithoutCancel(r.Context()), exprID, userID, req, functions)
+
+	res := models.CalculationResponse{
+		ID:       exprID,
+		Status:   storage.StatusPending,
+		Rendered: rendered,
+	}
+	if req.Words {
+		res.Expression = req.Expression
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) processExpression(
+	ctx context.Context,
+	exprID int64,
+	userID int,
+	req models.CalculationRequest,
+	functions []storage.Function,
+) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// The expression may have been cancelled before it is picked up.
+	version, err := h.storage.StartExpression(exprID)
+	if err != nil {
+		log.Printf("Expression %d not started: %v", exprID, err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		var header metadata.MD
+		res, err := h.evaluate(ctx, &pb.ExpressionRequest{
+			Expression: req.Expression,
+			UserId:     int32(userID),
+			Functions:  toFunctionDefinitions(functions),
+			Complex:    req.Complex,
+			Notation:   req.Notation,
+			Dialect:    req.Dialect,
+			Timezone:   req.Timezone,
+			Seed:       *req.Seed,
+		}, grpc.Header(&header))
+
+		// No agent answered: queue the expression and try again.
+		if status.Code(err) == codes.Unavailable && attempt < maxAttempts {
+			if version, err = h.requeue(ctx, exprID, version); err != nil {
+				log.Printf("Expression %d not retried: %v", exprID, err)
+				return
+			}
+			continue
+		}
+
+		outcome := evaluationOutcome(res, err, req)
+		outcome.Duration = time.Since(started)
+		outcome.Agent = agent.AgentName(header)
+
+		// A cancelled expression keeps its state and the reply is dropped.
+		if err := h.storage.UpdateExpressionStatus(exprID, version, outcome); err != nil {
+			log.Printf("Failed to update expression status: %v", err)
+		}
+		return
+	}
+}
+
+// requeue moves an expression the agents did not take back to the queue,
+// waits retryDelay and starts it again, returning its new version.
+func (h *Handler) requeue(ctx context.Context, exprID int64, version int) (int, error) {
+	if _, err := h.storage.TransitionExpression(exprID, version, storage.StatusQueued); err != nil {
+		return 0, err
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(retryDelay):
+	}
+	// Once the deadline has passed the attempt fails with TIMEOUT.
+	return h.storage.StartExpression(exprID)
+}
+
+// evaluationOutcome is the result to store for an agent's reply.
+func evaluationOutcome(res *pb.ExpressionResponse, err error, req models.CalculationRequest) storage.ExpressionResult {
+	switch {
+	case err != nil:
+		return failedResult(agent.EvaluationError(err))
+	case res.Error != "":
+		return failedResult(calculator.NewError(calculator.CodeInternal, res.Error))
+	}
+	outcome, err := completedResult(res)
+	if err != nil {
+		log.Printf("Failed to encode expression result: %v", err)
+		outcome = failedResult(calculator.Classify(err))
+	}
+	if req.SpellOut != "" {
+		outcome.ResultWords = spelledResult(res, calculator.Language(req.SpellOut))
+	}
+	return outcome
+}
+
+// completedResult converts a successful agent response into the form the
+// storage keeps: non-scalar values and script bindings are stored as JSON.
+func completedResult(res *pb.ExpressionResponse) (storage.ExpressionResult, error) {
+	outcome := storage.ExpressionResult{
+		Status:            storage.StatusCompleted,
+		Result:            res.Result,
+		ResultImag:        res.Imag,
+		ResultExact:       res.Exact,
+		ResultDigits:      int(res.Digits),
+		ResultUncertainty: res.Uncertainty,
+		ResultISO:         res.Iso,
+	}
+
+	if value := toValue(res); value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return outcome, err
+		}
+		outcome.ResultValue = string(data)
+	}
+
+	if len(res.Assignments) > 0 {
+		assignments := make([]models.Assignment, len(res.Assignments))
+		for i, a := range res.Assignments {
+			assignments[i] = models.Assignment{
+				Name:        a.Name,
+				Result:      a.Value.GetResult(),
+				Imag:        a.Value.GetImag(),
+				Exact:       a.Value.GetExact(),
+				Digits:      int(a.Value.GetDigits()),
+				Uncertainty: a.Value.GetUncertainty(),
+				ISO:         a.Value.GetIso(),
+				Value:       toValue(a.Value),
+			}
+		}
+		data, err := json.Marshal(assignments)
+		if err != nil {
+			return outcome, err
+		}
+		outcome.Assignments = string(data)
+	}
+
+	return outcome, nil
+}
+
+// failedResult is the stored form of an expression that failed.
+func failedResult(e *calculator.Error) storage.ExpressionResult {
+	return storage.ExpressionResult{
+		Status:       storage.StatusError,
+		ErrorCode:    string(e.Code),
+		ErrorMessage: e.Message,
+	}
+}
+
+// spelledResult writes a real or integer result out in words. Other
+// results have no words.
+func spelledResult(res *pb.ExpressionResponse, lang calculator.Language) string {
+	var value calculator.Value
+	switch res.GetKind() {
+	case "number":
+		value = calculator.Number(res.Result)
+	case "integer":
+		n, ok := new(big.Int).SetString(res.Exact, 10)
+		if !ok {
+			return ""
+		}
+		value = calculator.Integer{Int: n}
+	default:
+		return ""
+	}
+
+	words, err := calculator.SpellOut(value, lang)
+	if err != nil {
+		return ""
+	}
+	return words
+}
+
+// toValue returns the non-scalar part of an agent result, or nil when the
+// result is a scalar: a real, complex, big integer or uncertain number.
+func toValue(res *pb.ExpressionResponse) *models.Value {
+	switch res.GetKind() {
+	case "", "number", "integer", "complex", "uncertain", "datetime", "duration":
+		return nil
+	}
+
+	value := &models.Value{
+		Kind:   res.Kind,
+		Values: res.Values,
+	}
+	for _, row := range res.Matrix {
+		value.Matrix = append(value.Matrix, row.Values)
+	}
+	return value
+}
+
+// standardInfix rewrites an expression written in the given notation and
+// dialect into the standard infix form.
+func standardInfix(expr string, notation calculator.Notation, dialect string) (string, error) {
+	switch {
+	case notation != "" && notation != calculator.NotationInfix:
+		return calculator.Convert(expr, notation, calculator.NotationInfix)
+	case dialect != "" && dialect != calculator.Standard.Name:
+		return calculator.Standardize(expr, dialect)
+	}
+	return expr, nil
+}
+
+// requestedFormat returns the markup asked for with format=latex or
+// format=mathml, or "" when the request does not ask for one.
+func requestedFormat(r *http.Request) (calculator.Format, error) {
+	switch format := calculator.Format(r.URL.Query().Get("format")); format {
+	case "":
+		return "", nil
+	case calculator.FormatLaTeX, calculator.FormatMathML:
+		return format, nil
+	}
+	return "", calculator.ErrUnknownFormat
+}
+
+func isValidExpression(expr string) bool {
+	return true
+}
+
+// errorCodes are the codes of the errors sendError reports, by HTTP status.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            models.CodeInvalidRequest,
+	http.StatusUnauthorized:          models.CodeUnauthorized,
+	http.StatusForbidden:             models.CodeForbidden,
+	http.StatusNotFound:              models.CodeNotFound,
+	http.StatusConflict:              models.CodeConflict,
+	http.StatusRequestEntityTooLarge: string(calculator.CodeLimitExceeded),
+	http.StatusInternalServerError:   string(calculator.CodeInternal),
+}
+
+// sendError reports an error of the request with the code of its status.
+func sendError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(models.APIError{
+		Error: message,
+		Code:  errorCodes[code],
+	})
+}
+
+// sendCalculationError reports an error of the calculator or an agent
+// together with its code.
+func sendCalculationError(w http.ResponseWriter, err error) {
+	e := agent.EvaluationError(err)
+	code := http.StatusUnprocessableEntity
+	switch e.Code {
+	case calculator.CodeUnsupported:
+		code = http.StatusBadRequest
+	case calculator.CodeTimeout:
+		code = http.StatusGatewayTimeout
+	case calculator.CodeInternal:
+		code = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(models.APIError{
+		Error: e.Message,
+		Code:  string(e.Code),
+	})
+}
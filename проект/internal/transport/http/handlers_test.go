@@ -0,0 +1,43 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opr1234/calculator/internal/models"
+)
+
+func TestSendError(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusBadRequest, models.CodeInvalidRequest},
+		{http.StatusUnauthorized, models.CodeUnauthorized},
+		{http.StatusForbidden, models.CodeForbidden},
+		{http.StatusNotFound, models.CodeNotFound},
+		{http.StatusConflict, models.CodeConflict},
+		{http.StatusRequestEntityTooLarge, "LIMIT_EXCEEDED"},
+		{http.StatusInternalServerError, "INTERNAL"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		sendError(rec, tt.status, "message")
+
+		if rec.Code != tt.status {
+			t.Errorf("status %d: got status %d", tt.status, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("status %d: got Content-Type %q", tt.status, got)
+		}
+		var body models.APIError
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("status %d: %v", tt.status, err)
+		}
+		if body.Error != "message" || body.Code != tt.code {
+			t.Errorf("status %d: got %+v, want code %s", tt.status, body, tt.code)
+		}
+	}
+}
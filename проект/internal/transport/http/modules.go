@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/storage"
+	pb "github.com/opr1234/calculator/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// maxModuleSize limits the size of an uploaded WebAssembly module.
+const maxModuleSize = 4 << 20
+
+// UploadModule stores a WebAssembly module sent as the raw request body.
+// Every reachable agent compiles it first, so a module they reject is never
+// stored; when it is rejected or cannot be stored, the agents get the
+// module they had back.
+func (h *Handler) UploadModule(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	user, err := h.storage.GetUserByID(userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !user.IsAdmin {
+		sendError(w, http.StatusForbidden, "Only administrators can upload modules")
+		return
+	}
+
+	binary, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxModuleSize))
+	if err != nil {
+		sendError(w, http.StatusRequestEntityTooLarge, "Module is too large")
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	previous, err := h.storedModule(name)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	// The rollback must run even if the client has gone.
+	ctx := context.WithoutCancel(r.Context())
+
+	info, err := h.loadModule(ctx, &pb.WasmModule{Name: name, Binary: binary})
+	if err != nil {
+		h.restoreModule(ctx, name, previous)
+		sendCalculationError(w, err)
+		return
+	}
+
+	module, err := h.storage.SaveModule(name, binary, info.Exports, userID)
+	if err != nil {
+		h.restoreModule(ctx, name, previous)
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":     module.Name,
+		"checksum": module.Checksum,
+		"exports":  module.Exports,
+	})
+}
+
+// storedModule returns the stored module of a name, nil if there is none.
+func (h *Handler) storedModule(name string) (*storage.Module, error) {
+	modules, err := h.storage.GetModules()
+	if err != nil {
+		return nil, err
+	}
+	for i := range modules {
+		if modules[i].Name == name {
+			return &modules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// loadModule loads a module on every agent and returns what the agents
+// registered. Unreachable agents are skipped, they get the module when they
+// come back; if none is reachable the module is not loaded anywhere.
+func (h *Handler) loadModule(ctx context.Context, module *pb.WasmModule) (*pb.ModuleInfo, error) {
+	infos := make([]*pb.ModuleInfo, len(h.agents))
+	err := parallel(ctx, len(h.agents), func(ctx context.Context, i int) error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		info, err := h.agents[i].LoadModule(ctx, module)
+		if status.Code(err) == codes.Unavailable {
+			return nil
+		}
+		infos[i] = info
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info != nil {
+			return info, nil
+		}
+	}
+	return nil, status.Error(codes.Unavailable, "no agent is reachable")
+}
+
+// restoreModule puts back the module of a name the agents had before a
+// failed upload: the previous upload, or none at all.
+func (h *Handler) restoreModule(ctx context.Context, name string, previous *storage.Module) {
+	for _, agent := range h.agents {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		var err error
+		if previous != nil {
+			_, err = agent.LoadModule(ctx, &pb.WasmModule{Name: name, Binary: previous.Binary})
+		} else {
+			_, err = agent.UnloadModule(ctx, &pb.WasmModule{Name: name})
+		}
+		cancel()
+		if err != nil && status.Code(err) != codes.Unavailable {
+			log.Printf("Failed to restore module %s on an agent: %v", name, err)
+		}
+	}
+}
+
+func (h *Handler) ListModules(w http.ResponseWriter, r *http.Request) {
+	modules, err := h.storage.GetModules()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	res := make([]map[string]interface{}, len(modules))
+	for i, m := range modules {
+		res[i] = map[string]interface{}{
+			"name":       m.Name,
+			"checksum":   m.Checksum,
+			"exports":    m.Exports,
+			"created_at": m.CreatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"modules": res,
+	})
+}
+
+// SyncModules sends every stored module to an agent. Agents keep loaded
+// modules in memory only, so WatchAgent calls it whenever the connection
+// to an agent comes up.
+func (h *Handler) SyncModules(ctx context.Context, agent pb.CalculatorClient) error {
+	modules, err := h.storage.GetModules()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range modules {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		_, err := agent.LoadModule(ctx, &pb.WasmModule{
+			Name:   m.Name,
+			Binary: m.Binary,
+		})
+		cancel()
+		if err != nil {
+			log.Printf("Failed to load module %s on the agent: %v", m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// WatchAgent pushes the stored modules to the agent of conn each time the
+// connection becomes ready, that is on the first connection and on every
+// reconnection to a restarted or replaced agent. The server watches every
+// agent; it returns when ctx is done.
+func (h *Handler) WatchAgent(ctx context.Context, conn *grpc.ClientConn) {
+	agent := pb.NewCalculatorClient(conn)
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			if err := h.SyncModules(ctx, agent); err != nil {
+				log.Printf("Module sync failed: %v", err)
+			}
+		case connectivity.Idle:
+			conn.Connect()
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"context"
+	"sync"
+)
+
+// parallel runs fn for every index from 0 to n concurrently. Calls made
+// through the handler's calculator are balanced round robin over the
+// agents, so concurrent requests are spread over all of them. The first
+// error cancels the context passed to the remaining calls and is returned.
+func parallel(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var failure error
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					failure = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return failure
+}
+
+// chunkSeed derives the seed of the i-th part of a request split over the
+// agents, so the parts draw different random values while the whole stays
+// reproducible. The first part keeps the request's seed.
+func chunkSeed(seed int64, i int) int64 {
+	if i == 0 {
+		return seed
+	}
+	// One step of splitmix64 spreads consecutive parts far apart.
+	z := uint64(seed) + uint64(i)*0x9E3779B97F4A7C15
+	z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+	z = (z ^ z>>27) * 0x94D049BB133111EB
+	return int64(z ^ z>>31)
+}
@@ -0,0 +1,318 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/calculator"
+	"github.com/opr1234/calculator/internal/models"
+	pb "github.com/opr1234/calculator/proto"
+)
+
+const (
+	defaultSamples = 200
+	maxSamples     = 100000
+	// sampleChunk is the number of points sent to the agents in a single
+	// request. Chunks are sampled concurrently and the calculator balances
+	// them round robin, so large plots are spread over every agent.
+	sampleChunk = 2000
+)
+
+// Plot samples an expression of one variable over a range. It answers with
+// the data series as JSON, or with an SVG chart when format=svg is given or
+// the client accepts only image/svg+xml.
+func (h *Handler) Plot(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+	query := r.URL.Query()
+
+	plot := models.Plot{
+		Expression: query.Get("expr"),
+		Variable:   query.Get("var"),
+		From:       -10,
+		To:         10,
+	}
+	if plot.Expression == "" {
+		sendError(w, http.StatusBadRequest, "Expression is required")
+		return
+	}
+	if plot.Variable == "" {
+		plot.Variable = "x"
+	}
+
+	samples := defaultSamples
+	var err error
+	if v := query.Get("from"); v != "" {
+		plot.From, err = strconv.ParseFloat(v, 64)
+	}
+	if v := query.Get("to"); v != "" && err == nil {
+		plot.To, err = strconv.ParseFloat(v, 64)
+	}
+	if v := query.Get("samples"); v != "" && err == nil {
+		samples, err = strconv.Atoi(v)
+	}
+	if err != nil || !(plot.From < plot.To) || math.IsInf(plot.To-plot.From, 0) {
+		sendError(w, http.StatusBadRequest, "Invalid range")
+		return
+	}
+	if samples < 2 || samples > maxSamples {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("Samples must be between 2 and %d", maxSamples))
+		return
+	}
+
+	timezone := query.Get("timezone")
+	if _, err := time.LoadLocation(timezone); err != nil {
+		sendCalculationError(w, calculator.ErrUnknownTimezone)
+		return
+	}
+	var seed int64
+	if v := query.Get("seed"); v != "" {
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid seed")
+			return
+		}
+	}
+
+	dialect, err := h.dialectFor(userID, query.Get("dialect"))
+	if errors.Is(err, calculator.ErrUnknownDialect) {
+		sendCalculationError(w, calculator.ErrUnknownDialect)
+		return
+	}
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	standard := plot.Expression
+	if dialect != calculator.Standard {
+		if standard, err = calculator.Standardize(plot.Expression, dialect.Name); err != nil {
+			sendCalculationError(w, err)
+			return
+		}
+	}
+
+	svg := query.Get("format") == "svg" || r.Header.Get("Accept") == "image/svg+xml"
+	if !svg {
+		format, err := requestedFormat(r)
+		if err != nil {
+			sendCalculationError(w, calculator.ErrUnknownFormat)
+			return
+		}
+		if format != "" {
+			plot.Rendered, err = calculator.Render(standard, format)
+			if err != nil {
+				sendCalculationError(w, err)
+				return
+			}
+		}
+	}
+
+	functions, err := h.requiredFunctions(userID, standard)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	xs := make([]float64, samples)
+	for i := range xs {
+		xs[i] = plot.From + (plot.To-plot.From)*float64(i)/float64(samples-1)
+	}
+
+	ys, err := h.sample(r.Context(), &pb.SampleRequest{
+		Expression: plot.Expression,
+		Variable:   plot.Variable,
+		Functions:  toFunctionDefinitions(functions),
+		Complex:    query.Get("complex") == "true",
+		Dialect:    dialect.Name,
+		Timezone:   timezone,
+		Seed:       seed,
+	}, xs)
+	if err != nil {
+		sendCalculationError(w, err)
+		return
+	}
+
+	plot.Points = make([]models.PlotPoint, samples)
+	for i, x := range xs {
+		plot.Points[i].X = x
+		if y := ys[i]; !math.IsNaN(y) && !math.IsInf(y, 0) {
+			plot.Points[i].Y = &y
+		}
+	}
+	plot.Discontinuities = []float64{}
+	for _, i := range calculator.Discontinuities(ys) {
+		plot.Discontinuities = append(plot.Discontinuities, (xs[i]+xs[i+1])/2)
+	}
+
+	if svg {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(renderSVG(plot))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plot)
+}
+
+// sample evaluates the request at xs, in chunks sampled concurrently.
+func (h *Handler) sample(ctx context.Context, req *pb.SampleRequest, xs []float64) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	ys := make([]float64, len(xs))
+	chunks := (len(xs) + sampleChunk - 1) / sampleChunk
+	err := parallel(ctx, chunks, func(ctx context.Context, i int) error {
+		start := i * sampleChunk
+		end := start + sampleChunk
+		if end > len(xs) {
+			end = len(xs)
+		}
+
+		chunk := &pb.SampleRequest{
+			Expression: req.Expression,
+			Variable:   req.Variable,
+			Xs:         xs[start:end],
+			Functions:  req.Functions,
+			Complex:    req.Complex,
+			Dialect:    req.Dialect,
+			Timezone:   req.Timezone,
+			Seed:       chunkSeed(req.Seed, i),
+		}
+		res, err := h.calculator.Sample(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		if len(res.Ys) != len(chunk.Xs) {
+			return fmt.Errorf("agent returned %d samples for %d points", len(res.Ys), len(chunk.Xs))
+		}
+		copy(ys[start:], res.Ys)
+		return nil
+	})
+	return ys, err
+}
+
+const (
+	svgWidth  = 640
+	svgHeight = 400
+	svgMargin = 40
+)
+
+// renderSVG draws the plot as a line chart. The curve is broken at
+// undefined points and discontinuities. Outliers near poles are clipped, so
+// they do not flatten the rest of the curve.
+func renderSVG(plot models.Plot) []byte {
+	yMin, yMax := plotRange(plot.Points)
+
+	width := float64(svgWidth - 2*svgMargin)
+	height := float64(svgHeight - 2*svgMargin)
+	px := func(x float64) float64 {
+		return svgMargin + (x-plot.From)/(plot.To-plot.From)*width
+	}
+	py := func(y float64) float64 {
+		return svgMargin + (yMax-y)/(yMax-yMin)*height
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(&buf, `<defs><clipPath id="area"><rect x="%d" y="%d" width="%g" height="%g"/></clipPath></defs>`,
+		svgMargin, svgMargin, width, height)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="white"/>`)
+	fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%g" height="%g" fill="none" stroke="#ccc"/>`,
+		svgMargin, svgMargin, width, height)
+
+	if yMin < 0 && yMax > 0 {
+		fmt.Fprintf(&buf, `<line x1="%d" y1="%.2f" x2="%g" y2="%.2f" stroke="#888"/>`,
+			svgMargin, py(0), svgMargin+width, py(0))
+	}
+	if plot.From < 0 && plot.To > 0 {
+		fmt.Fprintf(&buf, `<line x1="%.2f" y1="%d" x2="%.2f" y2="%g" stroke="#888"/>`,
+			px(0), svgMargin, px(0), svgMargin+height)
+	}
+
+	breaks := make(map[int]bool, len(plot.Discontinuities))
+	d := 0
+	for i := 0; i+1 < len(plot.Points) && d < len(plot.Discontinuities); i++ {
+		if plot.Points[i+1].X > plot.Discontinuities[d] {
+			breaks[i] = true
+			d++
+		}
+	}
+
+	var path strings.Builder
+	pen := false
+	for i, p := range plot.Points {
+		if p.Y == nil {
+			pen = false
+			continue
+		}
+		command := "L"
+		if !pen {
+			command = "M"
+		}
+		fmt.Fprintf(&path, "%s%.2f %.2f ", command, px(p.X), py(clamp(*p.Y, yMin, yMax)))
+		pen = !breaks[i]
+	}
+	fmt.Fprintf(&buf, `<path d="%s" fill="none" stroke="#1f77b4" stroke-width="1.5" clip-path="url(#area)"/>`,
+		strings.TrimSpace(path.String()))
+
+	label := func(x, y float64, anchor, text string) {
+		fmt.Fprintf(&buf, `<text x="%.2f" y="%.2f" font-family="sans-serif" font-size="12" text-anchor="%s">%s</text>`,
+			x, y, anchor, html.EscapeString(text))
+	}
+	label(svgWidth/2, svgMargin/2, "middle", plot.Expression)
+	label(svgMargin, svgHeight-svgMargin/2, "start", formatTick(plot.From))
+	label(svgMargin+width, svgHeight-svgMargin/2, "end", formatTick(plot.To))
+	label(svgMargin-4, svgMargin+4, "end", formatTick(yMax))
+	label(svgMargin-4, svgMargin+height, "end", formatTick(yMin))
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// plotRange picks the y range to draw. When a few values are far outside
+// the bulk of the curve, as next to a pole, the range is cut down to the
+// 5th to 95th percentile with some room around it.
+func plotRange(points []models.PlotPoint) (float64, float64) {
+	var ys []float64
+	for _, p := range points {
+		if p.Y != nil {
+			ys = append(ys, *p.Y)
+		}
+	}
+	if len(ys) == 0 {
+		return -1, 1
+	}
+	sort.Float64s(ys)
+
+	lo, hi := ys[0], ys[len(ys)-1]
+	p5, p95 := ys[len(ys)*5/100], ys[(len(ys)-1)*95/100]
+	if hi-lo > 10*(p95-p5) && p95 > p5 {
+		lo, hi = p5-(p95-p5)/2, p95+(p95-p5)/2
+	}
+	if hi == lo {
+		return lo - 1, hi + 1
+	}
+	pad := (hi - lo) * 0.05
+	return lo - pad, hi + pad
+}
+
+func clamp(v, lo, hi float64) float64 {
+	// Points are drawn slightly outside the visible area, so the clip
+	// path cuts the line at the border instead of flattening it.
+	span := hi - lo
+	return math.Max(lo-span, math.Min(hi+span, v))
+}
+
+func formatTick(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
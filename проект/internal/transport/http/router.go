@@ -1,35 +1,47 @@
-package http
-
-import (
-    "net/http"
-    
-    "github.com/gorilla/mux"
-    "github.com/opr1234/calculator/internal/auth"
-)
-
-func NewRouter(h *Handler, authMiddleware mux.MiddlewareFunc) *mux.Router {
-    r := mux.NewRouter()
-    
-    r.Use(mux.CORSMethodMiddleware(r))
-    r.Use(func(next http.Handler) http.Handler {
-        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-            w.Header().Set("Access-Control-Allow-Origin", "*")
-            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-            next.ServeHTTP(w, r)
-        }
-    })
-
-    public := r.PathPrefix("/api/v1").Subrouter()
-    public.HandleFunc("/register", h.Register).Methods("POST", "OPTIONS")
-    public.HandleFunc("/login", h.Login).Methods("POST", "OPTIONS")
-
-    protected := r.PathPrefix("/api/v1").Subrouter()
-    protected.Use(authMiddleware)
-    protected.HandleFunc("/calculate", h.Calculate).Methods("POST", "OPTIONS")
-
-    r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        sendError(w, http.StatusNotFound, "Endpoint not found")
-    })
-
-    return r
-}
+package http
+
+import (
+    "net/http"
+    
+    "github.com/gorilla/mux"
+    "github.com/opr1234/calculator/internal/auth"
+)
+
+func NewRouter(h *Handler, authMiddleware mux.MiddlewareFunc) *mux.Router {
+    r := mux.NewRouter()
+    
+    r.Use(mux.CORSMethodMiddleware(r))
+    r.Use(func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Access-Control-Allow-Origin", "*")
+            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+            next.ServeHTTP(w, r)
+        }
+    })
+
+    public := r.PathPrefix("/api/v1").Subrouter()
+    public.HandleFunc("/register", h.Register).Methods("POST", "OPTIONS")
+    public.HandleFunc("/login", h.Login).Methods("POST", "OPTIONS")
+
+    protected := r.PathPrefix("/api/v1").Subrouter()
+    protected.Use(authMiddleware)
+    protected.HandleFunc("/calculate", h.Calculate).Methods("POST", "OPTIONS")
+    protected.HandleFunc("/expressions", h.ListExpressions).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/expressions/{id}", h.GetExpression).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/expressions/{id}/cancel", h.CancelExpression).Methods("POST", "OPTIONS")
+    protected.HandleFunc("/plot", h.Plot).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/convert", h.Convert).Methods("POST", "OPTIONS")
+    protected.HandleFunc("/settings", h.GetSettings).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/settings", h.UpdateSettings).Methods("PUT", "OPTIONS")
+    protected.HandleFunc("/functions", h.CreateFunction).Methods("POST", "OPTIONS")
+    protected.HandleFunc("/functions", h.ListFunctions).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/functions/{name}", h.GetFunction).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/modules", h.ListModules).Methods("GET", "OPTIONS")
+    protected.HandleFunc("/modules/{name}", h.UploadModule).Methods("PUT", "OPTIONS")
+
+    r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        sendError(w, http.StatusNotFound, "Endpoint not found")
+    })
+
+    return r
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/opr1234/calculator/internal/calculator"
+	pb "github.com/opr1234/calculator/proto"
+	"google.golang.org/grpc"
+)
+
+const (
+	// seriesChunk is the number of terms of a sum or prod evaluated by a
+	// single agent request.
+	seriesChunk = 250000
+	// maxSeriesParts bounds the number of requests a series is split into.
+	maxSeriesParts = 64
+)
+
+// evaluate sends an expression to the agents. A sum or prod over a large
+// range is split into ranges evaluated concurrently, and the partial
+// results are combined by one more request. Each range gets a seed of its
+// own, so random terms do not repeat from one range to the next. The call
+// options apply to the request giving the result, not to the partial ones.
+func (h *Handler) evaluate(ctx context.Context, req *pb.ExpressionRequest, opts ...grpc.CallOption) (*pb.ExpressionResponse, error) {
+	if req.Notation != "" && calculator.Notation(req.Notation) != calculator.NotationInfix {
+		return h.calculator.Evaluate(ctx, req, opts...)
+	}
+	parts, op, ok := calculator.SplitSeries(req.Expression, seriesChunk)
+	if !ok || len(parts) > maxSeriesParts {
+		return h.calculator.Evaluate(ctx, req, opts...)
+	}
+
+	results := make([]*pb.ExpressionResponse, len(parts))
+	err := parallel(ctx, len(parts), func(ctx context.Context, i int) error {
+		res, err := h.calculator.Evaluate(ctx, &pb.ExpressionRequest{
+			Expression: parts[i],
+			UserId:     req.UserId,
+			Functions:  req.Functions,
+			Complex:    req.Complex,
+			Dialect:    req.Dialect,
+			Timezone:   req.Timezone,
+			Seed:       chunkSeed(req.Seed, i),
+		})
+		results[i] = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	literals := make([]string, len(results))
+	for i, res := range results {
+		if res.Error != "" {
+			return res, nil
+		}
+		if literals[i], ok = literal(res); !ok {
+			// The partial result cannot be written back into an
+			// expression, so evaluate the series in one piece.
+			return h.calculator.Evaluate(ctx, req, opts...)
+		}
+	}
+
+	return h.calculator.Evaluate(ctx, &pb.ExpressionRequest{
+		Expression: strings.Join(literals, " "+op+" "),
+		UserId:     req.UserId,
+		Complex:    req.Complex,
+	}, opts...)
+}
+
+// literal writes a result as an expression that evaluates to it again.
+func literal(res *pb.ExpressionResponse) (string, bool) {
+	format := func(values ...float64) (string, bool) {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				return "", false
+			}
+			parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strings.Join(parts, ", "), true
+	}
+
+	switch res.Kind {
+	case "", "number":
+		return format(res.Result)
+	case "integer":
+		return res.Exact, res.Exact != ""
+	case "complex":
+		re, ok1 := format(res.Result)
+		im, ok2 := format(res.Imag)
+		return "(" + re + "+(" + im + "i))", ok1 && ok2
+	case "uncertain":
+		v, ok1 := format(res.Result)
+		u, ok2 := format(res.Uncertainty)
+		return "(" + v + " ± " + u + ")", ok1 && ok2
+	case "interval":
+		if len(res.Values) != 2 {
+			return "", false
+		}
+		lo, ok1 := format(res.Values[0])
+		hi, ok2 := format(res.Values[1])
+		return "[" + lo + " .. " + hi + "]", ok1 && ok2
+	case "list":
+		values, ok := format(res.Values...)
+		return "[" + values + "]", ok
+	case "matrix":
+		rows := make([]string, len(res.Matrix))
+		for i, row := range res.Matrix {
+			values, ok := format(row.Values...)
+			if !ok {
+				return "", false
+			}
+			rows[i] = "[" + values + "]"
+		}
+		return "[" + strings.Join(rows, ", ") + "]", true
+	}
+	return "", false
+}
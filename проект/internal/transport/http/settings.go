@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/opr1234/calculator/internal/auth"
+	"github.com/opr1234/calculator/internal/calculator"
+	"github.com/opr1234/calculator/internal/models"
+)
+
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	user, err := h.storage.GetUserByID(userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Settings{Dialect: user.Dialect})
+}
+
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.ContextKeyUserID).(int)
+
+	var req models.Settings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	d, err := calculator.LookupDialect(req.Dialect)
+	if err != nil {
+		sendCalculationError(w, calculator.ErrUnknownDialect)
+		return
+	}
+
+	if err := h.storage.SetUserDialect(userID, d.Name); err != nil {
+		sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Settings{Dialect: d.Name})
+}
+
+// dialectFor returns the dialect a request is written in: the one it
+// names, or the user's default.
+func (h *Handler) dialectFor(userID int, requested string) (*calculator.Dialect, error) {
+	if requested != "" {
+		return calculator.LookupDialect(requested)
+	}
+	user, err := h.storage.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return calculator.LookupDialect(user.Dialect)
+}
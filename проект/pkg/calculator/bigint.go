@@ -0,0 +1,474 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrNotInteger = errors.New("operand must be an integer")
+	ErrNegative   = errors.New("operand must not be negative")
+	ErrTooLarge   = errors.New("operand is too large")
+)
+
+const KindInteger Kind = "integer"
+
+const (
+	// maxExactFloat is the largest magnitude below which every integer is
+	// exactly representable as a float64.
+	maxExactFloat = 1 << 53
+	// maxFactorial bounds n! so a single expression cannot run for minutes.
+	maxFactorial = 20000
+	// maxExponent bounds exact integer powers for the same reason.
+	maxExponent = 100000
+	// maxResultBits bounds the size of an exact result. Products and
+	// powers are checked against it before they are computed, so chains
+	// such as (2^100000)^100000 fail instead of exhausting memory.
+	maxResultBits = 1 << 20
+	// factorialChunk is how many factors n! multiplies between checks of
+	// the context.
+	factorialChunk = 1000
+	// maxTrialDivisor is how far factor divides huge values by small
+	// numbers before giving up.
+	maxTrialDivisor = 100000
+	// checkInterval is how many trial divisors factor tries between checks
+	// of the context.
+	checkInterval = 1 << 12
+)
+
+// Integer is an arbitrary-precision integer. Functions such as n!, nCr and
+// powmod produce one; results small enough to be exact as float64 become
+// plain numbers again.
+type Integer struct {
+	*big.Int
+}
+
+func (i Integer) Kind() Kind { return KindInteger }
+
+func (i Integer) String() string { return i.Int.String() }
+
+// Digits returns the number of decimal digits, not counting the sign.
+func (i Integer) Digits() int {
+	return len(new(big.Int).Abs(i.Int).String())
+}
+
+// Float returns the nearest float64, which is infinite when the integer
+// is beyond its range.
+func (i Integer) Float() float64 {
+	f, _ := new(big.Float).SetInt(i.Int).Float64()
+	return f
+}
+
+// IntegerList is a list of exact integers, such as the prime factors of a
+// number. It prints every digit; as an operand it is a plain List.
+type IntegerList []Integer
+
+func (l IntegerList) Kind() Kind { return KindList }
+
+func (l IntegerList) String() string {
+	parts := make([]string, len(l))
+	for i, v := range l {
+		parts[i] = v.String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// List returns the nearest float64 of every element.
+func (l IntegerList) List() List {
+	res := make(List, len(l))
+	for i, v := range l {
+		res[i] = v.Float()
+	}
+	return res
+}
+
+// plainList turns an IntegerList into a List and leaves other values as
+// they are, for operators and functions that work in floating point.
+func plainList(v Value) Value {
+	if l, ok := v.(IntegerList); ok {
+		return l.List()
+	}
+	return v
+}
+
+func normalizeInteger(x *big.Int) Value {
+	if x.CmpAbs(big.NewInt(maxExactFloat)) <= 0 {
+		return Number(x.Int64())
+	}
+	return Integer{x}
+}
+
+// toBigInt converts an integral number to a big integer.
+func toBigInt(v Value) (*big.Int, error) {
+	switch v := v.(type) {
+	case Integer:
+		return v.Int, nil
+	case Number:
+		f := float64(v)
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return nil, ErrNotInteger
+		}
+		x, _ := big.NewFloat(f).Int(nil)
+		return x, nil
+	}
+	return nil, ErrTypeMismatch
+}
+
+func isIntegral(v Value) bool {
+	_, err := toBigInt(v)
+	return err == nil
+}
+
+// integerOperator applies a binary operator exactly when both operands
+// are integers and the result is one too, and in floating point otherwise.
+func integerOperator(op string, a, b Value) (Value, error) {
+	x, errA := toBigInt(a)
+	y, errB := toBigInt(b)
+	if errors.Is(errA, ErrTypeMismatch) || errors.Is(errB, ErrTypeMismatch) {
+		return nil, ErrTypeMismatch
+	}
+	if errA != nil || errB != nil {
+		res, err := arithmetic(op, toFloat(a), toFloat(b))
+		return Number(res), err
+	}
+
+	switch op {
+	case "+":
+		return normalizeInteger(new(big.Int).Add(x, y)), nil
+	case "-":
+		return normalizeInteger(new(big.Int).Sub(x, y)), nil
+	case "*":
+		if x.BitLen()+y.BitLen() > maxResultBits {
+			return nil, ErrTooLarge
+		}
+		return normalizeInteger(new(big.Int).Mul(x, y)), nil
+	case "/":
+		if y.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+		if r.Sign() == 0 {
+			return normalizeInteger(q), nil
+		}
+		res, err := arithmetic(op, toFloat(a), toFloat(b))
+		return Number(res), err
+	case "^":
+		if y.Sign() < 0 {
+			res, err := arithmetic(op, toFloat(a), toFloat(b))
+			return Number(res), err
+		}
+		if !y.IsInt64() || y.Int64() > maxExponent {
+			return nil, ErrTooLarge
+		}
+		// |x| < 2^BitLen, so x^y has at most BitLen * y bits.
+		if x.BitLen() > 1 && int64(x.BitLen())*y.Int64() > maxResultBits {
+			return nil, ErrTooLarge
+		}
+		return normalizeInteger(new(big.Int).Exp(x, y, nil)), nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s", op)
+}
+
+// exactArithmetic reports whether an operation between plain integral
+// numbers should be computed exactly. The decision is made on the operands,
+// never on a float64 result that may already be rounded: sums of integers
+// below 2^52 and products of integers below 2^26 are exact in float64,
+// anything larger and every power is computed on big integers.
+func exactArithmetic(op string, a, b Number) bool {
+	if !isIntegral(a) || !isIntegral(b) {
+		return false
+	}
+	x, y := math.Abs(float64(a)), math.Abs(float64(b))
+	switch op {
+	case "+", "-":
+		return x >= 1<<52 || y >= 1<<52
+	case "*":
+		return x >= 1<<26 || y >= 1<<26
+	case "^":
+		return b >= 0
+	}
+	return false
+}
+
+func toFloat(v Value) float64 {
+	switch v := v.(type) {
+	case Number:
+		return float64(v)
+	case Integer:
+		return v.Float()
+	}
+	return math.NaN()
+}
+
+// factorial computes n! a chunk of factors at a time and stops with the
+// context's error once it is done. maxFactorial keeps n! well within
+// maxResultBits.
+func factorial(ctx context.Context, v Value) (Value, error) {
+	n, err := toBigInt(v)
+	if err != nil {
+		return nil, err
+	}
+	if n.Sign() < 0 {
+		return nil, ErrNegative
+	}
+	if !n.IsInt64() || n.Int64() > maxFactorial {
+		return nil, ErrTooLarge
+	}
+
+	res := big.NewInt(1)
+	for lo := int64(1); lo <= n.Int64(); lo += factorialChunk {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hi := min(lo+factorialChunk-1, n.Int64())
+		res.Mul(res, new(big.Int).MulRange(lo, hi))
+	}
+	return normalizeInteger(res), nil
+}
+
+// integerFunctions are the number theory built-ins. isprime, factor and
+// powmod can run long on huge operands, so they check ctx as they go and
+// return its error once it is done.
+func integerFunctions(ctx context.Context) map[string]Function {
+	return map[string]Function{
+		"nCr":     {MinArgs: 2, MaxArgs: 2, Call: choose},
+		"nPr":     {MinArgs: 2, MaxArgs: 2, Call: permutations},
+		"gcd":     {MinArgs: 1, MaxArgs: -1, Call: gcd},
+		"lcm":     {MinArgs: 1, MaxArgs: -1, Call: lcm},
+		"isprime": {MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) { return isPrime(ctx, args) }},
+		"factor":  {MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) { return factor(ctx, args) }},
+		"powmod":  {MinArgs: 3, MaxArgs: 3, Call: func(args []Value) (Value, error) { return powmod(ctx, args) }},
+	}
+}
+
+func integerArgs(args []Value) ([]*big.Int, error) {
+	res := make([]*big.Int, len(args))
+	for i, arg := range args {
+		x, err := toBigInt(arg)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = x
+	}
+	return res, nil
+}
+
+// rangeArgs validates the n and r of nCr and nPr.
+func rangeArgs(args []Value) (int64, int64, error) {
+	xs, err := integerArgs(args)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, r := xs[0], xs[1]
+	if n.Sign() < 0 || r.Sign() < 0 {
+		return 0, 0, ErrNegative
+	}
+	if !n.IsInt64() || n.Int64() > maxFactorial {
+		return 0, 0, ErrTooLarge
+	}
+	if r.Cmp(n) > 0 {
+		return n.Int64(), -1, nil
+	}
+	return n.Int64(), r.Int64(), nil
+}
+
+func choose(args []Value) (Value, error) {
+	n, r, err := rangeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if r < 0 {
+		return Number(0), nil
+	}
+	return normalizeInteger(new(big.Int).Binomial(n, r)), nil
+}
+
+func permutations(args []Value) (Value, error) {
+	n, r, err := rangeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if r < 0 {
+		return Number(0), nil
+	}
+	if r == 0 {
+		return Number(1), nil
+	}
+	return normalizeInteger(new(big.Int).MulRange(n-r+1, n)), nil
+}
+
+func gcd(args []Value) (Value, error) {
+	xs, err := integerArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	res := new(big.Int).Abs(xs[0])
+	for _, x := range xs[1:] {
+		res.GCD(nil, nil, res, new(big.Int).Abs(x))
+	}
+	return normalizeInteger(res), nil
+}
+
+func lcm(args []Value) (Value, error) {
+	xs, err := integerArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	res := new(big.Int).Abs(xs[0])
+	for _, x := range xs[1:] {
+		x = new(big.Int).Abs(x)
+		if res.Sign() == 0 || x.Sign() == 0 {
+			res.SetInt64(0)
+			continue
+		}
+		g := new(big.Int).GCD(nil, nil, res, x)
+		res.Mul(res, x).Quo(res, g)
+	}
+	return normalizeInteger(res), nil
+}
+
+// millerRabinBases are the bases isprime tests numbers beyond 64 bits
+// with, one Miller-Rabin round each.
+var millerRabinBases = []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71}
+
+// isPrime is exact below 2^64, where ProbablyPrime is. Larger numbers get a
+// Miller-Rabin round per base in millerRabinBases, with the context checked
+// between rounds and during their exponentiations.
+func isPrime(ctx context.Context, args []Value) (Value, error) {
+	n, err := toBigInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if n.BitLen() <= 64 {
+		return primality(n.ProbablyPrime(0)), nil
+	}
+	if n.Bit(0) == 0 {
+		return primality(false), nil
+	}
+
+	// n - 1 = d * 2^s with d odd.
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(n, one)
+	s := nMinusOne.TrailingZeroBits()
+	d := new(big.Int).Rsh(nMinusOne, s)
+	for _, base := range millerRabinBases {
+		x, err := expMod(ctx, big.NewInt(base), d, n)
+		if err != nil {
+			return nil, err
+		}
+		if x.Cmp(one) == 0 || x.Cmp(nMinusOne) == 0 {
+			continue
+		}
+		witness := true
+		for i := uint(1); i < s && witness; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			x.Mul(x, x).Mod(x, n)
+			witness = x.Cmp(nMinusOne) != 0
+		}
+		if witness {
+			return primality(false), nil
+		}
+	}
+	return primality(true), nil
+}
+
+func primality(prime bool) Value {
+	if prime {
+		return Number(1)
+	}
+	return Number(0)
+}
+
+// factor returns the exact prime factors of n in ascending order, with
+// repetitions. Small primes are divided out exactly first, so values such as
+// 100! work as long as what remains fits a float64.
+func factor(ctx context.Context, args []Value) (Value, error) {
+	n, err := toBigInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if n.Sign() <= 0 {
+		return nil, ErrNegative
+	}
+
+	factors := IntegerList{}
+	rest := new(big.Int).Set(n)
+	mod := new(big.Int)
+	for p := int64(2); p <= maxTrialDivisor && rest.BitLen() > 53; p++ {
+		if p%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		bp := big.NewInt(p)
+		for mod.Mod(rest, bp).Sign() == 0 {
+			factors = append(factors, Integer{bp})
+			rest.Quo(rest, bp)
+		}
+	}
+	if rest.BitLen() > 53 {
+		return nil, ErrTooLarge
+	}
+
+	// Trial division stopped before any prime left in rest, so these
+	// factors come out in ascending order too.
+	m := rest.Uint64()
+	for p := uint64(2); p*p <= m; p++ {
+		if p%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		for m%p == 0 {
+			factors = append(factors, Integer{new(big.Int).SetUint64(p)})
+			m /= p
+		}
+	}
+	if m > 1 {
+		factors = append(factors, Integer{new(big.Int).SetUint64(m)})
+	}
+	return factors, nil
+}
+
+func powmod(ctx context.Context, args []Value) (Value, error) {
+	xs, err := integerArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	base, exp, mod := xs[0], xs[1], xs[2]
+	if mod.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	if exp.Sign() < 0 {
+		return nil, ErrNegative
+	}
+	res, err := expMod(ctx, base, exp, new(big.Int).Abs(mod))
+	if err != nil {
+		return nil, err
+	}
+	return normalizeInteger(res), nil
+}
+
+// expMod computes x^y mod m for y >= 0 and m > 0 by squaring and
+// multiplying a bit of the exponent at a time, from the top, checking ctx
+// at every bit, so a huge exponent or modulus cannot outlast ctx.
+func expMod(ctx context.Context, x, y, m *big.Int) (*big.Int, error) {
+	base := new(big.Int).Mod(x, m)
+	res := new(big.Int).Mod(big.NewInt(1), m)
+	for i := y.BitLen() - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res.Mul(res, res).Mod(res, m)
+		if y.Bit(i) == 1 {
+			res.Mul(res, base).Mod(res, m)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestIntegers(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "2^100", want: "1267650600228229401496703205376"},
+		{expr: "2^100 - 2^100 + 1", want: "1"},
+		{expr: "25!", want: "15511210043330985984000000"},
+		{expr: "(3 + 2)!", want: "120"},
+		{expr: "nCr(10, 3)", want: "120"},
+		{expr: "nPr(5, 2)", want: "20"},
+		{expr: "gcd(12, 18, 30)", want: "6"},
+		{expr: "lcm(4, 6)", want: "12"},
+		{expr: "isprime(97)", want: "1"},
+		{expr: "isprime(2^61 - 1)", want: "1"},
+		{expr: "powmod(3, 200, 1000)", want: "1"},
+		{expr: "(-1)!", err: ErrNegative},
+		{expr: "2.5!", err: ErrNotInteger},
+		{expr: "gcd(1.5, 3)", err: ErrNotInteger},
+	})
+}
+
+func TestFactor(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "factor(360)", want: "[2, 2, 2, 3, 3, 5]"},
+		{expr: "factor(97)", want: "[97]"},
+		{expr: "factor(1)", want: "[]"},
+		{expr: "factor(2^60 * 9007199254740881)", want: "[2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, " +
+			"2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, " +
+			"9007199254740881]"},
+		{expr: "prod(factor(360))", want: "360"},
+		{expr: "factor(12) * 2", want: "[4, 4, 6]"},
+		{expr: "factor(0)", err: ErrNegative},
+		{expr: "factor(2.5)", err: ErrNotInteger},
+	})
+
+	res, err := NewEvaluator().Evaluate(context.Background(), "factor(2^53 - 111)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	factors, ok := res.Value.(IntegerList)
+	if !ok || len(factors) != 1 || factors[0].Int.Int64() != 9007199254740881 {
+		t.Errorf("factor(2^53 - 111) = %#v, want the exact prime", res.Value)
+	}
+}
+
+func TestExactIntegerArithmetic(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "2^53 + 1", want: "9007199254740993"},
+		{expr: "2^53 - 1 + 2", want: "9007199254740993"},
+		{expr: "2^53 + 1 - 2^53", want: "1"},
+		{expr: "94906267 * 94906267", want: "9007199515875289"},
+		{expr: "3^34", want: "16677181699666569"},
+		{expr: "2^53 + 0.5", want: "9.007199254740992e+15"},
+		{expr: "(2^100000)^100000", err: ErrTooLarge},
+		{expr: "f = 20000!; f * f * f * f * f", err: ErrTooLarge},
+		{expr: "(20000!)!", err: ErrTooLarge},
+	})
+}
+
+func TestLargePrimes(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "isprime(2^89 - 1)", want: "1"},
+		{expr: "isprime(2^127 - 1)", want: "1"},
+		{expr: "isprime(2^67 - 1)", want: "0"},
+		{expr: "isprime((2^61 - 1) * (2^31 - 1))", want: "0"},
+		{expr: "isprime(2^100)", want: "0"},
+		{expr: "powmod(3, 2^100 + 5, 1000003)", want: new(big.Int).Exp(big.NewInt(3),
+			new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 100), big.NewInt(5)), big.NewInt(1000003)).String()},
+		{expr: "powmod(-2, 3, 7)", want: "6"},
+		{expr: "powmod(5, 0, 1)", want: "0"},
+	})
+}
+
+// TestIntegerTimeout checks that the long integer computations stop when
+// the evaluation runs out of time rather than at their end.
+func TestIntegerTimeout(t *testing.T) {
+	e := NewEvaluator(WithLimits(Limits{Timeout: 50 * time.Millisecond}))
+	for _, expr := range []string{
+		"isprime(20000! + 1)",
+		"powmod(3, 20000!, 19999! + 1)",
+		"factor(2^53 - 111)",
+	} {
+		start := time.Now()
+		_, err := e.Evaluate(context.Background(), expr)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: error = %v, want the deadline", expr, err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("%s ran for %v after its deadline", expr, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+var ErrDomain = errors.New("result is not a real number")
+
+// imaginaryUnit marks imaginary literals such as 4i.
+const imaginaryUnit = 'i'
+
+const KindComplex Kind = "complex"
+
+type Complex complex128
+
+func (c Complex) Kind() Kind { return KindComplex }
+
+func (c Complex) String() string {
+	if real(c) == 0 {
+		return Number(imag(c)).String() + string(imaginaryUnit)
+	}
+	return strings.Trim(strconv.FormatComplex(complex128(c), 'g', -1, 128), "()")
+}
+
+// normalizeComplex drops a zero imaginary part, so that (1+2i)*(1-2i) is
+// the plain number 5.
+func normalizeComplex(c complex128) Value {
+	if imag(c) == 0 {
+		return Number(real(c))
+	}
+	return Complex(c)
+}
+
+func toComplex(v Value) (complex128, bool) {
+	switch v := v.(type) {
+	case Number:
+		return complex(float64(v), 0), true
+	case Integer:
+		return complex(v.Float(), 0), true
+	case Complex:
+		return complex128(v), true
+	}
+	return 0, false
+}
+
+// complexOperator applies a binary operator to numbers treated as complex.
+func complexOperator(op string, a, b Value) (Value, error) {
+	x, ok := toComplex(a)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	y, ok := toComplex(b)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+
+	switch op {
+	case "+":
+		return normalizeComplex(x + y), nil
+	case "-":
+		return normalizeComplex(x - y), nil
+	case "*":
+		return normalizeComplex(x * y), nil
+	case "/":
+		if y == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return normalizeComplex(x / y), nil
+	case "^":
+		return normalizeComplex(complexPow(x, y)), nil
+	}
+	return nil, ErrTypeMismatch
+}
+
+// complexPow multiplies out small integer powers, which keeps results such
+// as i^2 exact where the polar form of cmplx.Pow would leave rounding noise.
+func complexPow(x, y complex128) complex128 {
+	n := real(y)
+	if imag(y) != 0 || n != math.Trunc(n) || math.Abs(n) > 64 {
+		return cmplx.Pow(x, y)
+	}
+
+	res := complex(1, 0)
+	for i := 0; i < int(math.Abs(n)); i++ {
+		res *= x
+	}
+	if n < 0 {
+		return 1 / res
+	}
+	return res
+}
+
+// complexFallbacks are used in complex mode when the real version of a
+// function has no real result, such as sqrt(-1).
+var complexFallbacks = map[string]func(args []Value) (Value, error){
+	"sqrt": complexFunction(cmplx.Sqrt),
+	"ln":   complexFunction(cmplx.Log),
+}
+
+func complexFunction(fn func(complex128) complex128) func(args []Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		x, ok := toComplex(args[0])
+		if !ok {
+			return nil, ErrTypeMismatch
+		}
+		return normalizeComplex(fn(x)), nil
+	}
+}
+
+func complexFunctions() map[string]Function {
+	unary := func(fn func(complex128) Value) Function {
+		return Function{MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) {
+			x, ok := toComplex(args[0])
+			if !ok {
+				return nil, ErrTypeMismatch
+			}
+			return fn(x), nil
+		}}
+	}
+
+	return map[string]Function{
+		"re":   unary(func(x complex128) Value { return Number(real(x)) }),
+		"im":   unary(func(x complex128) Value { return Number(imag(x)) }),
+		"arg":  unary(func(x complex128) Value { return Number(cmplx.Phase(x)) }),
+		"conj": unary(func(x complex128) Value { return normalizeComplex(cmplx.Conj(x)) }),
+		"abs": {MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) {
+			if x, ok := args[0].(Complex); ok {
+				return Number(cmplx.Abs(complex128(x))), nil
+			}
+			return absolute.call(args)
+		}},
+		"sqrt": squareRoot.function(),
+	}
+}
+
+// parseNumber parses a numeric literal, real, imaginary or a big integer.
+func parseNumber(text string) (Value, error) {
+	if strings.HasSuffix(text, string(imaginaryUnit)) {
+		num, err := strconv.ParseFloat(strings.TrimSuffix(text, string(imaginaryUnit)), 64)
+		if err != nil {
+			return nil, ErrInvalidExpression
+		}
+		return Complex(complex(0, num)), nil
+	}
+
+	num, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, ErrInvalidExpression
+	}
+	if math.Abs(num) > maxExactFloat && !strings.Contains(text, ".") {
+		// Long integer literals, such as results of an earlier exact
+		// computation, keep all their digits.
+		if x, ok := new(big.Int).SetString(text, 10); ok {
+			return normalizeInteger(x), nil
+		}
+	}
+	return Number(num), nil
+}
@@ -0,0 +1,46 @@
+package calculator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestComplex(t *testing.T) {
+	complexMode := Options{Complex: true}
+	runEvalTests(t, []evalTest{
+		{expr: "2 + 3i", opts: complexMode, want: "2+3i"},
+		{expr: "(1+2i) * (1-2i)", opts: complexMode, want: "5"},
+		{expr: "(1+2i) / (1-2i)", opts: complexMode, want: "-0.6+0.8i"},
+		{expr: "1i^2", opts: complexMode, want: "-1"},
+		{expr: "sqrt(-4)", opts: complexMode, want: "2i"},
+		{expr: "sqrt(4)", opts: complexMode, want: "2"},
+		{expr: "re(3+4i)", opts: complexMode, want: "3"},
+		{expr: "im(3+4i)", opts: complexMode, want: "4"},
+		{expr: "abs(3+4i)", opts: complexMode, want: "5"},
+		{expr: "conj(3+4i)", opts: complexMode, want: "3-4i"},
+		{expr: "ln(-1)", opts: complexMode, want: "3.141592653589793i"},
+		{expr: "arg(-1)", opts: complexMode, want: "3.141592653589793"},
+		{expr: "i^2", opts: complexMode, want: "-1"},
+		{expr: "(1+2i)*(3-i)", want: "5+5i"},
+		{expr: "re(exp(i*pi))", want: "-1"},
+		{expr: "arg(i)", want: "1.5707963267948966"},
+		{expr: "i = 2; i^2", want: "4"},
+		{expr: "sqrt(-4)", err: ErrDomain},
+		{expr: "ln(-1)", err: ErrDomain},
+	})
+}
+
+// TestEulerIdentity checks exp(i*pi) = -1 up to the rounding of pi.
+func TestEulerIdentity(t *testing.T) {
+	res, err := NewEvaluator().Evaluate(context.Background(), "exp(i*pi)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := res.Value.(Complex)
+	if !ok {
+		t.Fatalf("exp(i*pi) = %s, want a complex number", res.Value)
+	}
+	if !closeTo(real(c), -1, 1e-15) || !closeTo(imag(c), 0, 1e-15) {
+		t.Errorf("exp(i*pi) = %s, want -1", c)
+	}
+}
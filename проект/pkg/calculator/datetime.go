@@ -0,0 +1,499 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidDate     = errors.New("invalid date or time")
+	ErrInvalidDuration = errors.New("invalid duration")
+	ErrUnknownTimezone = errors.New("unknown timezone")
+)
+
+const (
+	KindDateTime Kind = "datetime"
+	KindDuration Kind = "duration"
+)
+
+// DateTime is a point in time. A date without a time of day, such as
+// 2026-10-18, is midnight in the evaluation's timezone and is written back
+// without one.
+type DateTime struct {
+	Time     time.Time
+	DateOnly bool
+}
+
+func (d DateTime) Kind() Kind { return KindDateTime }
+
+// String writes the value as ISO 8601.
+func (d DateTime) String() string {
+	if d.DateOnly {
+		return d.Time.Format("2006-01-02")
+	}
+	return d.Time.Format(time.RFC3339Nano)
+}
+
+// Duration is a span of calendar months and days plus a fixed time. Months
+// and days are kept apart from the time because their length depends on
+// the date they are added to: 2026-01-31 + 1mo is 2026-02-28 and a day
+// across a daylight saving change has 23 or 25 hours.
+type Duration struct {
+	Months int
+	Days   int
+	Time   time.Duration
+}
+
+func (d Duration) Kind() Kind { return KindDuration }
+
+// String writes the value as an ISO 8601 duration, P1Y2M10DT2H30M.
+func (d Duration) String() string {
+	var b strings.Builder
+	if d.Months <= 0 && d.Days <= 0 && d.Time <= 0 && d != (Duration{}) {
+		b.WriteString("-")
+		d = d.scaleInt(-1)
+	}
+	b.WriteString("P")
+	if y := d.Months / 12; y != 0 {
+		fmt.Fprintf(&b, "%dY", y)
+	}
+	if m := d.Months % 12; m != 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if d.Days != 0 {
+		fmt.Fprintf(&b, "%dD", d.Days)
+	}
+	if d.Time != 0 {
+		b.WriteString("T")
+		h := d.Time / time.Hour
+		m := (d.Time % time.Hour) / time.Minute
+		s := d.Time % time.Minute
+		if h != 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m != 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s != 0 {
+			b.WriteString(strconv.FormatFloat(s.Seconds(), 'f', -1, 64) + "S")
+		}
+	}
+	if d == (Duration{}) {
+		b.WriteString("T0S")
+	}
+	return b.String()
+}
+
+// Seconds returns the length of a duration without months, counting a day
+// as 24 hours.
+func (d Duration) Seconds() (float64, bool) {
+	if d.Months != 0 {
+		return 0, false
+	}
+	return float64(d.Days)*secondsPerDay + d.Time.Seconds(), true
+}
+
+const secondsPerDay = 24 * 60 * 60
+
+func (d Duration) scaleInt(n int) Duration {
+	return Duration{Months: d.Months * n, Days: d.Days * n, Time: d.Time * time.Duration(n)}
+}
+
+// scale multiplies a duration by f. Fractions of a day become time;
+// fractions of a month have no length and are refused.
+func (d Duration) scale(f float64) (Duration, error) {
+	months := float64(d.Months) * f
+	if months != math.Trunc(months) {
+		return Duration{}, fmt.Errorf("%w: fraction of a month", ErrInvalidDuration)
+	}
+	days := float64(d.Days) * f
+	whole := math.Trunc(days)
+	t := float64(d.Time)*f + (days-whole)*secondsPerDay*float64(time.Second)
+	if math.IsInf(t, 0) || math.Abs(t) > math.MaxInt64 {
+		return Duration{}, ErrTooLarge
+	}
+	return Duration{Months: int(months), Days: int(whole), Time: time.Duration(math.Round(t))}, nil
+}
+
+// Date and time literals follow ISO 8601: 2026-10-18, 2026-10-18T09:30 and
+// 2026-10-18T09:30:00+03:00. Duration literals are numbers with units,
+// written together: 90d, 3h 20m, 1y6mo, 1.5h.
+var (
+	dateLiteral     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(?:T\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:\d{2})?)?`)
+	durationLiteral = regexp.MustCompile(`^(?:\d+(?:\.\d+)?(?:min|mo|ms|y|w|d|h|m|s))+`)
+	durationPart    = regexp.MustCompile(`(\d+(?:\.\d+)?)(min|mo|ms|y|w|d|h|m|s)`)
+)
+
+var dateLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+}
+
+// temporalLength returns the length of the date or duration literal s
+// starts with, or 0.
+func temporalLength(s []rune) int {
+	text := string(s)
+	for _, re := range []*regexp.Regexp{dateLiteral, durationLiteral} {
+		m := re.FindString(text)
+		if m == "" {
+			continue
+		}
+		n := len([]rune(m))
+		if n < len(s) && (isLetter(s[n]) || isDigit(s[n]) || s[n] == '.') {
+			continue
+		}
+		return n
+	}
+	return 0
+}
+
+// parseLiteral reads a number, date or duration literal. Dates without an
+// offset are in loc.
+func parseLiteral(text string, loc *time.Location) (Value, error) {
+	digits := strings.TrimPrefix(text, "-")
+	switch {
+	case dateLiteral.MatchString(text):
+		return parseDate(text, loc)
+	case durationLiteral.FindString(digits) == digits:
+		d, err := parseDuration(digits)
+		if err != nil || digits == text {
+			return d, err
+		}
+		return d.scaleInt(-1), nil
+	}
+	return parseNumber(text)
+}
+
+func parseDate(text string, loc *time.Location) (Value, error) {
+	if len(text) == len("2006-01-02") {
+		t, err := time.ParseInLocation("2006-01-02", text, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidDate, text)
+		}
+		return DateTime{Time: t, DateOnly: true}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, text, loc); err == nil {
+			return DateTime{Time: t}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidDate, text)
+}
+
+var durationUnits = map[string]Duration{
+	"y":   {Months: 12},
+	"mo":  {Months: 1},
+	"w":   {Days: 7},
+	"d":   {Days: 1},
+	"h":   {Time: time.Hour},
+	"min": {Time: time.Minute},
+	"m":   {Time: time.Minute},
+	"s":   {Time: time.Second},
+	"ms":  {Time: time.Millisecond},
+}
+
+func parseDuration(text string) (Duration, error) {
+	var total Duration
+	for _, part := range durationPart.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.ParseFloat(part[1], 64)
+		if err != nil {
+			return Duration{}, fmt.Errorf("%w: %s", ErrInvalidDuration, text)
+		}
+		d, err := durationUnits[part[2]].scale(n)
+		if err != nil {
+			return Duration{}, fmt.Errorf("%w: %s", ErrInvalidDuration, text)
+		}
+		total = total.add(d)
+	}
+	return total, nil
+}
+
+func (d Duration) add(o Duration) Duration {
+	return Duration{Months: d.Months + o.Months, Days: d.Days + o.Days, Time: d.Time + o.Time}
+}
+
+func (d DateTime) add(o Duration) DateTime {
+	return DateTime{
+		Time:     addMonths(d.Time, o.Months).AddDate(0, 0, o.Days).Add(o.Time),
+		DateOnly: d.DateOnly && o.Time == 0,
+	}
+}
+
+// addMonths moves t by whole months, keeping the day of the month where
+// the target month has it and taking its last day otherwise: Jan 31 plus a
+// month is Feb 28, and Feb 29 plus a year is Feb 28 of the next year.
+func addMonths(t time.Time, months int) time.Time {
+	if months == 0 {
+		return t
+	}
+	y, m, day := t.Date()
+	target := m + time.Month(months)
+	// Day 0 of the following month is the last day of the target one.
+	if last := time.Date(y, target+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		day = last
+	}
+	return time.Date(y, target, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// sub returns the calendar days and the remaining time from o to d, both
+// with the same sign, reckoned in the timezone of d.
+func (d DateTime) sub(o DateTime) Duration {
+	from := o.Time.In(d.Time.Location())
+	days := civilDays(from, d.Time)
+	rest := d.Time.Sub(from.AddDate(0, 0, days))
+	switch {
+	case days > 0 && rest < 0:
+		days--
+	case days < 0 && rest > 0:
+		days++
+	default:
+		return Duration{Days: days, Time: rest}
+	}
+	return Duration{Days: days, Time: d.Time.Sub(from.AddDate(0, 0, days))}
+}
+
+// civilDays counts the calendar days from the date of a to the date of b.
+func civilDays(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+func isTemporal(v Value) bool {
+	switch v.(type) {
+	case DateTime, Duration:
+		return true
+	}
+	return false
+}
+
+// temporalOperator applies a binary operator where at least one operand is
+// a date or a duration.
+func temporalOperator(op string, a, b Value) (Value, error) {
+	switch x := a.(type) {
+	case DateTime:
+		switch y := b.(type) {
+		case Duration:
+			switch op {
+			case "+":
+				return x.add(y), nil
+			case "-":
+				return x.add(y.scaleInt(-1)), nil
+			}
+		case DateTime:
+			if op == "-" {
+				return x.sub(y), nil
+			}
+		}
+	case Duration:
+		switch y := b.(type) {
+		case DateTime:
+			if op == "+" {
+				return y.add(x), nil
+			}
+		case Duration:
+			switch op {
+			case "+":
+				return x.add(y), nil
+			case "-":
+				return x.add(y.scaleInt(-1)), nil
+			case "/":
+				xs, okX := x.Seconds()
+				ys, okY := y.Seconds()
+				if !okX || !okY {
+					return nil, fmt.Errorf("%w: durations in months cannot be divided", ErrInvalidDuration)
+				}
+				res, err := arithmetic(op, xs, ys)
+				return Number(res), err
+			}
+		case Number, Integer:
+			switch op {
+			case "*":
+				return x.scale(toFloat(y))
+			case "/":
+				if toFloat(y) == 0 {
+					return nil, ErrDivisionByZero
+				}
+				return x.scale(1 / toFloat(y))
+			}
+		}
+	case Number, Integer:
+		if y, ok := b.(Duration); ok && op == "*" {
+			return y.scale(toFloat(x))
+		}
+	}
+	return nil, ErrTypeMismatch
+}
+
+// conversionUnits are the units a duration can be expressed in with a
+// trailing "in days".
+var conversionUnits = map[string]Duration{
+	"years":   {Months: 12},
+	"months":  {Months: 1},
+	"weeks":   {Days: 7},
+	"days":    {Days: 1},
+	"hours":   {Time: time.Hour},
+	"minutes": {Time: time.Minute},
+	"seconds": {Time: time.Second},
+}
+
+var conversionSuffix = regexp.MustCompile(`^(.*\S)\s+in\s+([a-z]+)\s*$`)
+
+// splitConversion splits "expression in days" into the expression and the
+// unit. The unit is empty when the statement has no such suffix.
+func splitConversion(stmt string) (string, string) {
+	m := conversionSuffix.FindStringSubmatch(stmt)
+	if m == nil {
+		return stmt, ""
+	}
+	if _, ok := conversionUnits[m[2]]; !ok {
+		return stmt, ""
+	}
+	return m[1], m[2]
+}
+
+// convertDuration expresses a duration as a number of units. Months and
+// years only convert into each other.
+func convertDuration(v Value, unit string) (Value, error) {
+	d, ok := v.(Duration)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	u := conversionUnits[unit]
+	if u.Months != 0 {
+		if d.Days != 0 || d.Time != 0 {
+			return nil, fmt.Errorf("%w: %s is not a whole number of months", ErrInvalidDuration, d)
+		}
+		return Number(float64(d.Months) / float64(u.Months)), nil
+	}
+	seconds, ok := d.Seconds()
+	if !ok {
+		return nil, fmt.Errorf("%w: months have no fixed length in %s", ErrInvalidDuration, unit)
+	}
+	perUnit, _ := u.Seconds()
+	return Number(seconds / perUnit), nil
+}
+
+// location returns the timezone named by an IANA name, UTC for an empty
+// one.
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTimezone, name)
+	}
+	return loc, nil
+}
+
+// clock returns the values of now and today, which are read once per
+// evaluation.
+func (env *environment) clock(name string) (Value, bool) {
+	switch name {
+	case "now":
+		return DateTime{Time: env.now}, true
+	case "today":
+		y, m, d := env.now.Date()
+		return DateTime{Time: time.Date(y, m, d, 0, 0, 0, 0, env.now.Location()), DateOnly: true}, true
+	}
+	return nil, false
+}
+
+func dateTimeFunctions() map[string]Function {
+	return map[string]Function{
+		"weekday":     {MinArgs: 1, MaxArgs: 1, Call: weekday},
+		"workdays":    {MinArgs: 2, MaxArgs: 2, Call: workdays},
+		"addworkdays": {MinArgs: 2, MaxArgs: 2, Call: addWorkdays},
+	}
+}
+
+// weekday returns the ISO day of the week, 1 for Monday to 7 for Sunday.
+func weekday(args []Value) (Value, error) {
+	d, ok := args[0].(DateTime)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	return Number(isoWeekday(d.Time)), nil
+}
+
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+func isWorkday(t time.Time) bool {
+	return isoWeekday(t) <= 5
+}
+
+// workdays counts the days from Monday to Friday from the first date up
+// to, not including, the second one. It is negative when the second date
+// comes first.
+func workdays(args []Value) (Value, error) {
+	from, okFrom := args[0].(DateTime)
+	to, okTo := args[1].(DateTime)
+	if !okFrom || !okTo {
+		return nil, ErrTypeMismatch
+	}
+	a, b := from.Time, to.Time.In(from.Time.Location())
+	sign := 1
+	if b.Before(a) {
+		a, b, sign = b, a, -1
+	}
+
+	days := civilDays(a, b)
+	count := days / 7 * 5
+	for t := a.AddDate(0, 0, days/7*7); civilDays(t, b) > 0; t = t.AddDate(0, 0, 1) {
+		if isWorkday(t) {
+			count++
+		}
+	}
+	return Number(sign * count), nil
+}
+
+// addWorkdays moves a date by n days from Monday to Friday, skipping
+// weekends, as WORKDAY in spreadsheets.
+func addWorkdays(args []Value) (Value, error) {
+	d, ok := args[0].(DateTime)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	n := toFloat(args[1])
+	if n != math.Trunc(n) {
+		return nil, ErrNotInteger
+	}
+	if math.Abs(n) > maxWorkdays {
+		return nil, ErrTooLarge
+	}
+
+	step, k := 1, int(n)
+	if k < 0 {
+		step, k = -1, -k
+	}
+	t := d.Time
+	// Every seven days hold five workdays; the rest is walked day by day.
+	if k > 0 {
+		weeks := (k - 1) / 5
+		t = t.AddDate(0, 0, 7*weeks*step)
+		k -= 5 * weeks
+	}
+	for k > 0 {
+		t = t.AddDate(0, 0, step)
+		if isWorkday(t) {
+			k--
+		}
+	}
+	return DateTime{Time: t, DateOnly: d.DateOnly}, nil
+}
+
+// maxWorkdays keeps addworkdays within the years time.Time can hold.
+const maxWorkdays = 1e6
@@ -0,0 +1,43 @@
+package calculator
+
+import "testing"
+
+func TestCalendarArithmetic(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "2026-01-31 + 1mo", want: "2026-02-28"},
+		{expr: "2028-01-31 + 1mo", want: "2028-02-29"},
+		{expr: "2028-02-29 + 1y", want: "2029-02-28"},
+		{expr: "2028-02-29 + 4y", want: "2032-02-29"},
+		{expr: "2026-03-31 - 1mo", want: "2026-02-28"},
+		{expr: "2026-01-31 + 2mo", want: "2026-03-31"},
+		{expr: "2026-01-31 + 1mo + 1mo", want: "2026-03-28"},
+		{expr: "2026-12-31 + 2mo", want: "2027-02-28"},
+		{expr: "2026-01-31T10:30:00 + 1mo", want: "2026-02-28T10:30:00Z"},
+		{expr: "2026-01-15 + 1mo 1d", want: "2026-02-16"},
+		{expr: "2026-10-19 + 3h", want: "2026-10-19T03:00:00Z"},
+	})
+}
+
+func TestDateTimes(t *testing.T) {
+	berlin := Options{Timezone: "Europe/Berlin"}
+	runEvalTests(t, []evalTest{
+		{expr: "2026-03-01 - 2026-01-01", want: "P59D"},
+		{expr: "(2026-03-01 - 2026-01-01) in days", want: "59"},
+		{expr: "90d", want: "P90D"},
+		{expr: "3h 20m", want: "PT3H20M"},
+		{expr: "1y6mo", want: "P1Y6M"},
+		{expr: "1.5h", want: "PT1H30M"},
+		{expr: "1y in months", want: "12"},
+		{expr: "weekday(2026-10-19)", want: "1"},
+		{expr: "workdays(2026-10-19, 2026-10-26)", want: "5"},
+		{expr: "addworkdays(2026-10-23, 1)", want: "2026-10-26"},
+		// A calendar day across the daylight saving change keeps the
+		// time of day, 24 hours do not.
+		{expr: "2026-03-28T12:00:00 + 1d", opts: berlin, want: "2026-03-29T12:00:00+02:00"},
+		{expr: "2026-03-28T12:00:00 + 24h", opts: berlin, want: "2026-03-29T13:00:00+02:00"},
+		{expr: "2026-02-30", err: ErrInvalidDate},
+		{expr: "1mo in days", err: ErrInvalidDuration},
+		{expr: "2026-01-01 * 2", err: ErrTypeMismatch},
+		{expr: "today", opts: Options{Timezone: "Mars/Olympus"}, err: ErrUnknownTimezone},
+	})
+}
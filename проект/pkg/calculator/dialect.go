@@ -0,0 +1,270 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownDialect = errors.New("unknown dialect")
+
+// Operator is an entry of a dialect's operator table.
+type Operator struct {
+	// Name is the operator the spelling stands for: +, -, *, /, ^ or ±.
+	Name string
+	// Precedence orders operators, higher binds tighter.
+	Precedence int
+	// RightAssociative groups a chain from the right, 2**3**2 = 2**9.
+	RightAssociative bool
+}
+
+// Dialect is a flavour of infix syntax. Dialects only change how an
+// expression is read; the values and functions are the same in all of them.
+type Dialect struct {
+	Name string
+	// Operators is the table of binary operators, keyed by how they are
+	// written.
+	Operators map[string]Operator
+	// Negation is the precedence of unary minus. A minus directly before a
+	// number is part of the number unless something binds the number
+	// tighter: in the built-in dialects a power does, so -3^2 = -9 and
+	// 2^-1 = 0.5.
+	Negation int
+	// ImplicitMultiplication reads operands written next to each other as a
+	// product: 2(3 + 4), 2pi, (a + b)(a - b). x(2) is still a call.
+	ImplicitMultiplication bool
+	// FormulaPrefix lets statements start with =, as in spreadsheets.
+	FormulaPrefix bool
+	// Percent adds a postfix %: x% is x/100, and a + b% and a - b% change a
+	// by b percent of it, so 200 + 10% = 220.
+	Percent bool
+}
+
+var standardOperators = map[string]Operator{
+	plusMinus: {Name: plusMinus, Precedence: 0},
+	"+/-":     {Name: plusMinus, Precedence: 0},
+	"+":       {Name: "+", Precedence: 1},
+	"-":       {Name: "-", Precedence: 1},
+	"*":       {Name: "*", Precedence: 2},
+	"/":       {Name: "/", Precedence: 2},
+	"^":       {Name: "^", Precedence: 4},
+}
+
+// Standard is the dialect expressions are read in unless another one is
+// asked for. Function bodies, rendering and notation conversion always
+// use it.
+var Standard = &Dialect{
+	Name:      "standard",
+	Operators: standardOperators,
+	Negation:  3,
+}
+
+var dialects = map[string]*Dialect{
+	Standard.Name: Standard,
+	"math": {
+		Name:                   "math",
+		Operators:              standardOperators,
+		Negation:               3,
+		ImplicitMultiplication: true,
+	},
+	// python writes powers as **, which binds tighter than unary minus:
+	// -2**2 = -4.
+	"python": {
+		Name: "python",
+		Operators: map[string]Operator{
+			"+":  {Name: "+", Precedence: 1},
+			"-":  {Name: "-", Precedence: 1},
+			"*":  {Name: "*", Precedence: 2},
+			"/":  {Name: "/", Precedence: 2},
+			"**": {Name: "^", Precedence: 4, RightAssociative: true},
+		},
+		Negation: 3,
+	},
+	"spreadsheet": {
+		Name:          "spreadsheet",
+		Operators:     standardOperators,
+		Negation:      3,
+		FormulaPrefix: true,
+		Percent:       true,
+	},
+}
+
+// LookupDialect returns the dialect with the given name, Standard for an
+// empty one.
+func LookupDialect(name string) (*Dialect, error) {
+	if name == "" {
+		return Standard, nil
+	}
+	d, ok := dialects[name]
+	if !ok {
+		return nil, ErrUnknownDialect
+	}
+	return d, nil
+}
+
+// Standardize rewrites a script written in the named dialect into the
+// standard one, spelling out implicit products and percentages.
+func Standardize(expr, dialect string) (string, error) {
+	d, err := LookupDialect(dialect)
+	if err != nil {
+		return "", err
+	}
+	if err := d.validate(expr); err != nil {
+		return "", err
+	}
+	return NewEvaluator().rewrite(expr, NotationInfix, d, NotationInfix)
+}
+
+// operatorAt returns the longest operator spelling s starts with.
+func (d *Dialect) operatorAt(s []rune) (string, Operator, bool) {
+	var spelling string
+	for text := range d.Operators {
+		if len(text) > len(spelling) && strings.HasPrefix(string(s), text) {
+			spelling = text
+		}
+	}
+	op, ok := d.Operators[spelling]
+	return spelling, op, ok
+}
+
+// precedence returns the precedence of an operator by its name, or of
+// unary minus for negation.
+func (d *Dialect) precedence(name string) (int, bool) {
+	if name == negation {
+		return d.Negation, true
+	}
+	for _, op := range d.Operators {
+		if op.Name == name {
+			return op.Precedence, true
+		}
+	}
+	return 0, false
+}
+
+func (d *Dialect) rightAssociative(name string) bool {
+	for _, op := range d.Operators {
+		if op.Name == name {
+			return op.RightAssociative
+		}
+	}
+	return false
+}
+
+// bindsTighter reports whether the token after a number takes it as an
+// operand before a unary minus in front of the number would.
+func (d *Dialect) bindsTighter(next token) bool {
+	switch next.kind {
+	case tokenPostfix:
+		return true
+	case tokenOperator:
+		prec, _ := d.precedence(next.text)
+		return prec > d.Negation
+	}
+	return false
+}
+
+// statement strips the formula prefix off a statement.
+func (d *Dialect) statement(stmt string) string {
+	if d.FormulaPrefix {
+		if trimmed := strings.TrimSpace(stmt); strings.HasPrefix(trimmed, "=") {
+			return trimmed[1:]
+		}
+	}
+	return stmt
+}
+
+// validate checks that expr only holds characters the dialect uses.
+func (d *Dialect) validate(expr string) error {
+	for _, c := range expr {
+		if c == '%' && d.Percent {
+			continue
+		}
+		if !strings.ContainsRune(allowedCharacters, c) && !isLetter(c) {
+			return fmt.Errorf("%w: '%c'", ErrInvalidCharacter, c)
+		}
+	}
+	return nil
+}
+
+// implicitProducts puts a multiplication between operands written next to
+// each other.
+func implicitProducts(tokens []token) []token {
+	var out []token
+	for i, t := range tokens {
+		if i > 0 && endsOperand(tokens[i-1], t) && startsOperand(t) {
+			out = append(out, token{kind: tokenOperator, text: "*"})
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func endsOperand(t, next token) bool {
+	switch t.kind {
+	case tokenNumber, tokenRightParen, tokenRightBracket, tokenPostfix:
+		return true
+	case tokenIdent:
+		return next.kind != tokenLeftParen
+	}
+	return false
+}
+
+func startsOperand(t token) bool {
+	switch t.kind {
+	case tokenNumber, tokenIdent, tokenLeftParen, tokenLeftBracket:
+		return true
+	}
+	return false
+}
+
+const percent = "%"
+
+// expandPercents rewrites percentages into plain arithmetic: a + b% into
+// a * (100 + b) / 100, a - b% likewise and any other b% into b / 100.
+// Dividing last keeps 200 + 10% exactly 220.
+func expandPercents(n *node) *node {
+	fraction := func(n *node) *node {
+		return binaryNode("/", n, numberNode("100"))
+	}
+
+	if n.t.kind == tokenOperator && (n.t.text == "+" || n.t.text == "-") && isPercent(n.args[1]) {
+		a := expandPercents(n.args[0])
+		b := expandPercents(n.args[1].args[0])
+		return fraction(binaryNode("*", a, binaryNode(n.t.text, numberNode("100"), b)))
+	}
+
+	for i, arg := range n.args {
+		n.args[i] = expandPercents(arg)
+	}
+	if n.t.kind == tokenClosure {
+		n.t.body = postfixOf(n.args[0])
+	}
+	if isPercent(n) {
+		return fraction(n.args[0])
+	}
+	return n
+}
+
+func isPercent(n *node) bool {
+	return n.t.kind == tokenPostfix && n.t.text == percent
+}
+
+func binaryNode(op string, a, b *node) *node {
+	return &node{t: token{kind: tokenOperator, text: op}, args: []*node{a, b}}
+}
+
+func numberNode(text string) *node {
+	return &node{t: token{kind: tokenNumber, text: text}}
+}
+
+// postfixOf turns an expression tree back into postfix form.
+func postfixOf(n *node) []token {
+	if n.t.kind == tokenClosure {
+		return []token{n.t}
+	}
+	var postfix []token
+	for _, arg := range n.args {
+		postfix = append(postfix, postfixOf(arg)...)
+	}
+	return append(postfix, n.t)
+}
@@ -0,0 +1,68 @@
+package calculator
+
+import (
+	"math"
+	"testing"
+)
+
+// TestDialects checks the examples each dialect documents.
+func TestDialects(t *testing.T) {
+	standard := Options{Dialect: "standard"}
+	mathDialect := Options{Dialect: "math"}
+	python := Options{Dialect: "python"}
+	spreadsheet := Options{Dialect: "spreadsheet"}
+	runEvalTests(t, []evalTest{
+		{expr: "-3^2", opts: standard, want: "-9"},
+		{expr: "2^-1", opts: standard, want: "0.5"},
+		{expr: "2(3 + 4)", opts: standard, err: ErrInvalidExpression},
+		{expr: "2**2", opts: standard, err: ErrInvalidExpression},
+
+		{expr: "2(3 + 4)", opts: mathDialect, want: "14"},
+		{expr: "2pi", opts: mathDialect, want: "6.283185307179586"},
+		{expr: "(1 + 2)(3 + 4)", opts: mathDialect, want: "21"},
+		{expr: "x = 3; 2x^2", opts: mathDialect, want: "18"},
+		{expr: "-3^2", opts: mathDialect, want: "-9"},
+
+		{expr: "-2**2", opts: python, want: "-4"},
+		{expr: "2**3**2", opts: python, want: "512"},
+		{expr: "2^2", opts: python, err: ErrInvalidCharacter},
+
+		{expr: "=200 + 10%", opts: spreadsheet, want: "220"},
+		{expr: "200 - 10%", opts: spreadsheet, want: "180"},
+		{expr: "50%", opts: spreadsheet, want: "0.5"},
+		{expr: "-3^2", opts: spreadsheet, want: "-9"},
+
+		{expr: "1 + 1", opts: Options{Dialect: "fortran"}, err: ErrUnknownDialect},
+	})
+}
+
+func TestStandardize(t *testing.T) {
+	tests := []struct {
+		expr, dialect string
+		want          string
+	}{
+		{"200 + 10%", "spreadsheet", "200 * (100 + 10) / 100"},
+		{"2**3**2", "python", "2 ^ (3 ^ 2)"},
+		{"2(3 + 4)", "math", "2 * (3 + 4)"},
+	}
+	for _, tt := range tests {
+		if got, err := Standardize(tt.expr, tt.dialect); err != nil || got != tt.want {
+			t.Errorf("Standardize(%s, %s) = %q, %v; want %q", tt.expr, tt.dialect, got, err, tt.want)
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "sin(pi/2)", want: "1"},
+		{expr: "ln(e)", want: "1"},
+		{expr: "e = 5; e", want: "5"},
+		{expr: "pi = 3; 2 * pi", want: "6"},
+	})
+	if got := evalNumber(t, "pi"); got != math.Pi {
+		t.Errorf("pi = %g", got)
+	}
+	if got := evalNumber(t, "e"); got != math.E {
+		t.Errorf("e = %g", got)
+	}
+}
@@ -0,0 +1,20 @@
+// Package calculator evaluates arithmetic expressions and small scripts:
+// numbers, exact big integers, complex numbers, intervals, measurements
+// with uncertainty, lists, matrices, dates and durations, with user and
+// WebAssembly functions.
+//
+//	e := calculator.NewEvaluator(
+//		calculator.WithDialect("math"),
+//		calculator.WithLimits(calculator.Limits{Timeout: time.Second}),
+//	)
+//	res, err := e.Evaluate(ctx, "2(3 + 4)")
+//
+// # Compatibility
+//
+// This package is the supported way to embed the calculator and follows
+// semantic versioning: within a major version exported identifiers are not
+// removed or changed incompatibly, new fields, options, functions and
+// values may be added, and error values keep matching with errors.Is. The
+// text of error messages and the digits of inexact results are not part of
+// the contract. Packages under internal/ carry no such promise.
+package calculator
@@ -0,0 +1,169 @@
+package calculator
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// elementary is a real function of one variable that also accepts complex
+// numbers, intervals and uncertain values.
+type elementary struct {
+	eval func(float64) (float64, error)
+	// deriv is the derivative used to propagate uncertainties.
+	deriv func(float64) float64
+	// bounds returns the range of the function over an interval.
+	bounds func(Interval) (Value, error)
+	// complex is the complex version of the function, if there is one.
+	complex func(complex128) complex128
+}
+
+func (f elementary) function() Function {
+	return Function{MinArgs: 1, MaxArgs: 1, Call: f.call}
+}
+
+func (f elementary) call(args []Value) (Value, error) {
+	switch x := args[0].(type) {
+	case Number:
+		res, err := f.eval(float64(x))
+		return Number(res), err
+	case Integer:
+		res, err := f.eval(x.Float())
+		return Number(res), err
+	case Uncertain:
+		res, err := f.eval(x.Value)
+		if err != nil {
+			return nil, err
+		}
+		return propagate(res, x, f.deriv(x.Value), Uncertain{}, 0), nil
+	case Interval:
+		return f.bounds(x)
+	case Complex:
+		if f.complex != nil {
+			return normalizeComplex(f.complex(complex128(x))), nil
+		}
+	}
+	return nil, ErrTypeMismatch
+}
+
+func elementaryFunctions() map[string]Function {
+	return map[string]Function{
+		"exp": exponent.function(),
+		"ln":  logarithm.function(),
+		"sin": sine.function(),
+		"cos": cosine.function(),
+		"tan": tangent.function(),
+	}
+}
+
+var (
+	exponent = elementary{
+		eval:    func(x float64) (float64, error) { return math.Exp(x), nil },
+		deriv:   math.Exp,
+		bounds:  increasing(math.Exp, nil),
+		complex: cmplx.Exp,
+	}
+	logarithm = elementary{
+		eval: func(x float64) (float64, error) {
+			if x <= 0 {
+				return 0, ErrDomain
+			}
+			return math.Log(x), nil
+		},
+		deriv:   func(x float64) float64 { return 1 / x },
+		bounds:  increasing(math.Log, func(lo float64) bool { return lo > 0 }),
+		complex: cmplx.Log,
+	}
+	squareRoot = elementary{
+		eval: func(x float64) (float64, error) {
+			if x < 0 {
+				return 0, ErrDomain
+			}
+			return math.Sqrt(x), nil
+		},
+		deriv:   func(x float64) float64 { return 1 / (2 * math.Sqrt(x)) },
+		bounds:  increasing(math.Sqrt, func(lo float64) bool { return lo >= 0 }),
+		complex: cmplx.Sqrt,
+	}
+	absolute = elementary{
+		eval:  func(x float64) (float64, error) { return math.Abs(x), nil },
+		deriv: func(x float64) float64 { return math.Copysign(1, x) },
+		bounds: func(x Interval) (Value, error) {
+			lo, hi := math.Abs(x.Lo), math.Abs(x.Hi)
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if x.contains(0) {
+				lo = 0
+			}
+			return Interval{Lo: lo, Hi: hi}, nil
+		},
+	}
+	sine = elementary{
+		eval:    func(x float64) (float64, error) { return math.Sin(x), nil },
+		deriv:   math.Cos,
+		bounds:  periodic(math.Sin, math.Pi/2, -math.Pi/2),
+		complex: cmplx.Sin,
+	}
+	cosine = elementary{
+		eval:    func(x float64) (float64, error) { return math.Cos(x), nil },
+		deriv:   func(x float64) float64 { return -math.Sin(x) },
+		bounds:  periodic(math.Cos, 0, math.Pi),
+		complex: cmplx.Cos,
+	}
+	tangent = elementary{
+		eval:  func(x float64) (float64, error) { return math.Tan(x), nil },
+		deriv: func(x float64) float64 { return 1 / (math.Cos(x) * math.Cos(x)) },
+		bounds: func(x Interval) (Value, error) {
+			if reaches(x, math.Pi/2, math.Pi) {
+				return nil, ErrDomain
+			}
+			return widen(math.Tan(x.Lo), math.Tan(x.Hi)), nil
+		},
+		complex: cmplx.Tan,
+	}
+)
+
+// increasing returns the bounds of a monotonically increasing function.
+// The domain check, if any, is given the lower bound of the interval.
+func increasing(fn func(float64) float64, domain func(lo float64) bool) func(Interval) (Value, error) {
+	return func(x Interval) (Value, error) {
+		if domain != nil && !domain(x.Lo) {
+			return nil, ErrDomain
+		}
+		return widen(fn(x.Lo), fn(x.Hi)), nil
+	}
+}
+
+// periodic returns the bounds of sin or cos, which reach 1 at peak and -1
+// at trough once every 2π.
+func periodic(fn func(float64) float64, peak, trough float64) func(Interval) (Value, error) {
+	return func(x Interval) (Value, error) {
+		lo, hi := fn(x.Lo), fn(x.Hi)
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		res := widen(lo, hi)
+		if reaches(x, peak, 2*math.Pi) {
+			res.Hi = 1
+		}
+		if reaches(x, trough, 2*math.Pi) {
+			res.Lo = -1
+		}
+		res.Lo, res.Hi = math.Max(res.Lo, -1), math.Min(res.Hi, 1)
+		return res, nil
+	}
+}
+
+// reaches reports whether the interval contains point + k*period for some
+// integer k. Points within rounding distance of a bound count as reached,
+// which keeps the resulting bounds rigorous.
+func reaches(x Interval, point, period float64) bool {
+	if x.Hi-x.Lo >= period {
+		return true
+	}
+	k := math.Ceil((x.Lo - point) / period)
+	next := point + k*period
+	const slack = 1e-9
+	return next <= x.Hi+slack*math.Max(1, math.Abs(x.Hi)) ||
+		next-period >= x.Lo-slack*math.Max(1, math.Abs(x.Lo))
+}
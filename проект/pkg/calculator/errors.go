@@ -0,0 +1,141 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+)
+
+// Code is a stable, machine-readable class of error. Clients may switch on
+// codes; their set may grow, so unknown codes should be treated like
+// CodeInternal.
+type Code string
+
+const (
+	// CodeSyntax is an expression that cannot be read: a stray character,
+	// unbalanced brackets, a malformed date.
+	CodeSyntax Code = "SYNTAX"
+	// CodeUnknownIdentifier is a name that is neither a constant nor bound
+	// by the script.
+	CodeUnknownIdentifier Code = "UNKNOWN_IDENTIFIER"
+	// CodeUnknownFunction is a call of a function that is not defined.
+	CodeUnknownFunction Code = "UNKNOWN_FUNCTION"
+	// CodeInvalidArgument is a function or operator applied to operands it
+	// does not take: the wrong count, type, shape or range.
+	CodeInvalidArgument Code = "INVALID_ARGUMENT"
+	// CodeDivisionByZero is a division by zero.
+	CodeDivisionByZero Code = "DIVISION_BY_ZERO"
+	// CodeDomain is an operation without a real result, such as sqrt(-1)
+	// outside complex mode, or a limit that does not exist.
+	CodeDomain Code = "DOMAIN"
+	// CodeOverflow is a result or operand too large to compute.
+	CodeOverflow Code = "OVERFLOW"
+	// CodeTimeout is an evaluation that ran out of time.
+	CodeTimeout Code = "TIMEOUT"
+	// CodeLimitExceeded is an expression or module over a configured limit.
+	CodeLimitExceeded Code = "LIMIT_EXCEEDED"
+	// CodeUnsupported is an unknown dialect, notation, timezone, language
+	// or format.
+	CodeUnsupported Code = "UNSUPPORTED"
+	// CodeInvalidDefinition is a user function or module that cannot be
+	// defined.
+	CodeInvalidDefinition Code = "INVALID_DEFINITION"
+	// CodeInternal is everything else.
+	CodeInternal Code = "INTERNAL"
+)
+
+// Error is an error with its code. Message is meant for people; Code is
+// what programs should look at.
+type Error struct {
+	Code    Code
+	Message string
+	// err is the error classified, nil when the error came over the wire.
+	err error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.err }
+
+// errorCodes classifies the errors of this package.
+var errorCodes = []struct {
+	err  error
+	code Code
+}{
+	{ErrInvalidCharacter, CodeSyntax},
+	{ErrInvalidCharacters, CodeSyntax},
+	{ErrInvalidExpression, CodeSyntax},
+	{ErrUnbalancedBrackets, CodeSyntax},
+	{ErrInvalidOperatorUse, CodeSyntax},
+	{ErrEmptyExpression, CodeSyntax},
+	{ErrInvalidAssignment, CodeSyntax},
+	{ErrInvalidDate, CodeSyntax},
+	{ErrInvalidDuration, CodeSyntax},
+	{ErrUnknownWords, CodeSyntax},
+
+	{ErrUnknownIdentifier, CodeUnknownIdentifier},
+	{ErrUnknownFunction, CodeUnknownFunction},
+
+	{ErrArgumentCount, CodeInvalidArgument},
+	{ErrTypeMismatch, CodeInvalidArgument},
+	{ErrShapeMismatch, CodeInvalidArgument},
+	{ErrEmptyList, CodeInvalidArgument},
+	{ErrNotInteger, CodeInvalidArgument},
+	{ErrNegative, CodeInvalidArgument},
+	{ErrInvalidPercentile, CodeInvalidArgument},
+	{ErrNotEnoughValues, CodeInvalidArgument},
+	{ErrInvalidInterval, CodeInvalidArgument},
+	{ErrNegativeUncertainty, CodeInvalidArgument},
+	{ErrNotSquare, CodeInvalidArgument},
+	{ErrInvalidRange, CodeInvalidArgument},
+	{ErrLimitDirection, CodeInvalidArgument},
+
+	{ErrDivisionByZero, CodeDivisionByZero},
+	{ErrDomain, CodeDomain},
+	{ErrSingularMatrix, CodeDomain},
+	{ErrNoLimit, CodeDomain},
+	{ErrDivergent, CodeDomain},
+	{ErrTooLarge, CodeOverflow},
+
+	{ErrTimeout, CodeTimeout},
+	{context.DeadlineExceeded, CodeTimeout},
+	{context.Canceled, CodeTimeout},
+	{ErrTooLong, CodeLimitExceeded},
+	{ErrTooManyStatements, CodeLimitExceeded},
+	{ErrModuleLimit, CodeLimitExceeded},
+
+	{ErrUnknownDialect, CodeUnsupported},
+	{ErrUnknownNotation, CodeUnsupported},
+	{ErrUnknownTimezone, CodeUnsupported},
+	{ErrUnknownLanguage, CodeUnsupported},
+	{ErrUnknownFormat, CodeUnsupported},
+
+	{ErrInvalidFunction, CodeInvalidDefinition},
+	{ErrReservedName, CodeInvalidDefinition},
+	{ErrRecursiveFunction, CodeInvalidDefinition},
+	{ErrInvalidModule, CodeInvalidDefinition},
+	{ErrFunctionExists, CodeInvalidDefinition},
+}
+
+// Classify returns err with its code, nil for nil. Errors this package
+// does not know are CodeInternal.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return &Error{Code: c.code, Message: err.Error(), err: err}
+		}
+	}
+	return &Error{Code: CodeInternal, Message: err.Error(), err: err}
+}
+
+// NewError returns an error with the given code, for errors received from
+// another process.
+func NewError(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
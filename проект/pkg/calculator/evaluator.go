@@ -0,0 +1,605 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidCharacter  = errors.New("invalid character in expression")
+	ErrDivisionByZero    = errors.New("division by zero")
+	ErrTimeout           = errors.New("calculation timeout")
+	ErrInvalidExpression = errors.New("invalid expression structure")
+	ErrUnknownIdentifier = errors.New("unknown identifier")
+)
+
+// negation is the internal name of the unary minus operator. Its
+// precedence comes from the dialect.
+const negation = "neg"
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenIdent
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+	tokenCall
+	tokenList
+	tokenPostfix
+	tokenRange
+	tokenInterval
+	tokenClosure
+)
+
+// token is a lexical unit of an expression. In postfix form calls and list
+// literals carry the number of values they consume from the stack in argc,
+// closures their variable in text and their compiled expression in body.
+type token struct {
+	kind tokenKind
+	text string
+	argc int
+	body []token
+}
+
+type Evaluator struct {
+	// mu guards functions and modules, which change when wasm modules
+	// are loaded while expressions are being evaluated.
+	mu        sync.RWMutex
+	functions map[string]Function
+	modules   map[string]*wasmModule
+
+	dialect     string
+	definitions []FunctionDef
+	precision   Precision
+	limits      Limits
+}
+
+// NewEvaluator returns an evaluator with the built-in functions, configured
+// by opts. Without options it reads the standard dialect, keeps large
+// integers exact and sets no limits.
+func NewEvaluator(opts ...Option) *Evaluator {
+	e := &Evaluator{
+		functions: builtinFunctions(),
+		modules:   make(map[string]*wasmModule),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+const allowedCharacters = "0123456789+-*/^!() .[],_;=±:"
+
+// Validate checks that expr only holds characters of the standard dialect.
+func (e *Evaluator) Validate(expr string) error {
+	return Standard.validate(expr)
+}
+
+// Evaluate runs a script of one or more statements, see Result.
+func (e *Evaluator) Evaluate(ctx context.Context, expr string) (*Result, error) {
+	return e.EvaluateWithOptions(ctx, expr, Options{})
+}
+
+// EvaluateWithOptions is Evaluate with options for this evaluation only.
+// Options left empty fall back to the ones the evaluator was created with.
+func (e *Evaluator) EvaluateWithOptions(ctx context.Context, expr string, opts Options) (*Result, error) {
+	opts = e.withDefaults(opts)
+	dialect, err := LookupDialect(opts.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Notation {
+	case "", NotationInfix:
+		if err := dialect.validate(expr); err != nil {
+			return nil, err
+		}
+	case NotationRPN, NotationPrefix:
+	default:
+		return nil, ErrUnknownNotation
+	}
+
+	statements := splitStatements(expr)
+	if len(statements) == 0 {
+		return nil, ErrEmptyExpression
+	}
+	ctx, cancel, err := e.limits.bound(ctx, expr, len(statements))
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	functions, err := e.compileFunctions(opts.Functions)
+	if err != nil {
+		return nil, err
+	}
+	env, err := newEnvironment(ctx, functions, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, stmt := range statements {
+		select {
+		case <-ctx.Done():
+			return nil, ErrTimeout
+		default:
+		}
+
+		name, body, err := parseAssignment(dialect.statement(stmt))
+		if err != nil {
+			return nil, err
+		}
+		body, unit := splitConversion(body)
+
+		postfix, err := e.parse(body, opts.Notation, dialect)
+		if err != nil {
+			return nil, err
+		}
+		value, err := e.evaluatePostfix(postfix, env)
+		if err != nil {
+			return nil, err
+		}
+		if unit != "" {
+			if value, err = convertDuration(value, unit); err != nil {
+				return nil, err
+			}
+		}
+
+		if name != "" {
+			env.bindings[name] = value
+			res.Assignments = append(res.Assignments, Assignment{Name: name, Value: e.precision.round(value)})
+		}
+		res.Value = e.precision.round(value)
+	}
+
+	return res, nil
+}
+
+// compile turns a single expression written in dialect d into postfix
+// form.
+func (e *Evaluator) compile(expr string, d *Dialect) ([]token, error) {
+	tokens, err := e.tokenize(expr, d)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err = e.foldSeriesForms(tokens, d)
+	if err != nil {
+		return nil, err
+	}
+
+	postfix, err := e.infixToPostfix(tokens, d)
+	if err != nil || !d.Percent {
+		return postfix, err
+	}
+	root, err := parseTree(postfix)
+	if err != nil {
+		return nil, err
+	}
+	return postfixOf(expandPercents(root)), nil
+}
+
+func (e *Evaluator) tokenize(expr string, d *Dialect) ([]token, error) {
+	var tokens []token
+	var numberBuffer strings.Builder
+	var identBuffer strings.Builder
+	expr = strings.ReplaceAll(expr, " ", "")
+
+	flush := func() {
+		if numberBuffer.Len() > 0 {
+			tokens = append(tokens, token{kind: tokenNumber, text: numberBuffer.String()})
+			numberBuffer.Reset()
+		}
+		if identBuffer.Len() > 0 {
+			tokens = append(tokens, token{kind: tokenIdent, text: identBuffer.String()})
+			identBuffer.Reset()
+		}
+	}
+
+	runes := []rune(expr)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case identBuffer.Len() > 0 && (isLetter(char) || isDigit(char)):
+			identBuffer.WriteRune(char)
+		case char == '.' && i+1 < len(runes) && runes[i+1] == '.':
+			flush()
+			tokens = append(tokens, token{kind: tokenRange, text: ".."})
+			i++
+		case numberBuffer.Len() == 0 && isDigit(char) && isDice(runes[i:]):
+			dice, n := diceTokens(runes[i:])
+			tokens = append(tokens, dice...)
+			i += n - 1
+		case numberBuffer.Len() == 0 && isDigit(char) && temporalLength(runes[i:]) > 0:
+			n := temporalLength(runes[i:])
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i : i+n])})
+			i += n - 1
+		case isDigit(char) || char == '.':
+			numberBuffer.WriteRune(char)
+		case char == imaginaryUnit && numberBuffer.Len() > 0 &&
+			(i+1 == len(runes) || !isLetter(runes[i+1]) && !isDigit(runes[i+1])):
+			numberBuffer.WriteRune(char)
+			flush()
+		case isLetter(char):
+			flush()
+			identBuffer.WriteRune(char)
+		case char == '-' && numberBuffer.Len() == 0 && identBuffer.Len() == 0 && expectsOperand(tokens):
+			tokens = append(tokens, token{kind: tokenOperator, text: negation})
+		case char == '%' && d.Percent:
+			flush()
+			tokens = append(tokens, token{kind: tokenPostfix, text: percent})
+		default:
+			flush()
+			if spelling, op, ok := d.operatorAt(runes[i:]); ok {
+				tokens = append(tokens, token{kind: tokenOperator, text: op.Name})
+				i += len([]rune(spelling)) - 1
+				continue
+			}
+			kind, ok := punctuation[char]
+			if !ok {
+				return nil, fmt.Errorf("%w: '%c'", ErrInvalidCharacter, char)
+			}
+			tokens = append(tokens, token{kind: kind, text: string(char)})
+		}
+	}
+	flush()
+
+	if d.ImplicitMultiplication {
+		tokens = implicitProducts(tokens)
+	}
+	tokens = d.foldNegativeLiterals(tokens)
+	return tokens, nil
+}
+
+// punctuation holds the characters that are not operators. Operators come
+// from the dialect's table.
+var punctuation = map[rune]tokenKind{
+	'!': tokenPostfix,
+	'(': tokenLeftParen,
+	')': tokenRightParen,
+	'[': tokenLeftBracket,
+	']': tokenRightBracket,
+	',': tokenComma,
+}
+
+// expectsOperand reports whether the next token has to start an operand,
+// which is where a minus sign is unary rather than a subtraction.
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokenOperator, tokenLeftParen, tokenLeftBracket, tokenComma, tokenRange:
+		return true
+	}
+	return false
+}
+
+// foldNegativeLiterals turns a unary minus directly followed by a number
+// into a negative literal, which keeps large integers exact. A number
+// bound tighter than the minus is not folded: -3! is -(3!).
+func (d *Dialect) foldNegativeLiterals(tokens []token) []token {
+	folded := tokens[:0]
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == tokenOperator && t.text == negation &&
+			i+1 < len(tokens) && tokens[i+1].kind == tokenNumber &&
+			(i+2 == len(tokens) || !d.bindsTighter(tokens[i+2])) {
+			i++
+			t = token{kind: tokenNumber, text: "-" + tokens[i].text}
+		}
+		folded = append(folded, t)
+	}
+	return folded
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// argFrame counts the values inside a call or list literal being parsed.
+// A list literal with a range instead of commas is an interval.
+type argFrame struct {
+	commas int
+	empty  bool
+	ranged bool
+}
+
+func (f argFrame) argc() int {
+	if f.empty {
+		return 0
+	}
+	return f.commas + 1
+}
+
+func (e *Evaluator) infixToPostfix(tokens []token, d *Dialect) ([]token, error) {
+	var output []token
+	var stack []token
+	var frames []argFrame
+
+	top := func() *token {
+		if len(stack) == 0 {
+			return nil
+		}
+		return &stack[len(stack)-1]
+	}
+	pop := func() token {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return t
+	}
+	popUntil := func(kind tokenKind) error {
+		for len(stack) > 0 && top().kind != kind {
+			if top().kind == tokenLeftParen || top().kind == tokenLeftBracket {
+				return ErrInvalidExpression
+			}
+			output = append(output, pop())
+		}
+		if len(stack) == 0 {
+			return ErrInvalidExpression
+		}
+		return nil
+	}
+
+	for i, t := range tokens {
+		if len(frames) > 0 && t.kind != tokenRightParen && t.kind != tokenRightBracket {
+			frames[len(frames)-1].empty = false
+		}
+
+		switch t.kind {
+		case tokenNumber, tokenPostfix, tokenClosure:
+			output = append(output, t)
+		case tokenIdent:
+			if i+1 < len(tokens) && tokens[i+1].kind == tokenLeftParen {
+				stack = append(stack, token{kind: tokenCall, text: t.text})
+			} else {
+				output = append(output, t)
+			}
+		case tokenLeftParen:
+			if top() != nil && top().kind == tokenCall {
+				frames = append(frames, argFrame{empty: true})
+			}
+			stack = append(stack, t)
+		case tokenLeftBracket:
+			frames = append(frames, argFrame{empty: true})
+			stack = append(stack, t)
+		case tokenComma:
+			for len(stack) > 0 && top().kind == tokenOperator {
+				output = append(output, pop())
+			}
+			if len(frames) == 0 || len(stack) == 0 {
+				return nil, ErrInvalidExpression
+			}
+			if top().kind == tokenLeftParen && (len(stack) < 2 || stack[len(stack)-2].kind != tokenCall) {
+				return nil, ErrInvalidExpression
+			}
+			frames[len(frames)-1].commas++
+		case tokenRange:
+			for len(stack) > 0 && top().kind == tokenOperator {
+				output = append(output, pop())
+			}
+			if len(frames) == 0 || len(stack) == 0 || top().kind != tokenLeftBracket {
+				return nil, ErrInvalidExpression
+			}
+			frame := &frames[len(frames)-1]
+			if frame.commas > 0 || frame.ranged || i == 0 || tokens[i-1].kind == tokenLeftBracket {
+				return nil, ErrInvalidExpression
+			}
+			frame.ranged = true
+		case tokenRightParen:
+			if err := popUntil(tokenLeftParen); err != nil {
+				return nil, err
+			}
+			pop()
+			if top() != nil && top().kind == tokenCall {
+				call := pop()
+				call.argc = frames[len(frames)-1].argc()
+				frames = frames[:len(frames)-1]
+				output = append(output, call)
+			}
+		case tokenRightBracket:
+			if err := popUntil(tokenLeftBracket); err != nil {
+				return nil, err
+			}
+			pop()
+			frame := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+			if frame.ranged {
+				if frame.commas > 0 || tokens[i-1].kind == tokenRange {
+					return nil, ErrInvalidExpression
+				}
+				output = append(output, token{kind: tokenInterval, argc: 2})
+				continue
+			}
+			output = append(output, token{kind: tokenList, argc: frame.argc()})
+		case tokenOperator:
+			prec, _ := d.precedence(t.text)
+			if t.text != negation {
+				for len(stack) > 0 && top().kind == tokenOperator {
+					above, _ := d.precedence(top().text)
+					if above < prec || above == prec && d.rightAssociative(t.text) {
+						break
+					}
+					output = append(output, pop())
+				}
+			}
+			stack = append(stack, t)
+		}
+	}
+
+	for len(stack) > 0 {
+		if top().kind != tokenOperator {
+			return nil, ErrInvalidExpression
+		}
+		output = append(output, pop())
+	}
+
+	return output, nil
+}
+
+func (e *Evaluator) evaluatePostfix(postfix []token, env *environment) (Value, error) {
+	stack := []Value{}
+
+	popArgs := func(n int) ([]Value, error) {
+		if len(stack) < n {
+			return nil, ErrInvalidExpression
+		}
+		args := append([]Value(nil), stack[len(stack)-n:]...)
+		stack = stack[:len(stack)-n]
+		return args, nil
+	}
+
+	for _, t := range postfix {
+		switch t.kind {
+		case tokenNumber:
+			num, err := parseLiteral(t.text, env.now.Location())
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, num)
+		case tokenClosure:
+			stack = append(stack, e.newClosure(t, env))
+		case tokenIdent:
+			value, ok := env.bindings[t.text]
+			if !ok {
+				value, ok = env.clock(t.text)
+			}
+			if !ok {
+				value, ok = constants[t.text]
+			}
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownIdentifier, t.text)
+			}
+			stack = append(stack, value)
+		case tokenList:
+			args, err := popArgs(t.argc)
+			if err != nil {
+				return nil, err
+			}
+			list, err := newList(args)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, list)
+		case tokenInterval:
+			args, err := popArgs(t.argc)
+			if err != nil {
+				return nil, err
+			}
+			interval, err := newInterval(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, interval)
+		case tokenCall:
+			args, err := popArgs(t.argc)
+			if err != nil {
+				return nil, err
+			}
+			res, err := e.call(t.text, args, env)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, res)
+		case tokenPostfix:
+			args, err := popArgs(1)
+			if err != nil {
+				return nil, err
+			}
+			res, err := factorial(env.ctx, args[0])
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, res)
+		case tokenOperator:
+			if t.text == negation {
+				args, err := popArgs(1)
+				if err != nil {
+					return nil, err
+				}
+				res, err := negate(args[0])
+				if err != nil {
+					return nil, err
+				}
+				stack = append(stack, res)
+				continue
+			}
+
+			args, err := popArgs(2)
+			if err != nil {
+				return nil, err
+			}
+			res, err := applyOperator(t.text, args[0], args[1])
+			if errors.Is(err, ErrDomain) && env.complex {
+				res, err = complexOperator(t.text, args[0], args[1])
+			}
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, res)
+		default:
+			return nil, ErrInvalidExpression
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, ErrInvalidExpression
+	}
+
+	return stack[0], nil
+}
+
+// call invokes a series form, a built-in or, failing that, a user-defined
+// function. User
+// functions only see their own parameters, never the caller's bindings.
+func (e *Evaluator) call(name string, args []Value, env *environment) (Value, error) {
+	if form, ok := seriesForms[name]; ok && len(args) > 0 {
+		if c, ok := args[len(args)-1].(*closure); ok {
+			return form.call(args[:len(args)-1], c)
+		}
+	}
+
+	fn, ok := env.local[name]
+	if !ok {
+		e.mu.RLock()
+		fn, ok = e.functions[name]
+		e.mu.RUnlock()
+	}
+	if ok {
+		if err := fn.checkArity(name, len(args)); err != nil {
+			return nil, err
+		}
+		res, err := fn.Call(args)
+		if fallback, ok := complexFallbacks[name]; ok && errors.Is(err, ErrDomain) && env.complex {
+			return fallback(args)
+		}
+		return res, err
+	}
+
+	user, ok := env.functions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFunction, name)
+	}
+	if len(args) != len(user.def.Params) {
+		return nil, fmt.Errorf("%w: %s got %d", ErrArgumentCount, name, len(args))
+	}
+
+	local := *env
+	local.bindings = make(scope, len(args))
+	for i, param := range user.def.Params {
+		local.bindings[param] = args[i]
+	}
+	return e.evaluatePostfix(user.body, &local)
+}
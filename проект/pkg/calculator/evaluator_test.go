@@ -0,0 +1,132 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// evalTest is a case of the table-driven evaluator tests: an expression,
+// the options it is evaluated with and either the printed result or the
+// error it fails with.
+type evalTest struct {
+	expr string
+	opts Options
+	want string
+	err  error
+}
+
+func runEvalTests(t *testing.T, tests []evalTest) {
+	t.Helper()
+	e := NewEvaluator()
+	for _, tt := range tests {
+		res, err := e.EvaluateWithOptions(context.Background(), tt.expr, tt.opts)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: error = %v, want %v", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := res.Value.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// evalNumber evaluates an expression with a real result.
+func evalNumber(t *testing.T, expr string) float64 {
+	t.Helper()
+	res, err := NewEvaluator().Evaluate(context.Background(), expr)
+	if err != nil {
+		t.Fatalf("%s: %v", expr, err)
+	}
+	switch v := res.Value.(type) {
+	case Number:
+		return float64(v)
+	case Integer:
+		return v.Float()
+	}
+	t.Fatalf("%s = %v, want a number", expr, res.Value)
+	return 0
+}
+
+func closeTo(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(1, math.Abs(b))
+}
+
+func TestArithmetic(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "2 + 3 * 4", want: "14"},
+		{expr: "(2 + 3) * 4", want: "20"},
+		{expr: "7 / 2", want: "3.5"},
+		{expr: "2^10", want: "1024"},
+		{expr: "10 - 4 - 3", want: "3"},
+		{expr: "1 / 0", err: ErrDivisionByZero},
+		{expr: "2 +", err: ErrInvalidExpression},
+		{expr: "2 # 3", err: ErrInvalidCharacter},
+		{expr: "x + 1", err: ErrUnknownIdentifier},
+	})
+}
+
+func TestNegation(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "-3^2", want: "-9"},
+		{expr: "x = 3; -x^2", want: "-9"},
+		{expr: "(-3)^2", want: "9"},
+		{expr: "2^-1", want: "0.5"},
+		{expr: "x = 2; 2^-x", want: "0.25"},
+		{expr: "-2 * 3", want: "-6"},
+		{expr: "2 * -3", want: "-6"},
+		{expr: "5 - -3", want: "8"},
+		{expr: "-3!", want: "-6"},
+		{expr: "-(2 + 3)", want: "-5"},
+		{expr: "-123456789012345678901234567890", want: "-123456789012345678901234567890"},
+	})
+}
+
+func TestNegationDecays(t *testing.T) {
+	if got := evalNumber(t, "x = 3; exp(-x^2)"); got >= 1 {
+		t.Errorf("exp(-x^2) at 3 = %g, want it below 1", got)
+	}
+}
+
+func TestLists(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "[1, 2, 3]", want: "[1, 2, 3]"},
+		{expr: "[]", want: "[]"},
+		{expr: "[1, 2] + [3, 4]", want: "[4, 6]"},
+		{expr: "[1, 2, 3] * 2", want: "[2, 4, 6]"},
+		{expr: "10 / [2, 5]", want: "[5, 2]"},
+		{expr: "[1, 2] + [1, 2, 3]", err: ErrShapeMismatch},
+		{expr: "[1, [2]]", err: ErrTypeMismatch},
+	})
+}
+
+func TestAggregates(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "sum([1, 2, 3])", want: "6"},
+		{expr: "sum(1, 2, 3)", want: "6"},
+		{expr: "sum([1, 2], 3)", want: "6"},
+		{expr: "prod([2, 3, 4])", want: "24"},
+		{expr: "mean([4, 5])", want: "4.5"},
+		{expr: "median([3, 1, 2])", want: "2"},
+		{expr: "median([4, 1, 3, 2])", want: "2.5"},
+		{expr: "stdev([2, 4, 4, 4, 5, 5, 7, 9])", want: "2.138089935299395"},
+		{expr: "min([3, -1, 2])", want: "-1"},
+		{expr: "max(3, -1, 2)", want: "3"},
+		{expr: "percentile([1, 2, 3, 4, 5], 50)", want: "3"},
+		{expr: "percentile([1, 2, 3, 4], 25)", want: "1.75"},
+		{expr: "[1, 2, 3] * 2 + mean([4, 5])", want: "[6.5, 8.5, 10.5]"},
+		{expr: "sum([])", err: ErrEmptyList},
+		{expr: "stdev([1])", err: ErrNotEnoughValues},
+		{expr: "percentile([1, 2], 101)", err: ErrInvalidPercentile},
+		{expr: "percentile([1, 2])", err: ErrArgumentCount},
+		{expr: "median()", err: ErrArgumentCount},
+		{expr: "nosuch(1)", err: ErrUnknownFunction},
+	})
+}
@@ -0,0 +1,92 @@
+package calculator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/opr1234/calculator/pkg/calculator"
+)
+
+// An evaluator configured through options evaluates expressions, scripts
+// and dates.
+func ExampleEvaluator_Evaluate() {
+	e := calculator.NewEvaluator(calculator.WithDialect("math"))
+
+	for _, expr := range []string{
+		"2(3 + 4)",
+		"30!",
+		"x = 3; 2x^2 + 1",
+		"2026-10-18 + 90d",
+	} {
+		res, err := e.Evaluate(context.Background(), expr)
+		if err != nil {
+			log.Fatalf("%s: %v", expr, err)
+		}
+		fmt.Printf("%s = %v\n", expr, res.Value)
+	}
+	// Output:
+	// 2(3 + 4) = 14
+	// 30! = 265252859812191058636308480000000
+	// x = 3; 2x^2 + 1 = 19
+	// 2026-10-18 + 90d = 2027-01-16
+}
+
+// The evaluator can be extended with Go functions and with functions
+// defined in the expression grammar.
+func ExampleWithFunction() {
+	hypot, err := calculator.ParseFunction("hypot(a, b) = sqrt(a^2 + b^2)")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	e := calculator.NewEvaluator(
+		calculator.WithDefinitions(hypot),
+		calculator.WithFunction("double", calculator.Function{
+			MinArgs: 1,
+			MaxArgs: 1,
+			Call: func(args []calculator.Value) (calculator.Value, error) {
+				x, ok := args[0].(calculator.Number)
+				if !ok {
+					return nil, calculator.ErrTypeMismatch
+				}
+				return 2 * x, nil
+			},
+		}),
+	)
+
+	res, err := e.Evaluate(context.Background(), "double(hypot(3, 4))")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(res.Value)
+	// Output: 10
+}
+
+// Limits bound evaluations, and PrecisionFloat rounds large integers to
+// float64.
+func ExampleWithLimits() {
+	e := calculator.NewEvaluator(
+		calculator.WithPrecision(calculator.PrecisionFloat),
+		calculator.WithLimits(calculator.Limits{
+			MaxLength: 64,
+			Timeout:   time.Second,
+		}),
+	)
+
+	res, err := e.Evaluate(context.Background(), "30!")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(res.Value)
+
+	_, err = e.Evaluate(context.Background(), "1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1 + 1")
+	if errors.Is(err, calculator.ErrTooLong) {
+		fmt.Println("rejected:", err)
+	}
+	// Output:
+	// 2.6525285981219107e+32
+	// rejected: expression is too long: 65 bytes, at most 64
+}
@@ -0,0 +1,197 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	ErrUnknownFunction   = errors.New("unknown function")
+	ErrArgumentCount     = errors.New("wrong number of arguments")
+	ErrInvalidPercentile = errors.New("percentile must be between 0 and 100")
+	ErrNotEnoughValues   = errors.New("not enough values")
+)
+
+// Function is a built-in callable from expressions. MaxArgs below zero
+// means the function accepts any number of arguments.
+type Function struct {
+	MinArgs int
+	MaxArgs int
+	Call    func(args []Value) (Value, error)
+}
+
+// constants are the named numbers expressions can use, i being the
+// imaginary unit. A binding of the same name, such as e = 5 or i = 2 in a
+// script, hides them.
+var constants = map[string]Value{
+	"pi": Number(math.Pi),
+	"e":  Number(math.E),
+	"i":  Complex(1i),
+}
+
+func (f Function) checkArity(name string, argc int) error {
+	if argc < f.MinArgs || (f.MaxArgs >= 0 && argc > f.MaxArgs) {
+		return fmt.Errorf("%w: %s got %d", ErrArgumentCount, name, argc)
+	}
+	return nil
+}
+
+func builtinFunctions() map[string]Function {
+	functions := map[string]Function{
+		"sum":    aggregate(sum),
+		"prod":   aggregate(product),
+		"mean":   aggregate(mean),
+		"median": aggregate(median),
+		"stdev":  aggregate(stdev),
+		"min":    aggregate(minimum),
+		"max":    aggregate(maximum),
+		"percentile": {
+			MinArgs: 2,
+			MaxArgs: 2,
+			Call:    percentile,
+		},
+	}
+	for name, fn := range matrixFunctions() {
+		functions[name] = fn
+	}
+	for name, fn := range elementaryFunctions() {
+		functions[name] = fn
+	}
+	for name, fn := range complexFunctions() {
+		functions[name] = fn
+	}
+	// Evaluations call the integer functions of their environment, bound
+	// to their context; these keep the names and arities known.
+	for name, fn := range integerFunctions(context.Background()) {
+		functions[name] = fn
+	}
+	for name, fn := range dateTimeFunctions() {
+		functions[name] = fn
+	}
+	return functions
+}
+
+// aggregate wraps a reduction so it accepts either a single list,
+// sum([1, 2, 3]), or the values themselves, sum(1, 2, 3).
+func aggregate(fn func([]float64) (float64, error)) Function {
+	return Function{
+		MinArgs: 1,
+		MaxArgs: -1,
+		Call: func(args []Value) (Value, error) {
+			values, err := flatten(args)
+			if err != nil {
+				return nil, err
+			}
+			if len(values) == 0 {
+				return nil, ErrEmptyList
+			}
+			res, err := fn(values)
+			return Number(res), err
+		},
+	}
+}
+
+func flatten(args []Value) ([]float64, error) {
+	var values []float64
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Number:
+			values = append(values, float64(v))
+		case Integer:
+			values = append(values, v.Float())
+		case List:
+			values = append(values, v...)
+		case IntegerList:
+			values = append(values, v.List()...)
+		default:
+			return nil, ErrTypeMismatch
+		}
+	}
+	return values, nil
+}
+
+func sum(values []float64) (float64, error) {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total, nil
+}
+
+func product(values []float64) (float64, error) {
+	total := 1.0
+	for _, v := range values {
+		total *= v
+	}
+	return total, nil
+}
+
+func mean(values []float64) (float64, error) {
+	total, _ := sum(values)
+	return total / float64(len(values)), nil
+}
+
+func median(values []float64) (float64, error) {
+	return quantile(values, 0.5), nil
+}
+
+// stdev returns the sample standard deviation.
+func stdev(values []float64) (float64, error) {
+	if len(values) < 2 {
+		return 0, ErrNotEnoughValues
+	}
+	avg, _ := mean(values)
+	var squares float64
+	for _, v := range values {
+		squares += (v - avg) * (v - avg)
+	}
+	return math.Sqrt(squares / float64(len(values)-1)), nil
+}
+
+func minimum(values []float64) (float64, error) {
+	res := values[0]
+	for _, v := range values[1:] {
+		res = math.Min(res, v)
+	}
+	return res, nil
+}
+
+func maximum(values []float64) (float64, error) {
+	res := values[0]
+	for _, v := range values[1:] {
+		res = math.Max(res, v)
+	}
+	return res, nil
+}
+
+// percentile(list, p) interpolates linearly between the closest ranks.
+func percentile(args []Value) (Value, error) {
+	values, err := flatten(args[:1])
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrEmptyList
+	}
+	p, ok := args[1].(Number)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	if p < 0 || p > 100 {
+		return nil, ErrInvalidPercentile
+	}
+	return Number(quantile(values, float64(p)/100)), nil
+}
+
+func quantile(values []float64, q float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
@@ -0,0 +1,224 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidInterval = errors.New("interval lower bound exceeds upper bound")
+
+const KindInterval Kind = "interval"
+
+// Interval is the closed range of reals [Lo, Hi], written [1.5 .. 2.5].
+// Arithmetic on intervals is rigorous: every bound is rounded outwards, so
+// the result always contains every value the operation can take.
+type Interval struct {
+	Lo, Hi float64
+}
+
+func (iv Interval) Kind() Kind { return KindInterval }
+
+func (iv Interval) String() string {
+	return "[" + Number(iv.Lo).String() + " .. " + Number(iv.Hi).String() + "]"
+}
+
+func (iv Interval) contains(x float64) bool {
+	return iv.Lo <= x && x <= iv.Hi
+}
+
+func newInterval(lo, hi Value) (Value, error) {
+	a, okA := lo.(Number)
+	b, okB := hi.(Number)
+	if !okA || !okB {
+		return nil, ErrTypeMismatch
+	}
+	if a > b {
+		return nil, fmt.Errorf("%w: [%v .. %v]", ErrInvalidInterval, a, b)
+	}
+	return Interval{Lo: float64(a), Hi: float64(b)}, nil
+}
+
+func toInterval(v Value) (Interval, bool) {
+	switch v := v.(type) {
+	case Interval:
+		return v, true
+	case Number:
+		return Interval{Lo: float64(v), Hi: float64(v)}, true
+	case Integer:
+		// The float approximation of a big integer is rounded, so the
+		// interval has to cover the neighbouring floats as well.
+		f := v.Float()
+		return Interval{Lo: math.Nextafter(f, math.Inf(-1)), Hi: math.Nextafter(f, math.Inf(1))}, true
+	}
+	return Interval{}, false
+}
+
+// intervalOperator applies a binary operator where at least one operand is
+// an interval. Plain numbers are treated as degenerate intervals.
+func intervalOperator(op string, a, b Value) (Value, error) {
+	x, ok := toInterval(a)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	if op == "^" {
+		n, ok := b.(Number)
+		if !ok {
+			return nil, ErrTypeMismatch
+		}
+		return intervalPower(x, float64(n))
+	}
+	y, ok := toInterval(b)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+
+	switch op {
+	case "+":
+		lo, _ := roundedSum(x.Lo, y.Lo)
+		_, hi := roundedSum(x.Hi, y.Hi)
+		return Interval{Lo: lo, Hi: hi}, nil
+	case "-":
+		lo, _ := roundedSum(x.Lo, -y.Hi)
+		_, hi := roundedSum(x.Hi, -y.Lo)
+		return Interval{Lo: lo, Hi: hi}, nil
+	case "*":
+		return hull(roundedProduct, x, y), nil
+	case "/":
+		if y.contains(0) {
+			return nil, ErrDivisionByZero
+		}
+		return hull(roundedQuotient, x, y), nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s", op)
+}
+
+// hull combines every pair of bounds and returns the smallest interval
+// holding all of the results.
+func hull(fn func(a, b float64) (float64, float64), x, y Interval) Interval {
+	res := Interval{Lo: math.Inf(1), Hi: math.Inf(-1)}
+	for _, a := range []float64{x.Lo, x.Hi} {
+		for _, b := range []float64{y.Lo, y.Hi} {
+			lo, hi := fn(a, b)
+			res.Lo = math.Min(res.Lo, lo)
+			res.Hi = math.Max(res.Hi, hi)
+		}
+	}
+	return res
+}
+
+// intervalPower raises an interval to a constant power. Even integer
+// powers of an interval around zero start at zero, x^2 of [-1 .. 2] is
+// [0 .. 4] rather than the [-2 .. 4] repeated multiplication would give.
+func intervalPower(x Interval, n float64) (Value, error) {
+	if n != math.Trunc(n) {
+		if x.Lo < 0 || n < 0 && x.Lo == 0 {
+			return nil, ErrDomain
+		}
+		lo, hi := math.Pow(x.Lo, n), math.Pow(x.Hi, n)
+		if n < 0 {
+			lo, hi = hi, lo
+		}
+		return widen(lo, hi), nil
+	}
+
+	if math.Abs(n) > maxExponent {
+		return nil, ErrTooLarge
+	}
+	if n < 0 {
+		if x.contains(0) {
+			return nil, ErrDivisionByZero
+		}
+		p, err := intervalPower(x, -n)
+		if err != nil {
+			return nil, err
+		}
+		return intervalOperator("/", Number(1), p)
+	}
+
+	k := uint64(n)
+	loLo, loHi := signedPower(x.Lo, k)
+	hiLo, hiHi := signedPower(x.Hi, k)
+	switch {
+	case k%2 == 1:
+		return Interval{Lo: loLo, Hi: hiHi}, nil
+	case x.contains(0):
+		return Interval{Lo: 0, Hi: math.Max(loHi, hiHi)}, nil
+	case x.Lo > 0:
+		return Interval{Lo: loLo, Hi: hiHi}, nil
+	}
+	return Interval{Lo: hiLo, Hi: loHi}, nil
+}
+
+// signedPower brackets b^k by squaring and multiplying with rounding in
+// both directions.
+func signedPower(b float64, k uint64) (float64, float64) {
+	lo, hi := 1.0, 1.0
+	baseLo, baseHi := math.Abs(b), math.Abs(b)
+	for n := k; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			lo, _ = roundedProduct(lo, baseLo)
+			_, hi = roundedProduct(hi, baseHi)
+		}
+		baseLo, _ = roundedProduct(baseLo, baseLo)
+		_, baseHi = roundedProduct(baseHi, baseHi)
+	}
+	if b < 0 && k%2 == 1 {
+		return -hi, -lo
+	}
+	return lo, hi
+}
+
+// widen rounds bounds computed by library functions outwards by one unit in
+// the last place, which covers their rounding error. Zero and infinite
+// bounds are exact and stay as they are.
+func widen(lo, hi float64) Interval {
+	if lo != 0 && !math.IsInf(lo, 0) {
+		lo = math.Nextafter(lo, math.Inf(-1))
+	}
+	if hi != 0 && !math.IsInf(hi, 0) {
+		hi = math.Nextafter(hi, math.Inf(1))
+	}
+	return Interval{Lo: lo, Hi: hi}
+}
+
+// roundedSum returns floats just below and just above the exact a+b. They
+// are equal when the sum is exact; the rounding error is recovered with
+// Knuth's two-sum.
+func roundedSum(a, b float64) (float64, float64) {
+	s := a + b
+	bb := s - a
+	return bracket(s, (a-(s-bb))+(b-bb))
+}
+
+// roundedProduct brackets the exact a*b, with the error from a fused
+// multiply-add.
+func roundedProduct(a, b float64) (float64, float64) {
+	p := a * b
+	return bracket(p, math.FMA(a, b, -p))
+}
+
+// roundedQuotient brackets the exact a/b. The remainder a - q*b has the
+// sign of the error times the sign of b.
+func roundedQuotient(a, b float64) (float64, float64) {
+	q := a / b
+	r := math.FMA(-q, b, a)
+	if b < 0 {
+		r = -r
+	}
+	return bracket(q, r)
+}
+
+func bracket(x, err float64) (float64, float64) {
+	switch {
+	case math.IsInf(x, 1):
+		return math.MaxFloat64, x
+	case math.IsInf(x, -1):
+		return x, -math.MaxFloat64
+	case err > 0:
+		return x, math.Nextafter(x, math.Inf(1))
+	case err < 0:
+		return math.Nextafter(x, math.Inf(-1)), x
+	}
+	return x, x
+}
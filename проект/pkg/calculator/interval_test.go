@@ -0,0 +1,63 @@
+package calculator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIntervals(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "[1 .. 2] + [3 .. 4]", want: "[4 .. 6]"},
+		{expr: "[1 .. 2] - [3 .. 4]", want: "[-3 .. -1]"},
+		{expr: "[1 .. 2] * [-1 .. 3]", want: "[-2 .. 6]"},
+		{expr: "[1 .. 2] / [4 .. 8]", want: "[0.125 .. 0.5]"},
+		{expr: "[1 .. 2] * 3", want: "[3 .. 6]"},
+		{expr: "[-1 .. 2]^2", want: "[0 .. 4]"},
+		{expr: "[2 .. 1]", err: ErrInvalidInterval},
+		{expr: "1 / [-1 .. 1]", err: ErrDivisionByZero},
+	})
+}
+
+// TestIntervalRounding checks that bounds are rounded outwards: the exact
+// result lies inside even where floating point arithmetic rounds.
+func TestIntervalRounding(t *testing.T) {
+	tests := []struct {
+		expr   string
+		lo, hi float64
+	}{
+		{"[0.1 .. 0.1] + [0.2 .. 0.2]", 0.3, 0.3},
+		{"[1 .. 1] / [3 .. 3]", 1.0 / 3, 1.0 / 3},
+		{"sqrt([4 .. 9])", 2, 3},
+		{"sin([0 .. 1])", 0, 0.8414709848078965},
+	}
+	for _, tt := range tests {
+		res, err := NewEvaluator().Evaluate(context.Background(), tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		iv, ok := res.Value.(Interval)
+		if !ok {
+			t.Errorf("%s = %v, want an interval", tt.expr, res.Value)
+			continue
+		}
+		if iv.Lo > tt.lo || iv.Hi < tt.hi || iv.Hi-iv.Lo > tt.hi-tt.lo+1e-12 {
+			t.Errorf("%s = %v, want a tight interval around [%g .. %g]", tt.expr, iv, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestUncertainty(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "9.81 ± 0.02", want: "9.81 ± 0.02"},
+		{expr: "1 +/- 0.1", want: "1 ± 0.1"},
+		{expr: "2 * (1 ± 0.1)", want: "2 ± 0.2"},
+		{expr: "(2 ± 0.1)^2", want: "4 ± 0.4"},
+		{expr: "sqrt(4 ± 0.4)", want: "2 ± 0.1"},
+		{expr: "(1 ± 0.3) - (1 ± 0.4)", want: "0 ± 0.5"},
+		{expr: "g = 9.81 ± 0.02; g - g", want: "0"},
+		{expr: "1 ± -0.1", err: ErrNegativeUncertainty},
+		{expr: "(1 ± 0.1) ± 0.1", err: ErrTypeMismatch},
+		{expr: "[1 .. 2] ± 1", err: ErrTypeMismatch},
+	})
+}
@@ -0,0 +1,267 @@
+package calculator
+
+import (
+	"strings"
+)
+
+// latex writes expressions as LaTeX math, without the surrounding $ signs.
+type latex struct{}
+
+// latexFunctions are the functions LaTeX has an operator command for.
+var latexFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "exp": true, "ln": true,
+	"min": true, "max": true, "det": true, "gcd": true, "arg": true,
+}
+
+// number writes the imaginary unit of a literal such as 4i upright, the
+// way imaginary writes it.
+func (l latex) number(text string) string {
+	if strings.HasSuffix(text, string(imaginaryUnit)) {
+		return strings.TrimSuffix(text, string(imaginaryUnit)) + l.imaginary()
+	}
+	return text
+}
+
+func (latex) imaginary() string {
+	return `\mathrm{i}`
+}
+
+// identifier writes one-letter names in italics and longer ones upright.
+// Trailing digits become a subscript, x1 is x₁.
+func (latex) identifier(name string) string {
+	base, index := splitIndex(name)
+	if len([]rune(base)) > 1 {
+		base = `\mathrm{` + latexEscape(base) + `}`
+	}
+	if index != "" {
+		return base + "_{" + index + "}"
+	}
+	return base
+}
+
+func (latex) binary(op, a, b string) string {
+	switch op {
+	case "*":
+		return a + ` \cdot ` + b
+	case "/":
+		return `\frac{` + a + `}{` + b + `}`
+	case "^":
+		return a + "^{" + b + "}"
+	case plusMinus:
+		return a + ` \pm ` + b
+	}
+	return a + " " + op + " " + b
+}
+
+func (latex) negate(a string) string {
+	return "-" + a
+}
+
+func (latex) factorial(a string) string {
+	return a + "!"
+}
+
+func (latex) group(a string) string {
+	return `\left(` + a + `\right)`
+}
+
+func (l latex) call(name string, args []string) string {
+	switch {
+	case name == "sqrt" && len(args) == 1:
+		return `\sqrt{` + args[0] + `}`
+	case name == "abs" && len(args) == 1:
+		return `\left|` + args[0] + `\right|`
+	case latexFunctions[name]:
+		name = `\` + name
+	case len(name) > 1:
+		name = `\operatorname{` + latexEscape(name) + `}`
+	}
+	return name + l.group(strings.Join(args, ", "))
+}
+
+func (latex) list(items []string) string {
+	return `\left[` + strings.Join(items, ", ") + `\right]`
+}
+
+func (latex) matrix(rows [][]string) string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		lines[i] = strings.Join(row, " & ")
+	}
+	return `\begin{pmatrix}` + strings.Join(lines, ` \\ `) + `\end{pmatrix}`
+}
+
+func (latex) interval(lo, hi string) string {
+	return `\left[` + lo + ", " + hi + `\right]`
+}
+
+func (latex) series(name, variable, from, to, body string) string {
+	symbol := `\sum`
+	if name == "prod" {
+		symbol = `\prod`
+	}
+	return symbol + "_{" + variable + "=" + from + "}^{" + to + "} " + body
+}
+
+func (latex) integral(variable, from, to, body string) string {
+	return `\int_{` + from + "}^{" + to + "} " + body + `\,\mathrm{d}` + variable
+}
+
+func (latex) limit(variable, point string, side int, body string) string {
+	switch side {
+	case 1:
+		point = "{" + point + "}^{+}"
+	case -1:
+		point = "{" + point + "}^{-}"
+	}
+	return `\lim_{` + variable + ` \to ` + point + "} " + body
+}
+
+func (latex) assignment(name, value string) string {
+	return name + " = " + value
+}
+
+func (latex) document(statements []string) string {
+	return strings.Join(statements, `;\quad `)
+}
+
+func latexEscape(s string) string {
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// mathml writes expressions as presentation MathML. Every construct is a
+// single element, so it can be used as the argument of another one.
+type mathml struct{}
+
+func (m mathml) number(text string) string {
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+	res := "<mn>" + text + "</mn>"
+	if strings.HasSuffix(text, string(imaginaryUnit)) {
+		res = "<mrow><mn>" + strings.TrimSuffix(text, string(imaginaryUnit)) + "</mn>" + m.imaginary() + "</mrow>"
+	}
+	if negative {
+		return m.negate(res)
+	}
+	return res
+}
+
+func (mathml) imaginary() string {
+	return `<mi mathvariant="normal">i</mi>`
+}
+
+func (mathml) identifier(name string) string {
+	base, index := splitIndex(name)
+	if index != "" {
+		return "<msub><mi>" + base + "</mi><mn>" + index + "</mn></msub>"
+	}
+	return "<mi>" + base + "</mi>"
+}
+
+func (mathml) binary(op, a, b string) string {
+	switch op {
+	case "/":
+		return "<mfrac>" + a + b + "</mfrac>"
+	case "^":
+		return "<msup>" + a + b + "</msup>"
+	case "-":
+		op = "−"
+	case "*":
+		op = "⋅"
+	}
+	return "<mrow>" + a + "<mo>" + op + "</mo>" + b + "</mrow>"
+}
+
+func (mathml) negate(a string) string {
+	return "<mrow><mo>−</mo>" + a + "</mrow>"
+}
+
+func (mathml) factorial(a string) string {
+	return "<mrow>" + a + "<mo>!</mo></mrow>"
+}
+
+func (mathml) group(a string) string {
+	return "<mrow><mo>(</mo>" + a + "<mo>)</mo></mrow>"
+}
+
+func (m mathml) call(name string, args []string) string {
+	switch {
+	case name == "sqrt" && len(args) == 1:
+		return "<msqrt>" + args[0] + "</msqrt>"
+	case name == "abs" && len(args) == 1:
+		return "<mrow><mo>|</mo>" + args[0] + "<mo>|</mo></mrow>"
+	}
+	// U+2061 FUNCTION APPLICATION tells readers that name is applied to
+	// the parenthesized arguments rather than multiplied by them.
+	return "<mrow><mi>" + name + "</mi><mo>&#x2061;</mo>" + m.group(strings.Join(args, "<mo>,</mo>")) + "</mrow>"
+}
+
+func (mathml) list(items []string) string {
+	return "<mrow><mo>[</mo>" + strings.Join(items, "<mo>,</mo>") + "<mo>]</mo></mrow>"
+}
+
+func (mathml) matrix(rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("<mrow><mo>(</mo><mtable>")
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range row {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable><mo>)</mo></mrow>")
+	return b.String()
+}
+
+func (mathml) interval(lo, hi string) string {
+	return "<mrow><mo>[</mo>" + lo + "<mo>,</mo>" + hi + "<mo>]</mo></mrow>"
+}
+
+func (mathml) series(name, variable, from, to, body string) string {
+	symbol := "∑"
+	if name == "prod" {
+		symbol = "∏"
+	}
+	return "<mrow><munderover><mo>" + symbol + "</mo><mrow>" + variable + "<mo>=</mo>" + from + "</mrow>" +
+		to + "</munderover>" + body + "</mrow>"
+}
+
+func (mathml) integral(variable, from, to, body string) string {
+	return "<mrow><msubsup><mo>∫</mo>" + from + to + "</msubsup>" + body +
+		"<mspace width=\"0.17em\"/><mi mathvariant=\"normal\">d</mi>" + variable + "</mrow>"
+}
+
+func (mathml) limit(variable, point string, side int, body string) string {
+	switch side {
+	case 1:
+		point = "<msup>" + point + "<mo>+</mo></msup>"
+	case -1:
+		point = "<msup>" + point + "<mo>−</mo></msup>"
+	}
+	return "<mrow><munder><mo>lim</mo><mrow>" + variable + "<mo>→</mo>" + point + "</mrow></munder>" + body + "</mrow>"
+}
+
+func (mathml) assignment(name, value string) string {
+	return "<mrow>" + name + "<mo>=</mo>" + value + "</mrow>"
+}
+
+func (mathml) document(statements []string) string {
+	body := statements[0]
+	if len(statements) > 1 {
+		body = "<mrow>" + strings.Join(statements, "<mo>;</mo><mspace width=\"1em\"/>") + "</mrow>"
+	}
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">` + body + "</math>"
+}
+
+// splitIndex splits trailing digits off a name, as in x1.
+func splitIndex(name string) (string, string) {
+	i := len(name)
+	for i > 1 && isDigit(rune(name[i-1])) {
+		i--
+	}
+	if i == len(name) || name[i-1] == '_' {
+		return name, ""
+	}
+	return name[:i], name[i:]
+}
@@ -0,0 +1,325 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+var (
+	ErrNotSquare      = errors.New("matrix is not square")
+	ErrSingularMatrix = errors.New("matrix is singular")
+)
+
+// singularTolerance is the pivot magnitude below which a matrix is
+// treated as singular during elimination.
+const singularTolerance = 1e-12
+
+// Matrix is a rectangular table of numbers stored row by row. Matrix
+// literals are written as lists of rows: [[1, 2], [3, 4]].
+type Matrix [][]float64
+
+func (m Matrix) Kind() Kind { return KindMatrix }
+
+func (m Matrix) String() string {
+	rows := make([]string, len(m))
+	for i, row := range m {
+		rows[i] = List(row).String()
+	}
+	return "[" + strings.Join(rows, ", ") + "]"
+}
+
+func (m Matrix) rows() int { return len(m) }
+
+func (m Matrix) cols() int {
+	if len(m) == 0 {
+		return 0
+	}
+	return len(m[0])
+}
+
+func (m Matrix) clone() Matrix {
+	res := make(Matrix, len(m))
+	for i, row := range m {
+		res[i] = append([]float64(nil), row...)
+	}
+	return res
+}
+
+func newMatrix(rows, cols int) Matrix {
+	res := make(Matrix, rows)
+	for i := range res {
+		res[i] = make([]float64, cols)
+	}
+	return res
+}
+
+func identity(n int) Matrix {
+	res := newMatrix(n, n)
+	for i := range res {
+		res[i][i] = 1
+	}
+	return res
+}
+
+// matrixOperator applies a binary operator where at least one operand is a
+// matrix. "*" between matrices and vectors is the matrix product, a number
+// is broadcast over every element for the remaining combinations.
+func matrixOperator(op string, a, b Value) (Value, error) {
+	switch a := a.(type) {
+	case Number:
+		if b, ok := b.(Matrix); ok && op != "^" {
+			return mapMatrix(b, func(v float64) (float64, error) { return arithmetic(op, float64(a), v) })
+		}
+	case List:
+		if b, ok := b.(Matrix); ok && op == "*" {
+			return vectorProduct(a, b)
+		}
+	case Matrix:
+		switch b := b.(type) {
+		case Number:
+			if op == "^" {
+				return matrixPower(a, float64(b))
+			}
+			return mapMatrix(a, func(v float64) (float64, error) { return arithmetic(op, v, float64(b)) })
+		case List:
+			if op == "*" {
+				return matrixVectorProduct(a, b)
+			}
+		case Matrix:
+			switch op {
+			case "+", "-":
+				if a.rows() != b.rows() || a.cols() != b.cols() {
+					return nil, ErrShapeMismatch
+				}
+				res := newMatrix(a.rows(), a.cols())
+				for i := range res {
+					for j := range res[i] {
+						res[i][j], _ = arithmetic(op, a[i][j], b[i][j])
+					}
+				}
+				return res, nil
+			case "*":
+				return matrixProduct(a, b)
+			}
+		}
+	}
+	return nil, ErrTypeMismatch
+}
+
+func mapMatrix(m Matrix, fn func(float64) (float64, error)) (Value, error) {
+	res := newMatrix(m.rows(), m.cols())
+	for i := range m {
+		for j := range m[i] {
+			v, err := fn(m[i][j])
+			if err != nil {
+				return nil, err
+			}
+			res[i][j] = v
+		}
+	}
+	return res, nil
+}
+
+func matrixProduct(a, b Matrix) (Matrix, error) {
+	if a.cols() != b.rows() {
+		return nil, ErrShapeMismatch
+	}
+	res := newMatrix(a.rows(), b.cols())
+	for i := range res {
+		for j := range res[i] {
+			for k := 0; k < a.cols(); k++ {
+				res[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return res, nil
+}
+
+// matrixVectorProduct treats the list as a column vector.
+func matrixVectorProduct(m Matrix, v List) (Value, error) {
+	if m.cols() != len(v) {
+		return nil, ErrShapeMismatch
+	}
+	res := make(List, m.rows())
+	for i := range res {
+		for j, x := range v {
+			res[i] += m[i][j] * x
+		}
+	}
+	return res, nil
+}
+
+// vectorProduct treats the list as a row vector.
+func vectorProduct(v List, m Matrix) (Value, error) {
+	if len(v) != m.rows() {
+		return nil, ErrShapeMismatch
+	}
+	res := make(List, m.cols())
+	for j := range res {
+		for i, x := range v {
+			res[j] += x * m[i][j]
+		}
+	}
+	return res, nil
+}
+
+// matrixPower raises a square matrix to a non-negative integer power.
+func matrixPower(m Matrix, n float64) (Value, error) {
+	if m.rows() != m.cols() {
+		return nil, ErrNotSquare
+	}
+	if n < 0 || n != math.Trunc(n) {
+		return nil, ErrTypeMismatch
+	}
+	res := identity(m.rows())
+	for i := 0; i < int(n); i++ {
+		res, _ = matrixProduct(res, m)
+	}
+	return res, nil
+}
+
+func transpose(m Matrix) Matrix {
+	res := newMatrix(m.cols(), m.rows())
+	for i := range m {
+		for j := range m[i] {
+			res[j][i] = m[i][j]
+		}
+	}
+	return res
+}
+
+// determinant uses Gaussian elimination with partial pivoting.
+func determinant(m Matrix) (float64, error) {
+	if m.rows() != m.cols() {
+		return 0, ErrNotSquare
+	}
+	a := m.clone()
+	det := 1.0
+	for col := range a {
+		pivot := pivotRow(a, col)
+		if math.Abs(a[pivot][col]) < singularTolerance {
+			return 0, nil
+		}
+		if pivot != col {
+			a[pivot], a[col] = a[col], a[pivot]
+			det = -det
+		}
+		det *= a[col][col]
+		for row := col + 1; row < len(a); row++ {
+			factor := a[row][col] / a[col][col]
+			for k := col; k < len(a); k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+		}
+	}
+	return det, nil
+}
+
+// solveSystem reduces [a | b] with Gauss-Jordan elimination and returns the
+// right-hand side, which then holds the solution of a * x = b.
+func solveSystem(a, b Matrix) (Matrix, error) {
+	if a.rows() != a.cols() {
+		return nil, ErrNotSquare
+	}
+	if a.rows() != b.rows() {
+		return nil, ErrShapeMismatch
+	}
+	a, b = a.clone(), b.clone()
+	for col := range a {
+		pivot := pivotRow(a, col)
+		if math.Abs(a[pivot][col]) < singularTolerance {
+			return nil, ErrSingularMatrix
+		}
+		a[pivot], a[col] = a[col], a[pivot]
+		b[pivot], b[col] = b[col], b[pivot]
+
+		scale := a[col][col]
+		for k := range a[col] {
+			a[col][k] /= scale
+		}
+		for k := range b[col] {
+			b[col][k] /= scale
+		}
+
+		for row := range a {
+			if row == col || a[row][col] == 0 {
+				continue
+			}
+			factor := a[row][col]
+			for k := range a[row] {
+				a[row][k] -= factor * a[col][k]
+			}
+			for k := range b[row] {
+				b[row][k] -= factor * b[col][k]
+			}
+		}
+	}
+	return b, nil
+}
+
+func pivotRow(a Matrix, col int) int {
+	pivot := col
+	for row := col + 1; row < len(a); row++ {
+		if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+			pivot = row
+		}
+	}
+	return pivot
+}
+
+func matrixFunctions() map[string]Function {
+	return map[string]Function{
+		"transpose": {MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) {
+			m, err := asMatrix(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return transpose(m), nil
+		}},
+		"det": {MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) {
+			m, err := asMatrix(args[0])
+			if err != nil {
+				return nil, err
+			}
+			det, err := determinant(m)
+			return Number(det), err
+		}},
+		"inv": {MinArgs: 1, MaxArgs: 1, Call: func(args []Value) (Value, error) {
+			m, err := asMatrix(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return solveSystem(m, identity(m.rows()))
+		}},
+		"solve": {MinArgs: 2, MaxArgs: 2, Call: solve},
+	}
+}
+
+// solve(A, b) returns x such that A * x = b. A list on the right-hand side
+// is a single column and yields a list.
+func solve(args []Value) (Value, error) {
+	a, err := asMatrix(args[0])
+	if err != nil {
+		return nil, err
+	}
+	switch b := plainList(args[1]).(type) {
+	case List:
+		x, err := solveSystem(a, transpose(Matrix{b}))
+		if err != nil {
+			return nil, err
+		}
+		return List(transpose(x)[0]), nil
+	case Matrix:
+		return solveSystem(a, b)
+	}
+	return nil, ErrTypeMismatch
+}
+
+func asMatrix(v Value) (Matrix, error) {
+	m, ok := v.(Matrix)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	return m, nil
+}
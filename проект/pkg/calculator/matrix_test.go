@@ -0,0 +1,46 @@
+package calculator
+
+import "testing"
+
+func TestMatrixArithmetic(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "[[1, 2], [3, 4]]", want: "[[1, 2], [3, 4]]"},
+		{expr: "[[1, 2], [3, 4]] + [[1, 1], [1, 1]]", want: "[[2, 3], [4, 5]]"},
+		{expr: "[[1, 2], [3, 4]] - [[1, 1], [1, 1]]", want: "[[0, 1], [2, 3]]"},
+		{expr: "2 * [[1, 2], [3, 4]]", want: "[[2, 4], [6, 8]]"},
+		{expr: "[[1, 2], [3, 4]] / 2", want: "[[0.5, 1], [1.5, 2]]"},
+		{expr: "[[1, 2], [3, 4]] * [[5, 6], [7, 8]]", want: "[[19, 22], [43, 50]]"},
+		{expr: "[[1, 2], [3, 4]] * [1, 1]", want: "[3, 7]"},
+		{expr: "[1, 1] * [[1, 2], [3, 4]]", want: "[4, 6]"},
+		{expr: "[[1, 1], [0, 1]]^3", want: "[[1, 3], [0, 1]]"},
+		{expr: "[[1, 2], [3, 4]]^0", want: "[[1, 0], [0, 1]]"},
+		{expr: "[[1, 2], [3, 4]] + [[1, 2, 3], [4, 5, 6]]", err: ErrShapeMismatch},
+		{expr: "[[1, 2, 3]] * [[1, 2, 3]]", err: ErrShapeMismatch},
+		{expr: "[[1, 2], [3]]", err: ErrShapeMismatch},
+		{expr: "[[1, 2, 3]]^2", err: ErrNotSquare},
+		{expr: "[[1, 2], [3, 4]]^0.5", err: ErrTypeMismatch},
+	})
+}
+
+func TestMatrixFunctions(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "transpose([[1, 2, 3], [4, 5, 6]])", want: "[[1, 4], [2, 5], [3, 6]]"},
+		{expr: "det([[2, 0], [0, 3]])", want: "6"},
+		{expr: "det([[1, 2], [2, 4]])", want: "0"},
+		{expr: "inv([[2, 0], [0, 4]])", want: "[[0.5, 0], [0, 0.25]]"},
+		{expr: "solve([[2, 0], [0, 4]], [2, 8])", want: "[1, 2]"},
+		{expr: "solve([[1, 0], [0, 1]], [[1, 2], [3, 4]])", want: "[[1, 2], [3, 4]]"},
+		{expr: "det([[1, 2, 3]])", err: ErrNotSquare},
+		{expr: "inv([[1, 2], [2, 4]])", err: ErrSingularMatrix},
+		{expr: "solve([[1, 2], [3, 4]], [1, 2, 3])", err: ErrShapeMismatch},
+		{expr: "det([1, 2])", err: ErrTypeMismatch},
+		{expr: "transpose(1, 2)", err: ErrArgumentCount},
+	})
+
+	if got := evalNumber(t, "det([[1, 2], [3, 4]])"); !closeTo(got, -2, 1e-12) {
+		t.Errorf("det([[1, 2], [3, 4]]) = %g, want -2", got)
+	}
+	if got := evalNumber(t, "x = solve([[1, 2], [3, 4]], [5, 6]); sum(x)"); !closeTo(got, 0.5, 1e-12) {
+		t.Errorf("sum of the solution = %g, want -4 + 4.5", got)
+	}
+}
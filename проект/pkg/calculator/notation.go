@@ -0,0 +1,356 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownNotation = errors.New("unknown notation")
+
+// Notation is the way operators are placed relative to their operands.
+type Notation string
+
+const (
+	// NotationInfix is the usual notation, (2 + 3) * 4. It is the default.
+	NotationInfix Notation = "infix"
+	// NotationRPN is reverse Polish notation, 2 3 + 4 *.
+	NotationRPN Notation = "rpn"
+	// NotationPrefix is Polish notation, * + 2 3 4.
+	NotationPrefix Notation = "prefix"
+)
+
+// In RPN and prefix notation tokens are separated by spaces. Unary minus
+// is written neg, a list [ 1 2 3 ] and an interval 1 2 .. in RPN. In
+// prefix a - whose first operand ends the expression or list is unary as
+// well, so - 5 is -5 while - 5 3 is 2. A built-in function takes the
+// fewest arguments it accepts; name@n or name/n calls a function with n
+// arguments, as in 1 2 3 max@3 or max/3 1 2 3. User functions are always
+// called that way, f@1.
+
+// parse turns a single expression in the given notation into postfix
+// form. The dialect only matters for infix.
+func (e *Evaluator) parse(expr string, notation Notation, d *Dialect) ([]token, error) {
+	switch notation {
+	case "", NotationInfix:
+		return e.compile(expr, d)
+	case NotationRPN:
+		return e.readRPN(strings.Fields(expr))
+	case NotationPrefix:
+		return e.readPrefix(strings.Fields(expr))
+	}
+	return nil, ErrUnknownNotation
+}
+
+func (e *Evaluator) readRPN(fields []string) ([]token, error) {
+	var postfix []token
+	var marks []int
+	depth := 0
+	for _, f := range fields {
+		switch f {
+		case "[":
+			marks = append(marks, depth)
+			continue
+		case "]":
+			if len(marks) == 0 {
+				return nil, ErrInvalidExpression
+			}
+			mark := marks[len(marks)-1]
+			marks = marks[:len(marks)-1]
+			postfix = append(postfix, token{kind: tokenList, argc: depth - mark})
+			depth = mark + 1
+			continue
+		}
+
+		t, arity, err := e.readToken(f)
+		if err != nil {
+			return nil, err
+		}
+		if depth-arity < 0 || len(marks) > 0 && depth-arity < marks[len(marks)-1] {
+			return nil, ErrInvalidExpression
+		}
+		depth += 1 - arity
+		postfix = append(postfix, t)
+	}
+
+	if len(marks) > 0 || depth != 1 {
+		return nil, ErrInvalidExpression
+	}
+	return postfix, nil
+}
+
+func (e *Evaluator) readPrefix(fields []string) ([]token, error) {
+	var postfix []token
+	pos := 0
+
+	var read func() error
+	read = func() error {
+		if pos == len(fields) {
+			return ErrInvalidExpression
+		}
+		f := fields[pos]
+		pos++
+
+		switch f {
+		case "]":
+			return ErrInvalidExpression
+		case "[":
+			argc := 0
+			for pos < len(fields) && fields[pos] != "]" {
+				if err := read(); err != nil {
+					return err
+				}
+				argc++
+			}
+			if pos == len(fields) {
+				return ErrInvalidExpression
+			}
+			pos++
+			postfix = append(postfix, token{kind: tokenList, argc: argc})
+			return nil
+		}
+
+		t, arity, err := e.readToken(f)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < arity; i++ {
+			if err := read(); err != nil {
+				return err
+			}
+			if f == "-" && i == 0 && (pos == len(fields) || fields[pos] == "]") {
+				t, arity = token{kind: tokenOperator, text: negation}, 1
+			}
+		}
+		postfix = append(postfix, t)
+		return nil
+	}
+
+	if err := read(); err != nil {
+		return nil, err
+	}
+	if pos != len(fields) {
+		return nil, ErrInvalidExpression
+	}
+	return postfix, nil
+}
+
+// readToken reads a token of RPN or prefix notation and returns it with
+// the number of operands it takes.
+func (e *Evaluator) readToken(f string) (token, int, error) {
+	switch f {
+	case "+", "-", "*", "/", "^", plusMinus:
+		return token{kind: tokenOperator, text: f}, 2, nil
+	case "+/-":
+		return token{kind: tokenOperator, text: plusMinus}, 2, nil
+	case negation:
+		return token{kind: tokenOperator, text: negation}, 1, nil
+	case "!":
+		return token{kind: tokenPostfix, text: f}, 1, nil
+	case "..":
+		return token{kind: tokenInterval, argc: 2}, 2, nil
+	}
+
+	if digits := strings.TrimPrefix(f, "-"); digits != "" && (isDigit(rune(digits[0])) || digits[0] == '.') {
+		if _, err := parseLiteral(f, time.UTC); err != nil {
+			return token{}, 0, fmt.Errorf("%w: %q", ErrInvalidExpression, f)
+		}
+		return token{kind: tokenNumber, text: f}, 0, nil
+	}
+
+	name, count, ok := strings.Cut(f, "@")
+	if !ok && f != "/" {
+		name, count, ok = strings.Cut(f, "/")
+	}
+	if ok {
+		argc, err := strconv.Atoi(count)
+		if !isIdentifier(name) || err != nil || argc < 0 {
+			return token{}, 0, fmt.Errorf("%w: %q", ErrInvalidExpression, f)
+		}
+		return token{kind: tokenCall, text: name, argc: argc}, argc, nil
+	}
+
+	if !isIdentifier(f) {
+		return token{}, 0, fmt.Errorf("%w: %q", ErrInvalidExpression, f)
+	}
+	if argc, ok := e.arity(f); ok {
+		return token{kind: tokenCall, text: f, argc: argc}, argc, nil
+	}
+	return token{kind: tokenIdent, text: f}, 0, nil
+}
+
+// arity returns the number of arguments a built-in called by name alone
+// takes, and false when name is not a built-in.
+func (e *Evaluator) arity(name string) (int, bool) {
+	e.mu.RLock()
+	fn, ok := e.functions[name]
+	e.mu.RUnlock()
+	return fn.MinArgs, ok
+}
+
+// Convert rewrites an expression or script from one notation into
+// another. Assignments keep their "name =" in front.
+func Convert(expr string, from, to Notation) (string, error) {
+	e := NewEvaluator()
+	if from == "" || from == NotationInfix {
+		if err := e.Validate(expr); err != nil {
+			return "", err
+		}
+	}
+	switch to {
+	case "", NotationInfix, NotationRPN, NotationPrefix:
+	default:
+		return "", ErrUnknownNotation
+	}
+	return e.rewrite(expr, from, Standard, to)
+}
+
+// rewrite parses every statement of a script and writes it out again in
+// notation to.
+func (e *Evaluator) rewrite(expr string, from Notation, d *Dialect, to Notation) (string, error) {
+	statements := splitStatements(expr)
+	if len(statements) == 0 {
+		return "", ErrEmptyExpression
+	}
+
+	converted := make([]string, len(statements))
+	for i, stmt := range statements {
+		name, body, err := parseAssignment(d.statement(stmt))
+		if err != nil {
+			return "", err
+		}
+		body, unit := splitConversion(body)
+		postfix, err := e.parse(body, from, d)
+		if err != nil {
+			return "", err
+		}
+		root, err := parseTree(postfix)
+		if err != nil {
+			return "", err
+		}
+
+		var text string
+		if to == NotationRPN || to == NotationPrefix {
+			var fields []string
+			if err := e.writeFields(root, to, &fields); err != nil {
+				return "", err
+			}
+			text = strings.Join(fields, " ")
+		} else {
+			text = writeInfix(root).text
+		}
+		if unit != "" {
+			text += " in " + unit
+		}
+		if name != "" {
+			text = name + " = " + text
+		}
+		converted[i] = text
+	}
+	return strings.Join(converted, "; "), nil
+}
+
+// writeFields writes the tree in RPN or prefix notation.
+func (e *Evaluator) writeFields(n *node, to Notation, fields *[]string) error {
+	var text string
+	switch t := n.t; t.kind {
+	case tokenNumber, tokenIdent, tokenOperator, tokenPostfix:
+		text = t.text
+	case tokenInterval:
+		text = ".."
+	case tokenCall:
+		text = t.text
+		if argc, ok := e.arity(t.text); !ok || argc != t.argc {
+			text += "@" + strconv.Itoa(t.argc)
+		}
+	case tokenList:
+		*fields = append(*fields, "[")
+		for _, arg := range n.args {
+			if err := e.writeFields(arg, to, fields); err != nil {
+				return err
+			}
+		}
+		*fields = append(*fields, "]")
+		return nil
+	case tokenClosure:
+		return fmt.Errorf("%w: an expression argument cannot be written in %s notation", ErrInvalidExpression, to)
+	}
+
+	if to == NotationPrefix {
+		*fields = append(*fields, text)
+	}
+	for _, arg := range n.args {
+		if err := e.writeFields(arg, to, fields); err != nil {
+			return err
+		}
+	}
+	if to == NotationRPN {
+		*fields = append(*fields, text)
+	}
+	return nil
+}
+
+// Precedence levels of the standard dialect beyond its binary operators,
+// which are all left-associative. Negation sits between products and
+// powers.
+const (
+	infixNegation  = 3
+	infixFactorial = 5
+	infixAtom      = 6
+)
+
+// writeInfix writes the tree in infix notation with the parentheses the
+// evaluator needs to read it back the same way, and a few more for
+// readers: the base of a power and the operand of a negation are
+// parenthesized unless they are atoms, so -(x^2) and (-x)^2 stay apart.
+func writeInfix(n *node) piece {
+	operand := func(n *node, min int) string {
+		p := writeInfix(n)
+		if p.prec < min {
+			return "(" + p.text + ")"
+		}
+		return p.text
+	}
+	args := func() []string {
+		nodes := n.args
+		if len(nodes) > 0 && nodes[len(nodes)-1].t.kind == tokenClosure {
+			nodes = seriesArguments(seriesForms[n.t.text], nodes)
+		}
+		texts := make([]string, len(nodes))
+		for i, arg := range nodes {
+			texts[i] = writeInfix(arg).text
+		}
+		return texts
+	}
+
+	switch t := n.t; t.kind {
+	case tokenNumber:
+		if strings.HasPrefix(t.text, "-") {
+			return piece{text: t.text, prec: infixNegation}
+		}
+		return piece{text: t.text, prec: infixAtom}
+	case tokenIdent:
+		return piece{text: t.text, prec: infixAtom}
+	case tokenList:
+		return piece{text: "[" + strings.Join(args(), ", ") + "]", prec: infixAtom}
+	case tokenInterval:
+		return piece{text: "[" + strings.Join(args(), " .. ") + "]", prec: infixAtom}
+	case tokenCall:
+		return piece{text: t.text + "(" + strings.Join(args(), ", ") + ")", prec: infixAtom}
+	case tokenPostfix:
+		return piece{text: operand(n.args[0], infixFactorial) + t.text, prec: infixFactorial}
+	case tokenOperator:
+		if t.text == negation {
+			return piece{text: "-" + operand(n.args[0], infixFactorial), prec: infixNegation}
+		}
+		prec, _ := Standard.precedence(t.text)
+		left := prec
+		if t.text == "^" {
+			left = infixFactorial
+		}
+		return piece{text: operand(n.args[0], left) + " " + t.text + " " + operand(n.args[1], prec+1), prec: prec}
+	}
+	return piece{prec: infixAtom}
+}
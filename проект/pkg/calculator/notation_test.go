@@ -0,0 +1,117 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNotations(t *testing.T) {
+	rpn := Options{Notation: NotationRPN}
+	prefix := Options{Notation: NotationPrefix}
+	runEvalTests(t, []evalTest{
+		{expr: "2 3 + 4 *", opts: rpn, want: "20"},
+		{expr: "* + 2 3 4", opts: prefix, want: "20"},
+		{expr: "3 neg 2 ^", opts: rpn, want: "9"},
+		{expr: "16 sqrt", opts: rpn, want: "4"},
+		{expr: "1 2 3 max@3", opts: rpn, want: "3"},
+		{expr: "[ 1 2 3 ] sum", opts: rpn, want: "6"},
+		{expr: "5 !", opts: rpn, want: "120"},
+		{expr: "1 2 ..", opts: rpn, want: "[1 .. 2]"},
+		{expr: ".. 1 2", opts: prefix, want: "[1 .. 2]"},
+		{expr: "9.81 0.02 +/-", opts: rpn, want: "9.81 ± 0.02"},
+		{expr: "a = 2 3 +; a 2 *", opts: rpn, want: "10"},
+		{expr: "2 +", opts: rpn, err: ErrInvalidExpression},
+		{expr: "2 3", opts: rpn, err: ErrInvalidExpression},
+		{expr: "+ 2", opts: prefix, err: ErrInvalidExpression},
+		{expr: "1 2 3 max@9", opts: rpn, err: ErrInvalidExpression},
+
+		// Variadic functions take an arity marker, in either notation.
+		{expr: "1 7 3 max/3", opts: rpn, want: "7"},
+		{expr: "max@3 1 7 3", opts: prefix, want: "7"},
+		{expr: "+ sum/4 1 2 3 4 1", opts: prefix, want: "11"},
+		{expr: "max/0", opts: prefix, err: ErrArgumentCount},
+		{expr: "1 2 max/x", opts: rpn, err: ErrInvalidExpression},
+
+		// List literals, nested too.
+		{expr: "sum [ 1 2 3 ]", opts: prefix, want: "6"},
+		{expr: "[ 1 2 ] [ 3 4 ] +", opts: rpn, want: "[4, 6]"},
+		{expr: "[ [ 1 2 ] [ 3 4 ] ] det", opts: rpn, want: "-2"},
+		{expr: "det [ [ 1 2 ] [ 3 4 ] ]", opts: prefix, want: "-2"},
+		{expr: "sum [ 1 2", opts: prefix, err: ErrInvalidExpression},
+
+		// Unary minus in prefix.
+		{expr: "- 5", opts: prefix, want: "-5"},
+		{expr: "- 5 3", opts: prefix, want: "2"},
+		{expr: "+ 1 - 5", opts: prefix, want: "-4"},
+		{expr: "- ^ 2 2", opts: prefix, want: "-4"},
+		{expr: "sum [ 1 - 2 ]", opts: prefix, want: "-1"},
+		{expr: "-", opts: prefix, err: ErrInvalidExpression},
+		{expr: "1 2 +", opts: Options{Notation: "roman"}, err: ErrUnknownNotation},
+	})
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		expr     string
+		from, to Notation
+		want     string
+	}{
+		{"(2 + 3) * 4", NotationInfix, NotationRPN, "2 3 + 4 *"},
+		{"(2 + 3) * 4", NotationInfix, NotationPrefix, "* + 2 3 4"},
+		{"-x^2", NotationInfix, NotationRPN, "x 2 ^ neg"},
+		{"(-x)^2", NotationInfix, NotationRPN, "x neg 2 ^"},
+		{"sqrt(16)", NotationInfix, NotationPrefix, "sqrt 16"},
+		{"[1 .. 2] + 1", NotationInfix, NotationRPN, "1 2 .. 1 +"},
+		{"a = 1 + 2; a * max(a, 4)", NotationInfix, NotationRPN, "a = 1 2 +; a a 4 max@2 *"},
+		{"a = 1 2 +; a a 4 max@2 *", NotationRPN, NotationPrefix, "a = + 1 2; * a max@2 a 4"},
+		{"x 2 ^ neg", NotationRPN, NotationInfix, "-(x ^ 2)"},
+		{"x neg 2 ^", NotationRPN, NotationInfix, "(-x) ^ 2"},
+		{"1 2 3 - -", NotationRPN, NotationInfix, "1 - (2 - 3)"},
+		{"1 2 - 3 -", NotationRPN, NotationInfix, "1 - 2 - 3"},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.expr, tt.from, tt.to)
+		if err != nil || got != tt.want {
+			t.Errorf("Convert(%s, %s, %s) = %q, %v; want %q", tt.expr, tt.from, tt.to, got, err, tt.want)
+		}
+	}
+
+	if _, err := Convert("1", NotationInfix, "roman"); !errors.Is(err, ErrUnknownNotation) {
+		t.Errorf("unknown notation: error = %v, want ErrUnknownNotation", err)
+	}
+}
+
+// TestConvertRoundTrip checks that an expression converted to every
+// notation evaluates to the same value.
+func TestConvertRoundTrip(t *testing.T) {
+	for _, expr := range []string{"-3^2", "2^-1", "(1 - 2) - 3 * 4 / 5", "max(1, 7, 3) + 2!", "a = 2; -a^2 + sqrt(16)"} {
+		want, err := NewEvaluator().Evaluate(context.Background(), expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		for _, to := range []Notation{NotationRPN, NotationPrefix} {
+			converted, err := Convert(expr, NotationInfix, to)
+			if err != nil {
+				t.Errorf("%s to %s: %v", expr, to, err)
+				continue
+			}
+			back, err := Convert(converted, to, NotationInfix)
+			if err != nil {
+				t.Errorf("%s back from %s: %v", converted, to, err)
+				continue
+			}
+			for _, c := range []struct {
+				expr     string
+				notation Notation
+			}{{converted, to}, {back, NotationInfix}} {
+				got, err := NewEvaluator().EvaluateWithOptions(context.Background(), c.expr, Options{Notation: c.notation})
+				if err != nil {
+					t.Errorf("%s (%s, from %s): %v", c.expr, c.notation, expr, err)
+				} else if got.Value.String() != want.Value.String() {
+					t.Errorf("%s (%s, from %s) = %v, want %v", c.expr, c.notation, expr, got.Value, want.Value)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTooLong           = errors.New("expression is too long")
+	ErrTooManyStatements = errors.New("too many statements")
+)
+
+// Option configures an Evaluator, see NewEvaluator.
+type Option func(*Evaluator)
+
+// Precision is how integer results beyond the exact range of float64 are
+// returned.
+type Precision int
+
+const (
+	// PrecisionExact returns them as an Integer with every digit. It is
+	// the default.
+	PrecisionExact Precision = iota
+	// PrecisionFloat rounds them to the nearest Number, so every real
+	// result is a float64.
+	PrecisionFloat
+)
+
+// Limits bound what a single evaluation may use. Zero fields are not
+// limited.
+type Limits struct {
+	// MaxLength is the longest script accepted, in bytes.
+	MaxLength int
+	// MaxStatements is the most statements a script may have.
+	MaxStatements int
+	// Timeout bounds the evaluation on top of the deadline of its context.
+	Timeout time.Duration
+}
+
+// WithDialect sets the dialect expressions are read in when Options names
+// none. An unknown name fails every such evaluation with
+// ErrUnknownDialect.
+func WithDialect(name string) Option {
+	return func(e *Evaluator) { e.dialect = name }
+}
+
+// WithPrecision sets how large integer results are returned.
+func WithPrecision(p Precision) Option {
+	return func(e *Evaluator) { e.precision = p }
+}
+
+// WithLimits bounds every evaluation.
+func WithLimits(l Limits) Option {
+	return func(e *Evaluator) { e.limits = l }
+}
+
+// WithFunction registers a Go function callable from expressions. It
+// replaces a built-in of the same name.
+func WithFunction(name string, fn Function) Option {
+	return func(e *Evaluator) { e.functions[name] = fn }
+}
+
+// WithDefinitions makes user functions such as "f(x) = x^2 + 1" callable
+// from every expression, next to the ones passed in Options.Functions.
+func WithDefinitions(defs ...FunctionDef) Option {
+	return func(e *Evaluator) { e.definitions = append(e.definitions, defs...) }
+}
+
+// withDefaults fills the options of one evaluation from the evaluator's.
+func (e *Evaluator) withDefaults(opts Options) Options {
+	if opts.Dialect == "" {
+		opts.Dialect = e.dialect
+	}
+	if len(e.definitions) > 0 {
+		opts.Functions = append(append([]FunctionDef{}, e.definitions...), opts.Functions...)
+	}
+	return opts
+}
+
+// bound checks a script against the limits and applies the timeout.
+func (l Limits) bound(ctx context.Context, expr string, statements int) (context.Context, context.CancelFunc, error) {
+	if l.MaxLength > 0 && len(expr) > l.MaxLength {
+		return nil, nil, fmt.Errorf("%w: %d bytes, at most %d", ErrTooLong, len(expr), l.MaxLength)
+	}
+	if l.MaxStatements > 0 && statements > l.MaxStatements {
+		return nil, nil, fmt.Errorf("%w: %d, at most %d", ErrTooManyStatements, statements, l.MaxStatements)
+	}
+	if l.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// round converts exact integers to numbers under PrecisionFloat.
+func (p Precision) round(v Value) Value {
+	if i, ok := v.(Integer); ok && p == PrecisionFloat {
+		return Number(i.Float())
+	}
+	return v
+}
@@ -0,0 +1,103 @@
+package calculator
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"regexp"
+)
+
+var ErrInvalidRange = errors.New("invalid range")
+
+// maxDice bounds the number of dice rolled at once.
+const maxDice = 1000000
+
+// randomFunctions are the built-ins that draw random numbers. They draw
+// from r, which every evaluation seeds from Options.Seed, so the same
+// expression and seed always give the same values.
+func randomFunctions(r *rand.Rand) map[string]Function {
+	return map[string]Function{
+		"rand": {MinArgs: 0, MaxArgs: 0, Call: func(args []Value) (Value, error) {
+			return Number(r.Float64()), nil
+		}},
+		"randint": {MinArgs: 2, MaxArgs: 2, Call: func(args []Value) (Value, error) {
+			lo, hi, err := integerPair(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			if lo > hi {
+				return nil, ErrInvalidRange
+			}
+			return Number(lo + r.Int63n(hi-lo+1)), nil
+		}},
+		"normal": {MinArgs: 2, MaxArgs: 2, Call: func(args []Value) (Value, error) {
+			mu, sigma := toFloat(args[0]), toFloat(args[1])
+			if math.IsNaN(mu) || math.IsNaN(sigma) {
+				return nil, ErrTypeMismatch
+			}
+			if sigma < 0 {
+				return nil, ErrDomain
+			}
+			return Number(mu + sigma*r.NormFloat64()), nil
+		}},
+		"dice": {MinArgs: 2, MaxArgs: 2, Call: func(args []Value) (Value, error) {
+			n, sides, err := integerPair(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || n > maxDice || sides < 1 {
+				return nil, ErrInvalidRange
+			}
+			var total int64
+			for i := int64(0); i < n; i++ {
+				total += 1 + r.Int63n(sides)
+			}
+			return Number(total), nil
+		}},
+	}
+}
+
+// integerPair reads two integer arguments.
+func integerPair(a, b Value) (int64, int64, error) {
+	x, y := toFloat(a), toFloat(b)
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return 0, 0, ErrTypeMismatch
+	}
+	if x != math.Trunc(x) || y != math.Trunc(y) {
+		return 0, 0, ErrNotInteger
+	}
+	if math.Abs(x) > maxExactFloat || math.Abs(y) > maxExactFloat {
+		return 0, 0, ErrTooLarge
+	}
+	return int64(x), int64(y), nil
+}
+
+// diceLiteral is dice notation, 3d6 for the sum of three six-sided dice.
+var diceLiteral = regexp.MustCompile(`^(\d+)d(\d+)`)
+
+// diceTokens returns the tokens of the call dice(n, sides) for the dice
+// notation s starts with, and its length.
+func diceTokens(s []rune) ([]token, int) {
+	m := diceLiteral.FindStringSubmatch(string(s))
+	if m == nil {
+		return nil, 0
+	}
+	n := len([]rune(m[0]))
+	if n < len(s) && (isLetter(s[n]) || isDigit(s[n]) || s[n] == '.') {
+		return nil, 0
+	}
+	return []token{
+		{kind: tokenIdent, text: "dice"},
+		{kind: tokenLeftParen, text: "("},
+		{kind: tokenNumber, text: m[1]},
+		{kind: tokenComma, text: ","},
+		{kind: tokenNumber, text: m[2]},
+		{kind: tokenRightParen, text: ")"},
+	}, n
+}
+
+// isDice reports whether s starts with dice notation.
+func isDice(s []rune) bool {
+	_, n := diceTokens(s)
+	return n > 0
+}
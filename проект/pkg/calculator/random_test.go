@@ -0,0 +1,70 @@
+package calculator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRandomRanges(t *testing.T) {
+	e := NewEvaluator()
+	tests := []struct {
+		expr    string
+		lo, hi  float64
+		integer bool
+	}{
+		{"rand()", 0, 1 - 1e-16, false},
+		{"randint(1, 6)", 1, 6, true},
+		{"randint(-3, -3)", -3, -3, true},
+		{"3d6", 3, 18, true},
+		{"dice(2, 20)", 2, 40, true},
+		{"0d6", 0, 0, true},
+		{"normal(5, 0)", 5, 5, false},
+	}
+	for _, tt := range tests {
+		for seed := int64(0); seed < 50; seed++ {
+			res, err := e.EvaluateWithOptions(context.Background(), tt.expr, Options{Seed: seed})
+			if err != nil {
+				t.Fatalf("%s: %v", tt.expr, err)
+			}
+			v, ok := res.Value.(Number)
+			if !ok || float64(v) < tt.lo || float64(v) > tt.hi || tt.integer && !isIntegral(v) {
+				t.Errorf("%s with seed %d = %v, want a value in [%g, %g]", tt.expr, seed, res.Value, tt.lo, tt.hi)
+			}
+		}
+	}
+}
+
+func TestRandomSeed(t *testing.T) {
+	const expr = "[rand(), randint(1, 1000000), normal(0, 1), 10d100]"
+	eval := func(seed int64) string {
+		t.Helper()
+		res, err := NewEvaluator().EvaluateWithOptions(context.Background(), expr, Options{Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Value.String()
+	}
+	if a, b := eval(42), eval(42); a != b {
+		t.Errorf("seed 42 gave %s and %s", a, b)
+	}
+	if a, b := eval(42), eval(43); a == b {
+		t.Errorf("seeds 42 and 43 both gave %s", a)
+	}
+
+	// Every call draws anew within one evaluation.
+	if got := evalNumber(t, "a = rand(); b = rand(); a - b"); got == 0 {
+		t.Errorf("two calls of rand() drew the same number")
+	}
+}
+
+func TestRandomErrors(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "randint(6, 1)", err: ErrInvalidRange},
+		{expr: "randint(1.5, 2)", err: ErrNotInteger},
+		{expr: "dice(-1, 6)", err: ErrInvalidRange},
+		{expr: "dice(1, 0)", err: ErrInvalidRange},
+		{expr: "dice(2000000, 6)", err: ErrInvalidRange},
+		{expr: "normal(0, -1)", err: ErrDomain},
+		{expr: "rand(1)", err: ErrArgumentCount},
+	})
+}
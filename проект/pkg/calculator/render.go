@@ -0,0 +1,373 @@
+package calculator
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("unknown render format")
+
+// Format is a markup language expressions can be typeset in.
+type Format string
+
+const (
+	FormatLaTeX  Format = "latex"
+	FormatMathML Format = "mathml"
+)
+
+// Render typesets an expression or script. Parentheses are placed from the
+// parsed structure, so only those needed to read the expression back the
+// way it is evaluated are kept: (2 + 3) * 4 keeps them, 2 + (3 * 4) does
+// not, and neither does -(x^2), which is read as -x^2.
+func Render(expr string, format Format) (string, error) {
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	e := NewEvaluator()
+	if err := e.Validate(expr); err != nil {
+		return "", err
+	}
+	statements := splitStatements(expr)
+	if len(statements) == 0 {
+		return "", ErrEmptyExpression
+	}
+
+	lines := make([]string, len(statements))
+	bound := map[string]bool{}
+	for i, stmt := range statements {
+		name, body, err := parseAssignment(stmt)
+		if err != nil {
+			return "", err
+		}
+		lines[i], err = e.render(body, m, bound)
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			lines[i] = m.assignment(m.identifier(name), lines[i])
+			bound[name] = true
+		}
+	}
+	return m.document(lines), nil
+}
+
+// RenderFunction typesets a user-defined function as "f(x, y) = body".
+func RenderFunction(def FunctionDef, format Format) (string, error) {
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	bound := map[string]bool{}
+	params := make([]string, len(def.Params))
+	for i, p := range def.Params {
+		params[i] = m.identifier(p)
+		bound[p] = true
+	}
+	body, err := NewEvaluator().render(def.Body, m, bound)
+	if err != nil {
+		return "", err
+	}
+	return m.document([]string{m.assignment(m.call(def.Name, params), body)}), nil
+}
+
+func markupFor(format Format) (markup, error) {
+	switch format {
+	case FormatLaTeX:
+		return latex{}, nil
+	case FormatMathML:
+		return mathml{}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// render typesets one expression. Names in bound are variables, which
+// matters for i: unbound, it is the imaginary unit.
+func (e *Evaluator) render(expr string, m markup, bound map[string]bool) (string, error) {
+	postfix, err := e.compile(expr, Standard)
+	if err != nil {
+		return "", err
+	}
+	root, err := parseTree(postfix)
+	if err != nil {
+		return "", err
+	}
+	return typesetter{m, bound}.typeset(root).text, nil
+}
+
+// node is an expression tree built back from postfix form. A closure node
+// has the closure's expression as its only argument.
+type node struct {
+	t    token
+	args []*node
+}
+
+func parseTree(postfix []token) (*node, error) {
+	var stack []*node
+	for _, t := range postfix {
+		n := &node{t: t}
+		arity := 0
+		switch t.kind {
+		case tokenList, tokenInterval, tokenCall:
+			arity = t.argc
+		case tokenPostfix:
+			arity = 1
+		case tokenOperator:
+			arity = 2
+			if t.text == negation {
+				arity = 1
+			}
+		case tokenClosure:
+			body, err := parseTree(t.body)
+			if err != nil {
+				return nil, err
+			}
+			n.args = []*node{body}
+		}
+
+		if len(stack) < arity {
+			return nil, ErrInvalidExpression
+		}
+		n.args = append(n.args, stack[len(stack)-arity:]...)
+		stack = append(stack[:len(stack)-arity], n)
+	}
+
+	if len(stack) != 1 {
+		return nil, ErrInvalidExpression
+	}
+	return stack[0], nil
+}
+
+// Precedence levels of typeset expressions, loosest first. They follow the
+// usual reading of formulas: a negation is read looser than a power.
+const (
+	precPlusMinus = iota
+	precSum
+	precNegation
+	precProduct
+	precPower
+	precAtom
+)
+
+// operatorRule gives the precedence of a binary operator and the lowest
+// precedences its operands may have without parentheses.
+type operatorRule struct {
+	prec        int
+	left, right int
+}
+
+var operatorRules = map[string]operatorRule{
+	plusMinus: {prec: precPlusMinus, left: precSum, right: precSum},
+	"+":       {prec: precSum, left: precSum, right: precProduct},
+	"-":       {prec: precSum, left: precSum, right: precProduct},
+	"*":       {prec: precProduct, left: precNegation, right: precPower},
+	// A fraction groups its operands itself, so does an exponent.
+	"/": {prec: precPower, left: precPlusMinus, right: precPlusMinus},
+	"^": {prec: precPower, left: precAtom, right: precPlusMinus},
+}
+
+// piece is a typeset subexpression. An open piece ends in a big operator
+// such as a sum, whose body would swallow anything written after it.
+type piece struct {
+	text string
+	prec int
+	open bool
+}
+
+// markup writes the constructs of an expression in one format. Every
+// method gets its operands already typeset and parenthesized.
+type markup interface {
+	number(text string) string
+	identifier(name string) string
+	// imaginary writes the imaginary unit, set apart from a variable i.
+	imaginary() string
+	binary(op, a, b string) string
+	negate(a string) string
+	factorial(a string) string
+	group(a string) string
+	call(name string, args []string) string
+	list(items []string) string
+	matrix(rows [][]string) string
+	interval(lo, hi string) string
+	series(name, variable, from, to, body string) string
+	integral(variable, from, to, body string) string
+	// limit writes a limit from above for side 1, from below for -1 and
+	// from both sides for 0.
+	limit(variable, point string, side int, body string) string
+	assignment(name, value string) string
+	document(statements []string) string
+}
+
+// typesetter decides on parentheses, leaving the notation to the markup.
+// bound holds the variables in scope.
+type typesetter struct {
+	m     markup
+	bound map[string]bool
+}
+
+func (ts typesetter) typeset(n *node) piece {
+	t := n.t
+	switch t.kind {
+	case tokenNumber:
+		prec := precAtom
+		switch {
+		case strings.HasPrefix(t.text, "-"):
+			prec = precNegation
+		case strings.HasSuffix(t.text, string(imaginaryUnit)):
+			prec = precProduct
+		}
+		return piece{text: ts.m.number(t.text), prec: prec}
+	case tokenIdent:
+		if t.text == string(imaginaryUnit) && !ts.bound[t.text] {
+			return piece{text: ts.m.imaginary(), prec: precAtom}
+		}
+		return piece{text: ts.m.identifier(t.text), prec: precAtom}
+	case tokenList:
+		if rows, ok := ts.matrixRows(n); ok {
+			return piece{text: ts.m.matrix(rows), prec: precAtom}
+		}
+		return piece{text: ts.m.list(ts.each(n.args)), prec: precAtom}
+	case tokenInterval:
+		args := ts.each(n.args)
+		return piece{text: ts.m.interval(args[0], args[1]), prec: precAtom}
+	case tokenPostfix:
+		return piece{text: ts.m.factorial(ts.operand(n.args[0], precAtom).text), prec: precPower}
+	case tokenOperator:
+		if t.text == negation {
+			a := ts.operand(n.args[0], precProduct)
+			return piece{text: ts.m.negate(a.text), prec: precNegation, open: a.open}
+		}
+		rule := operatorRules[t.text]
+		a := ts.operand(n.args[0], rule.left)
+		b := ts.operand(n.args[1], rule.right)
+		if t.text == "/" || t.text == "^" {
+			return piece{text: ts.m.binary(t.text, a.text, b.text), prec: rule.prec}
+		}
+		if a.open {
+			a = ts.wrap(a)
+		}
+		return piece{text: ts.m.binary(t.text, a.text, b.text), prec: rule.prec, open: b.open}
+	case tokenCall:
+		return ts.call(n)
+	case tokenClosure:
+		return ts.within(t.text).typeset(n.args[0])
+	}
+	return piece{prec: precAtom}
+}
+
+// within returns the typesetter of a closure body, where the closure's
+// variable is bound.
+func (ts typesetter) within(variable string) typesetter {
+	bound := map[string]bool{variable: true}
+	for name := range ts.bound {
+		bound[name] = true
+	}
+	return typesetter{ts.m, bound}
+}
+
+// operand typesets n, in parentheses if it binds looser than min.
+func (ts typesetter) operand(n *node, min int) piece {
+	p := ts.typeset(n)
+	if p.prec < min {
+		return ts.wrap(p)
+	}
+	return p
+}
+
+func (ts typesetter) wrap(p piece) piece {
+	return piece{text: ts.m.group(p.text), prec: precAtom}
+}
+
+func (ts typesetter) each(nodes []*node) []string {
+	texts := make([]string, len(nodes))
+	for i, n := range nodes {
+		texts[i] = ts.typeset(n).text
+	}
+	return texts
+}
+
+// matrixRows returns the elements of a list literal of equally long list
+// literals, which is written as a matrix.
+func (ts typesetter) matrixRows(n *node) ([][]string, bool) {
+	if len(n.args) == 0 {
+		return nil, false
+	}
+	rows := make([][]string, len(n.args))
+	for i, row := range n.args {
+		if row.t.kind != tokenList || len(row.args) == 0 || len(row.args) != len(n.args[0].args) {
+			return nil, false
+		}
+		rows[i] = ts.each(row.args)
+	}
+	return rows, true
+}
+
+func (ts typesetter) call(n *node) piece {
+	name, args := n.t.text, n.args
+	closure := len(args) > 0 && args[len(args)-1].t.kind == tokenClosure
+	if !closure {
+		return piece{text: ts.m.call(name, ts.each(args)), prec: precAtom}
+	}
+
+	c := args[len(args)-1]
+	body := func(min int) string { return ts.within(c.t.text).operand(c.args[0], min).text }
+	variable := ts.m.identifier(c.t.text)
+	bounds := ts.each(args[:len(args)-1])
+
+	switch name {
+	case "sum", "prod":
+		return piece{
+			text: ts.m.series(name, variable, bounds[0], bounds[1], body(precProduct)),
+			prec: precPower,
+			open: true,
+		}
+	case "integrate":
+		return piece{text: ts.m.integral(variable, bounds[0], bounds[1], body(precSum)), prec: precPower}
+	case "limit":
+		side, ok := 0, true
+		if len(bounds) == 2 {
+			side, ok = limitSide(args[1])
+		}
+		if ok {
+			return piece{text: ts.m.limit(variable, bounds[0], side, body(precProduct)), prec: precPower, open: true}
+		}
+	}
+	return piece{text: ts.m.call(name, ts.each(seriesArguments(seriesForms[name], args))), prec: precAtom}
+}
+
+// limitSide reads the direction argument of limit when it is written as
+// a literal 1 or -1.
+func limitSide(n *node) (int, bool) {
+	if n.t.kind != tokenNumber {
+		return 0, false
+	}
+	switch n.t.text {
+	case "1":
+		return 1, true
+	case "-1":
+		return -1, true
+	}
+	return 0, false
+}
+
+// seriesArguments restores the arguments of a series form as written, with
+// the variable and the expression back in their places.
+func seriesArguments(form seriesForm, args []*node) []*node {
+	c := args[len(args)-1]
+	rest := args[:len(args)-1]
+	written := make([]*node, 0, len(args)+1)
+	for i := 0; i < len(args)+1; i++ {
+		switch i {
+		case form.variable:
+			written = append(written, &node{t: token{kind: tokenIdent, text: c.t.text}})
+		case form.body:
+			written = append(written, c.args[0])
+		default:
+			written = append(written, rest[0])
+			rest = rest[1:]
+		}
+	}
+	return written
+}
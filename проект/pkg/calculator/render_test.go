@@ -0,0 +1,139 @@
+package calculator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderLaTeX(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`1 + 2 * 3`, `1 + 2 \cdot 3`},
+		{`(1 + 2) * 3`, `\left(1 + 2\right) \cdot 3`},
+		{`1 - (2 - 3)`, `1 - \left(2 - 3\right)`},
+		{`a / b`, `\frac{a}{b}`},
+		{`x^2`, `x^{2}`},
+		{`-x^2`, `-x^{2}`},
+		{`(-x)^2`, `\left(-x\right)^{2}`},
+		{`2^-1`, `2^{-1}`},
+		{`(2 ^ 3) ^ 2`, `\left(2^{3}\right)^{2}`},
+		{`sqrt(x)`, `\sqrt{x}`},
+		{`abs(x)`, `\left|x\right|`},
+		{`sin(x) + max(1, 2)`, `\sin\left(x\right) + \max\left(1, 2\right)`},
+		{`5!`, `5!`},
+		{`[1, 2]`, `\left[1, 2\right]`},
+		{`[[1, 2], [3, 4]]`, `\begin{pmatrix}1 & 2 \\ 3 & 4\end{pmatrix}`},
+		{`9.81 ± 0.02`, `9.81 \pm 0.02`},
+		{`a = 2; a^2`, `a = 2;\quad a^{2}`},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.expr, FormatLaTeX)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Render(%s) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMathML(t *testing.T) {
+	const (
+		header = `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`
+		footer = `</math>`
+	)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`a / b`, `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+		{`x^2`, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{`-x^2`, `<mrow><mo>−</mo><msup><mi>x</mi><mn>2</mn></msup></mrow>`},
+		{`sqrt(x)`, `<msqrt><mi>x</mi></msqrt>`},
+		{`abs(x)`, `<mrow><mo>|</mo><mi>x</mi><mo>|</mo></mrow>`},
+		{`1 + 2 * 3`, `<mrow><mn>1</mn><mo>+</mo><mrow><mn>2</mn><mo>⋅</mo><mn>3</mn></mrow></mrow>`},
+		{`5!`, `<mrow><mn>5</mn><mo>!</mo></mrow>`},
+		{`9.81 ± 0.02`, `<mrow><mn>9.81</mn><mo>±</mo><mn>0.02</mn></mrow>`},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.expr, FormatMathML)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if !strings.HasPrefix(got, header) || !strings.HasSuffix(got, footer) {
+			t.Errorf("Render(%s) = %s, not a MathML document", tt.expr, got)
+			continue
+		}
+		if body := strings.TrimSuffix(strings.TrimPrefix(got, header), footer); body != tt.want {
+			t.Errorf("Render(%s) = %s, want %s", tt.expr, body, tt.want)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := Render("1", "svg"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format: error = %v, want ErrUnknownFormat", err)
+	}
+	for _, format := range []Format{FormatLaTeX, FormatMathML} {
+		if _, err := Render("1 +", format); !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("%s of an invalid expression: error = %v, want ErrInvalidExpression", format, err)
+		}
+	}
+}
+
+func TestRenderFunction(t *testing.T) {
+	def, err := ParseFunction("f(x, y) = x^2 + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderFunction(def, FormatLaTeX)
+	if want := `f\left(x, y\right) = x^{2} + y`; err != nil || got != want {
+		t.Errorf("RenderFunction = %s, %v; want %s", got, err, want)
+	}
+}
+
+// TestRenderImaginaryUnit checks that i is typeset as the imaginary unit
+// unless it is a variable.
+func TestRenderImaginaryUnit(t *testing.T) {
+	tests := []struct {
+		expr   string
+		format Format
+		want   string
+	}{
+		{`(1+2i)*(3-i)`, FormatLaTeX, `\left(1 + 2\mathrm{i}\right) \cdot \left(3 - \mathrm{i}\right)`},
+		{`arg(i)`, FormatLaTeX, `\arg\left(\mathrm{i}\right)`},
+		{`i = 2; i^2`, FormatLaTeX, `i = 2;\quad i^{2}`},
+		{`sum(i, 1, 10, i^2) + i`, FormatLaTeX, `\left(\sum_{i=1}^{10} i^{2}\right) + \mathrm{i}`},
+		{`exp(i*pi)`, FormatMathML, `<mi mathvariant="normal">i</mi><mo>⋅</mo><mi>pi</mi>`},
+		{`2i`, FormatMathML, `<mn>2</mn><mi mathvariant="normal">i</mi>`},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.expr, tt.format)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		// MathML is checked without the document around the expression.
+		match := got == tt.want
+		if tt.format == FormatMathML {
+			match = strings.Contains(got, tt.want)
+		}
+		if !match {
+			t.Errorf("Render(%s, %s) = %s, want %s", tt.expr, tt.format, got, tt.want)
+		}
+	}
+
+	def, err := ParseFunction("f(i) = i + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderFunction(def, FormatLaTeX)
+	if want := `f\left(i\right) = i + 1`; err != nil || got != want {
+		t.Errorf("RenderFunction = %s, %v; want %s", got, err, want)
+	}
+}
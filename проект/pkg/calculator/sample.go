@@ -0,0 +1,128 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Sample evaluates a single expression at every point of xs, with the
+// point bound to variable. The expression is compiled once. Points where it
+// has no real value, such as x = 0 in sin(x)/x, come back as NaN; errors
+// that hold for every point, like an unknown identifier, fail the whole
+// call.
+func (e *Evaluator) Sample(ctx context.Context, expr, variable string, xs []float64, opts Options) ([]float64, error) {
+	opts = e.withDefaults(opts)
+	dialect, err := LookupDialect(opts.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Notation == "" || opts.Notation == NotationInfix {
+		if err := dialect.validate(expr); err != nil {
+			return nil, err
+		}
+	}
+	if !isIdentifier(variable) {
+		return nil, ErrInvalidAssignment
+	}
+	ctx, cancel, err := e.limits.bound(ctx, expr, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	postfix, err := e.parse(expr, opts.Notation, dialect)
+	if err != nil {
+		return nil, err
+	}
+	functions, err := e.compileFunctions(opts.Functions)
+	if err != nil {
+		return nil, err
+	}
+	env, err := newEnvironment(ctx, functions, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ys := make([]float64, len(xs))
+	for i, x := range xs {
+		select {
+		case <-ctx.Done():
+			return nil, ErrTimeout
+		default:
+		}
+
+		env.bindings[variable] = Number(x)
+		value, err := e.evaluatePostfix(postfix, env)
+		if err != nil {
+			if !undefinedAt(err) {
+				return nil, err
+			}
+			ys[i] = math.NaN()
+			continue
+		}
+
+		switch v := value.(type) {
+		case Number:
+			ys[i] = float64(v)
+		case Integer:
+			ys[i] = v.Float()
+		case Uncertain:
+			ys[i] = v.Value
+		case Complex:
+			ys[i] = math.NaN()
+		default:
+			return nil, ErrTypeMismatch
+		}
+	}
+	return ys, nil
+}
+
+// undefinedAt reports whether an evaluation error only means the
+// expression has no value at that particular point.
+func undefinedAt(err error) bool {
+	return errors.Is(err, ErrDivisionByZero) ||
+		errors.Is(err, ErrDomain) ||
+		errors.Is(err, ErrNotInteger) ||
+		errors.Is(err, ErrNegative) ||
+		errors.Is(err, ErrTooLarge)
+}
+
+// Discontinuities returns the indices i for which the sampled curve jumps
+// between xs[i] and xs[i+1], so a plot should not connect the two points.
+// A step counts as a jump when it is larger than both neighbouring steps
+// and goes against both of them, as at the pole of tan(x), or when it
+// dwarfs its neighbours, as at a step function. Undefined points are not reported,
+// they already break the curve.
+func Discontinuities(ys []float64) []int {
+	var jumps []int
+	for i := 0; i+1 < len(ys); i++ {
+		step := ys[i+1] - ys[i]
+		if math.IsNaN(step) || step == 0 {
+			continue
+		}
+
+		var neighbours []float64
+		if i > 0 && !math.IsNaN(ys[i-1]) {
+			neighbours = append(neighbours, ys[i]-ys[i-1])
+		}
+		if i+2 < len(ys) && !math.IsNaN(ys[i+2]) {
+			neighbours = append(neighbours, ys[i+2]-ys[i+1])
+		}
+
+		against, larger, dwarfs := true, true, true
+		for _, n := range neighbours {
+			against = against && n*step < 0
+			larger = larger && math.Abs(step) > math.Abs(n)
+			dwarfs = dwarfs && math.Abs(step) > jumpRatio*math.Abs(n)
+		}
+		if len(neighbours) == 2 && larger && against || len(neighbours) > 0 && dwarfs {
+			jumps = append(jumps, i)
+		}
+	}
+	return jumps
+}
+
+// jumpRatio is how many times larger than its neighbours a step in the same
+// direction has to be to count as a jump.
+const jumpRatio = 20
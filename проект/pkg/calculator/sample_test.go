@@ -0,0 +1,84 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	xs := []float64{-1, 0, 1, 4}
+	tests := []struct {
+		expr string
+		opts Options
+		want []float64
+		err  error
+	}{
+		{expr: "x^2 + 1", want: []float64{2, 1, 2, 17}},
+		{expr: "sin(x) / x", want: []float64{math.Sin(-1) / -1, math.NaN(), math.Sin(1), math.Sin(4) / 4}},
+		{expr: "sqrt(x)", want: []float64{math.NaN(), 0, 1, 2}},
+		{expr: "sqrt(x)", opts: Options{Complex: true}, want: []float64{math.NaN(), 0, 1, 2}},
+		{expr: "x ** 2", opts: Options{Dialect: "python"}, want: []float64{1, 0, 1, 16}},
+		{expr: "x + y", err: ErrUnknownIdentifier},
+		{expr: "[x, x]", err: ErrTypeMismatch},
+	}
+	for _, tt := range tests {
+		ys, err := NewEvaluator().Sample(context.Background(), tt.expr, "x", xs, tt.opts)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: error = %v, want %v", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		for i := range xs {
+			if math.IsNaN(tt.want[i]) != math.IsNaN(ys[i]) || !math.IsNaN(ys[i]) && !closeTo(ys[i], tt.want[i], 1e-12) {
+				t.Errorf("%s at x = %g is %g, want %g", tt.expr, xs[i], ys[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestSampleOptions(t *testing.T) {
+	sample := func(expr string, opts Options) []float64 {
+		t.Helper()
+		ys, err := NewEvaluator().Sample(context.Background(), expr, "x", []float64{1, 2, 3}, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		return ys
+	}
+
+	a, b := sample("rand() + x", Options{Seed: 7}), sample("rand() + x", Options{Seed: 7})
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed sampled %v and %v", a, b)
+	}
+	if c := sample("rand() + x", Options{Seed: 8}); reflect.DeepEqual(a, c) {
+		t.Errorf("seeds 7 and 8 both sampled %v", a)
+	}
+
+	if _, err := NewEvaluator().Sample(context.Background(), "x", "x", []float64{1}, Options{Timezone: "Mars/Olympus"}); !errors.Is(err, ErrUnknownTimezone) {
+		t.Errorf("unknown timezone: error = %v, want ErrUnknownTimezone", err)
+	}
+}
+
+func TestDiscontinuities(t *testing.T) {
+	tests := []struct {
+		ys   []float64
+		want []int
+	}{
+		{[]float64{0, 1, 2, 3, 4}, nil},
+		{[]float64{1, 10, 100, -100, -10, -1}, []int{2}},
+		{[]float64{0, 0, 1, 1}, []int{1}},
+	}
+	for _, tt := range tests {
+		if got := Discontinuities(tt.ys); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Discontinuities(%v) = %v, want %v", tt.ys, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAssignment = errors.New("invalid assignment")
+
+// Result is the outcome of evaluating a script. A script is a sequence of
+// statements separated by ';', each either an expression or an assignment
+// "name = expression". The value of the last statement is the result.
+type Result struct {
+	Value       Value
+	Assignments []Assignment
+}
+
+// Assignment records a binding made by a script, in evaluation order.
+type Assignment struct {
+	Name  string
+	Value Value
+}
+
+// scope holds the bindings visible to the statement being evaluated.
+type scope map[string]Value
+
+// environment is what a single evaluation can refer to by name.
+type environment struct {
+	bindings  scope
+	functions map[string]userFunction
+	complex   bool
+	// now is the time the evaluation started, in its timezone.
+	now time.Time
+	// ctx bounds the evaluation; long computations such as n! check it.
+	ctx context.Context
+	// local holds the built-ins bound to the evaluation: the random
+	// functions, drawing from its seeded source, and the number theory
+	// ones, which stop when ctx is done.
+	local map[string]Function
+}
+
+func newEnvironment(ctx context.Context, functions map[string]userFunction, opts Options) (*environment, error) {
+	now, err := opts.now()
+	if err != nil {
+		return nil, err
+	}
+	local := randomFunctions(rand.New(rand.NewSource(opts.Seed)))
+	for name, fn := range integerFunctions(ctx) {
+		local[name] = fn
+	}
+	return &environment{
+		bindings:  scope{},
+		functions: functions,
+		complex:   opts.Complex,
+		now:       now,
+		ctx:       ctx,
+		local:     local,
+	}, nil
+}
+
+// Options tune a single evaluation.
+type Options struct {
+	// Functions are the user-defined functions the expression may call.
+	Functions []FunctionDef
+	// Complex lets operations without a real result, such as sqrt(-1)
+	// or (-8)^(1/3), return complex numbers instead of failing.
+	Complex bool
+	// Notation is the notation the expression is written in, infix when
+	// empty. User function bodies are always infix.
+	Notation Notation
+	// Dialect names the infix dialect the expression is written in,
+	// standard when empty. User function bodies are always standard.
+	Dialect string
+	// Timezone is the IANA name of the timezone dates without an offset,
+	// today and calendar arithmetic are in, UTC when empty.
+	Timezone string
+	// Seed seeds rand, randint, normal and dice: the same expression and
+	// seed always give the same values.
+	Seed int64
+}
+
+// now returns the current time in the timezone of the options.
+func (opts Options) now() (time.Time, error) {
+	loc, err := location(opts.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(loc).Truncate(time.Second), nil
+}
+
+func splitStatements(script string) []string {
+	var statements []string
+	for _, stmt := range strings.Split(script, ";") {
+		if strings.TrimSpace(stmt) != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
+
+// parseAssignment splits "name = expression" into its parts. The name is
+// empty when the statement is a plain expression.
+func parseAssignment(stmt string) (string, string, error) {
+	idx := strings.IndexRune(stmt, '=')
+	if idx < 0 {
+		return "", stmt, nil
+	}
+
+	name := strings.TrimSpace(stmt[:idx])
+	if !isIdentifier(name) {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidAssignment, name)
+	}
+	return name, stmt[idx+1:], nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !isLetter(c) && (i == 0 || !isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
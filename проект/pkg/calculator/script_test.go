@@ -0,0 +1,72 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScripts(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "a = 2; b = a * 3; b ^ 2", want: "36"},
+		{expr: "a = 2; a = a + 1; a", want: "3"},
+		{expr: "x = [1, 2, 3]; sum(x * 2)", want: "12"},
+		{expr: "a = 5", want: "5"},
+		{expr: "1; 2; 3", want: "3"},
+		{expr: "1;;2;", want: "2"},
+		{expr: " ; ", err: ErrEmptyExpression},
+		{expr: "a + 1; a = 1", err: ErrUnknownIdentifier},
+		{expr: "2a = 1", err: ErrInvalidAssignment},
+		{expr: "a b = 1", err: ErrInvalidAssignment},
+	})
+}
+
+func TestScriptAssignments(t *testing.T) {
+	res, err := NewEvaluator().Evaluate(context.Background(), "a = 2; b = a * 3; a + b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name  string
+		value string
+	}{{"a", "2"}, {"b", "6"}}
+	if len(res.Assignments) != len(want) {
+		t.Fatalf("assignments = %v, want %d", res.Assignments, len(want))
+	}
+	for i, w := range want {
+		if a := res.Assignments[i]; a.Name != w.name || a.Value.String() != w.value {
+			t.Errorf("assignment %d = %s = %v, want %s = %s", i, a.Name, a.Value, w.name, w.value)
+		}
+	}
+	if res.Value.String() != "8" {
+		t.Errorf("value = %v, want 8", res.Value)
+	}
+}
+
+func TestScriptLimits(t *testing.T) {
+	tests := []struct {
+		limits Limits
+		expr   string
+		err    error
+	}{
+		{Limits{MaxLength: 5}, "1 + 2 + 3", ErrTooLong},
+		{Limits{MaxLength: 5}, "1 + 2", nil},
+		{Limits{MaxStatements: 2}, "a = 1; b = 2; a + b", ErrTooManyStatements},
+		{Limits{MaxStatements: 2}, "a = 1; a + 1", nil},
+		{Limits{Timeout: time.Minute}, "1 + 1", nil},
+	}
+	for _, tt := range tests {
+		e := NewEvaluator(WithLimits(tt.limits))
+		_, err := e.Evaluate(context.Background(), tt.expr)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%+v %s: error = %v, want %v", tt.limits, tt.expr, err, tt.err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewEvaluator().Evaluate(ctx, "1 + 1"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("evaluation with a cancelled context = %v, want ErrTimeout", err)
+	}
+}
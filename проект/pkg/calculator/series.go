@@ -0,0 +1,588 @@
+package calculator
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoLimit        = errors.New("limit does not exist or cannot be approximated")
+	ErrDivergent      = errors.New("integral does not converge")
+	ErrLimitDirection = errors.New("limit direction must be -1 or 1")
+)
+
+const KindFunction Kind = "function"
+
+const (
+	// maxSeriesTerms bounds the range of a single sum or prod. Larger
+	// ranges are split with SplitSeries and evaluated piece by piece.
+	maxSeriesTerms = 1000000
+	// maxSegments bounds the subdivisions of an adaptive integration.
+	maxSegments = 500
+	// integrationTolerance is the relative error integrate aims for.
+	integrationTolerance = 1e-12
+	// divergenceRatio is how much of its error estimate at maxSegments/2
+	// an integral that has not converged at maxSegments may still have
+	// before it is treated as divergent.
+	divergenceRatio = 0.9
+	// limitTolerance is the relative error above which a limit is treated
+	// as divergent.
+	limitTolerance = 1e-6
+)
+
+// closure is an expression argument of sum, prod, integrate or limit. It is
+// evaluated many times with its variable bound to different values and sees
+// the bindings of the script it appears in.
+type closure struct {
+	e        *Evaluator
+	variable string
+	body     []token
+	env      *environment
+}
+
+func (c *closure) Kind() Kind { return KindFunction }
+
+func (c *closure) String() string { return "<expression of " + c.variable + ">" }
+
+func (e *Evaluator) newClosure(t token, env *environment) *closure {
+	bindings := make(scope, len(env.bindings)+1)
+	for name, v := range env.bindings {
+		bindings[name] = v
+	}
+	local := *env
+	local.bindings = bindings
+	return &closure{e: e, variable: t.text, body: t.body, env: &local}
+}
+
+func (c *closure) at(x Value) (Value, error) {
+	c.env.bindings[c.variable] = x
+	return c.e.evaluatePostfix(c.body, c.env)
+}
+
+// real evaluates the closure at x and requires a real result.
+func (c *closure) real(x float64) (float64, error) {
+	v, err := c.at(Number(x))
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case Number:
+		return float64(v), nil
+	case Integer:
+		return v.Float(), nil
+	}
+	return 0, ErrTypeMismatch
+}
+
+// seriesForm describes a function whose arguments include a variable and
+// an expression over it, such as sum(i, 1, 100, i^2). The parser compiles
+// the expression into a closure passed as the last argument; the variable
+// is dropped.
+type seriesForm struct {
+	minArgs, maxArgs int
+	variable, body   int
+	call             func(args []Value, c *closure) (Value, error)
+}
+
+var seriesForms map[string]seriesForm
+
+func init() {
+	seriesForms = map[string]seriesForm{
+		"sum":       {minArgs: 4, maxArgs: 4, variable: 0, body: 3, call: series("+", Number(0))},
+		"prod":      {minArgs: 4, maxArgs: 4, variable: 0, body: 3, call: series("*", Number(1))},
+		"integrate": {minArgs: 4, maxArgs: 4, variable: 1, body: 0, call: integrate},
+		"limit":     {minArgs: 3, maxArgs: 4, variable: 1, body: 0, call: limit},
+	}
+}
+
+// foldSeriesForms replaces the expression argument of series forms with a
+// compiled closure token. sum and prod are only series forms with four
+// arguments, the first being a bare variable; otherwise they stay the
+// aggregates sum(1, 2, 3) and prod(1, 2, 3).
+func (e *Evaluator) foldSeriesForms(tokens []token, d *Dialect) ([]token, error) {
+	var out []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		form, ok := seriesForms[t.text]
+		if t.kind != tokenIdent || !ok || i+1 == len(tokens) || tokens[i+1].kind != tokenLeftParen {
+			out = append(out, t)
+			continue
+		}
+
+		args, end := splitArguments(tokens, i+1)
+		if end < 0 {
+			return nil, ErrInvalidExpression
+		}
+		isVariable := len(args) > form.variable &&
+			len(args[form.variable]) == 1 && args[form.variable][0].kind == tokenIdent
+		if len(args) < form.minArgs || len(args) > form.maxArgs || !isVariable {
+			if form.variable == 0 {
+				out = append(out, t)
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s(expression, variable, ...)", ErrArgumentCount, t.text)
+		}
+
+		body, err := e.foldSeriesForms(args[form.body], d)
+		if err != nil {
+			return nil, err
+		}
+		postfix, err := e.infixToPostfix(body, d)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, t, tokens[i+1])
+		for n, arg := range args {
+			if n == form.variable || n == form.body {
+				continue
+			}
+			folded, err := e.foldSeriesForms(arg, d)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, folded...)
+			out = append(out, token{kind: tokenComma, text: ","})
+		}
+		out = append(out,
+			token{kind: tokenClosure, text: args[form.variable][0].text, body: postfix},
+			token{kind: tokenRightParen, text: ")"},
+		)
+		i = end
+	}
+	return out, nil
+}
+
+// splitArguments splits the tokens of a call, starting at its opening
+// parenthesis, at the top-level commas. It returns the index of the closing
+// parenthesis, or -1 when it is missing.
+func splitArguments(tokens []token, open int) ([][]token, int) {
+	var args [][]token
+	depth := 0
+	start := open + 1
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case tokenLeftParen, tokenLeftBracket:
+			depth++
+		case tokenRightParen, tokenRightBracket:
+			depth--
+			if depth == 0 {
+				if i > start || len(args) > 0 {
+					args = append(args, tokens[start:i])
+				}
+				return args, i
+			}
+		case tokenComma:
+			if depth == 1 {
+				args = append(args, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return nil, -1
+}
+
+// series sums or multiplies the closure over an integer range. Results are
+// combined with the usual operators, so sums of integers stay exact.
+func series(op string, identity Value) func(args []Value, c *closure) (Value, error) {
+	return func(args []Value, c *closure) (Value, error) {
+		from, err := toBigInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := toBigInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if !from.IsInt64() || !to.IsInt64() {
+			return nil, ErrTooLarge
+		}
+
+		a, b := from.Int64(), to.Int64()
+		if b < a {
+			return identity, nil
+		}
+		if b-a >= maxSeriesTerms {
+			return nil, fmt.Errorf("%w: more than %d terms", ErrTooLarge, maxSeriesTerms)
+		}
+
+		acc := identity
+		for i := a; i <= b; i++ {
+			term, err := c.at(Number(i))
+			if err != nil {
+				return nil, err
+			}
+			if acc, err = applyOperator(op, acc, term); err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	}
+}
+
+// SplitSeries splits a top-level sum(i, a, b, body) or prod(i, a, b, body)
+// with literal bounds into consecutive ranges of at most chunk terms. It
+// returns the expressions for the ranges and the operator that combines
+// their results, or false when expr is not such a series or is small
+// enough to evaluate at once.
+func SplitSeries(expr string, chunk int64) ([]string, string, bool) {
+	expr = strings.TrimSpace(expr)
+	open := strings.IndexByte(expr, '(')
+	if open < 0 || !strings.HasSuffix(expr, ")") {
+		return nil, "", false
+	}
+
+	var op string
+	switch strings.TrimSpace(expr[:open]) {
+	case "sum":
+		op = "+"
+	case "prod":
+		op = "*"
+	default:
+		return nil, "", false
+	}
+
+	var args []string
+	depth, start := 0, open+1
+	for i := open; i < len(expr); i++ {
+		switch expr[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+			if depth == 0 && i != len(expr)-1 {
+				return nil, "", false
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, expr[start:i])
+				start = i + 1
+			}
+		case ';', '=':
+			return nil, "", false
+		}
+	}
+	args = append(args, expr[start:len(expr)-1])
+	if len(args) != 4 || !isIdentifier(strings.TrimSpace(args[0])) {
+		return nil, "", false
+	}
+
+	from, err := strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+	if err != nil {
+		return nil, "", false
+	}
+	to, err := strconv.ParseInt(strings.TrimSpace(args[2]), 10, 64)
+	if err != nil || to-from < chunk {
+		return nil, "", false
+	}
+
+	var parts []string
+	for lo := from; lo <= to; lo += chunk {
+		hi := lo + chunk - 1
+		if hi > to {
+			hi = to
+		}
+		parts = append(parts, fmt.Sprintf("%s(%s, %d, %d, %s)",
+			strings.TrimSpace(expr[:open]), strings.TrimSpace(args[0]), lo, hi, strings.TrimSpace(args[3])))
+	}
+	return parts, op, true
+}
+
+// Gauss-Kronrod 7-15 nodes on [-1, 1] and their weights. Only the
+// non-negative nodes are listed; the Gauss weights belong to the odd
+// Kronrod nodes.
+var (
+	kronrodNodes = [8]float64{
+		0.991455371120812639206854697526329,
+		0.949107912342758524526189684047851,
+		0.864864423359769072789712788640926,
+		0.741531185599394439863864773280788,
+		0.586087235467691130294144845693013,
+		0.405845151377397166906606412076961,
+		0.207784955007898467600689403773245,
+		0.000000000000000000000000000000000,
+	}
+	kronrodWeights = [8]float64{
+		0.022935322010529224963732008058970,
+		0.063092092629978553290700663189204,
+		0.104790010322250183839876322541518,
+		0.140653259715525918745189590510238,
+		0.169004726639267902826583426598550,
+		0.190350578064785409913256402421014,
+		0.204432940075298892414161999234649,
+		0.209482141084727828012999174891714,
+	}
+	gaussWeights = [4]float64{
+		0.129484966168869693270611432679082,
+		0.279705391489276667901467771423780,
+		0.381830050505118944950369775488975,
+		0.417959183673469387755102040816327,
+	}
+)
+
+// segment is a part of the integration range with its estimate.
+type segment struct {
+	a, b     float64
+	value    float64
+	estimate float64
+}
+
+// segments is a max-heap of segments by error estimate, so the worst one
+// is subdivided first.
+type segments []segment
+
+func (s segments) Len() int            { return len(s) }
+func (s segments) Less(i, j int) bool  { return s[i].estimate > s[j].estimate }
+func (s segments) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *segments) Push(x interface{}) { *s = append(*s, x.(segment)) }
+func (s *segments) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return x
+}
+
+func gaussKronrod(f func(float64) (float64, error), a, b float64) (segment, error) {
+	center, half := (a+b)/2, (b-a)/2
+
+	fc, err := f(center)
+	if err != nil {
+		return segment{}, err
+	}
+	kronrod := fc * kronrodWeights[7]
+	gauss := fc * gaussWeights[3]
+	var fs [7][2]float64
+	for i := 0; i < 7; i++ {
+		dx := half * kronrodNodes[i]
+		f1, err := f(center - dx)
+		if err != nil {
+			return segment{}, err
+		}
+		f2, err := f(center + dx)
+		if err != nil {
+			return segment{}, err
+		}
+		fs[i] = [2]float64{f1, f2}
+		kronrod += kronrodWeights[i] * (f1 + f2)
+		if i%2 == 1 {
+			gauss += gaussWeights[i/2] * (f1 + f2)
+		}
+	}
+
+	// The difference to the Gauss rule overestimates the error of the
+	// Kronrod rule by orders of magnitude, so it is scaled the way QUADPACK
+	// does, against the variation of f over the segment.
+	estimate := math.Abs((kronrod - gauss) * half)
+	mean := kronrod / 2
+	variation := kronrodWeights[7] * math.Abs(fc-mean)
+	for i := 0; i < 7; i++ {
+		variation += kronrodWeights[i] * (math.Abs(fs[i][0]-mean) + math.Abs(fs[i][1]-mean))
+	}
+	variation *= math.Abs(half)
+	if variation != 0 && estimate != 0 {
+		estimate = variation * math.Min(1, math.Pow(200*estimate/variation, 1.5))
+	}
+
+	return segment{
+		a:        a,
+		b:        b,
+		value:    kronrod * half,
+		estimate: estimate,
+	}, nil
+}
+
+// integrate(f(x), x, a, b) integrates with adaptive Gauss-Kronrod
+// quadrature. The result carries the estimated absolute error as its
+// uncertainty, unless the estimate is below floating point precision. A
+// divergent integral, such as that of 1/x from 0, fails with ErrDivergent.
+func integrate(args []Value, c *closure) (Value, error) {
+	a, b := toFloat(args[0]), toFloat(args[1])
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return nil, ErrTypeMismatch
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return nil, ErrTooLarge
+	}
+	if a == b {
+		return Number(0), nil
+	}
+
+	sign := 1.0
+	if a > b {
+		a, b, sign = b, a, -1
+	}
+
+	first, err := gaussKronrod(c.real, a, b)
+	if err != nil {
+		return nil, err
+	}
+	queue := &segments{first}
+	value, estimate := first.value, first.estimate
+	halfway := math.Inf(1)
+	for queue.Len() < maxSegments && estimate > integrationTolerance*math.Abs(value) {
+		worst := heap.Pop(queue).(segment)
+		mid := (worst.a + worst.b) / 2
+		if mid <= worst.a || mid >= worst.b {
+			heap.Push(queue, worst)
+			break
+		}
+		left, err := gaussKronrod(c.real, worst.a, mid)
+		if err != nil {
+			return nil, err
+		}
+		right, err := gaussKronrod(c.real, mid, worst.b)
+		if err != nil {
+			return nil, err
+		}
+		heap.Push(queue, left)
+		heap.Push(queue, right)
+
+		value, estimate = 0, 0
+		for _, s := range *queue {
+			value += s.value
+			estimate += s.estimate
+		}
+		if queue.Len() == maxSegments/2 {
+			halfway = estimate
+		}
+	}
+
+	if math.IsInf(value, 0) || math.IsNaN(value) || math.IsInf(estimate, 0) {
+		return nil, ErrDivergent
+	}
+	// A convergent integral keeps shrinking its error estimate as its
+	// segments are split, however slowly. One whose estimate stays put or
+	// grows over the second half of the subdivisions, as for 1/x near 0,
+	// diverges.
+	if queue.Len() >= maxSegments && estimate > integrationTolerance*math.Abs(value) &&
+		estimate >= divergenceRatio*halfway {
+		return nil, ErrDivergent
+	}
+
+	if estimate <= 1e-15*math.Abs(value) {
+		return Number(sign * value), nil
+	}
+	return newUncertain(Number(sign*value), Number(estimate))
+}
+
+// limit(f(x), x, a) approximates the limit at a by extrapolating f(a ± h)
+// for shrinking h with Wynn's epsilon algorithm. An optional fourth argument
+// of 1 or -1 takes the limit from above or below only. The result is rounded
+// to the precision of the approximation.
+func limit(args []Value, c *closure) (Value, error) {
+	a := toFloat(args[0])
+	if math.IsNaN(a) {
+		return nil, ErrTypeMismatch
+	}
+
+	sides := []float64{-1, 1}
+	if len(args) == 2 {
+		switch toFloat(args[1]) {
+		case -1:
+			sides = []float64{-1}
+		case 1:
+			sides = []float64{1}
+		default:
+			return nil, ErrLimitDirection
+		}
+	}
+
+	var values, estimates []float64
+	for _, side := range sides {
+		value, estimate, err := oneSidedLimit(c, a, side)
+		if undefinedAt(err) && len(sides) == 2 {
+			// Only one side is in the domain, as for sqrt(x) at 0.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		estimates = append(estimates, estimate)
+	}
+	if len(values) == 0 {
+		return nil, ErrNoLimit
+	}
+
+	value, estimate := values[0], estimates[0]
+	if len(values) == 2 {
+		gap := math.Abs(values[0] - values[1])
+		estimate = math.Max(estimates[0], estimates[1]) + gap/2
+		value = (values[0] + values[1]) / 2
+	}
+	if estimate > limitTolerance*math.Max(1, math.Abs(value)) {
+		return nil, ErrNoLimit
+	}
+
+	if estimate > 0 {
+		scale := math.Pow(10, math.Floor(-math.Log10(estimate))-1)
+		value = math.Round(value*scale) / scale
+	}
+	if value == 0 {
+		value = 0 // drop the sign of -0
+	}
+	return Number(value), nil
+}
+
+// oneSidedLimit extrapolates f(a + side*h) to h = 0 and returns the best
+// estimate with the difference to its neighbour in the epsilon table as
+// its error.
+func oneSidedLimit(c *closure, a, side float64) (float64, float64, error) {
+	const terms = 16
+	h := math.Max(1, math.Abs(a)) / 4
+
+	sequence := make([]float64, terms)
+	for k := range sequence {
+		y, err := c.real(a + side*h)
+		if err != nil {
+			return 0, 0, err
+		}
+		if math.IsInf(y, 0) || math.IsNaN(y) {
+			return 0, 0, ErrNoLimit
+		}
+		sequence[k] = y
+		h /= 2
+	}
+
+	// Extrapolation would happily turn the growth of 1/h into a finite
+	// value, so the samples themselves have to settle down first.
+	if math.Abs(sequence[terms-1]-sequence[terms-2]) > math.Abs(sequence[1]-sequence[0]) {
+		return 0, 0, ErrNoLimit
+	}
+
+	best, bestEstimate := math.NaN(), math.Inf(1)
+	consider := func(column []float64) {
+		for k := 1; k < len(column); k++ {
+			estimate := math.Abs(column[k] - column[k-1])
+			if !math.IsNaN(estimate) && !math.IsInf(column[k], 0) && estimate < bestEstimate {
+				best, bestEstimate = column[k], estimate
+			}
+		}
+	}
+
+	previous, current := make([]float64, terms+1), sequence
+	consider(current)
+	for j := 1; len(current) > 2; j++ {
+		next := make([]float64, len(current)-1)
+		for k := range next {
+			next[k] = previous[k+1] + 1/(current[k+1]-current[k])
+		}
+		// Only the even columns of the table estimate the limit.
+		if j%2 == 0 {
+			consider(next)
+		}
+		previous, current = current, next
+	}
+	// An oscillating sequence can produce two neighbouring estimates that
+	// agree by chance. A genuine limit is no further from the last sample
+	// than the tail of a sequence that converges at least geometrically.
+	last := math.Abs(sequence[terms-1] - sequence[terms-2])
+	if math.Abs(best-sequence[terms-1]) > 4*last+bestEstimate {
+		return 0, 0, ErrNoLimit
+	}
+	return best, bestEstimate, nil
+}
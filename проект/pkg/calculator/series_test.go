@@ -0,0 +1,97 @@
+package calculator
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSeries(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{expr: "sum(i, 1, 100, i^2)", want: "338350"},
+		{expr: "prod(i, 1, 30, i)", want: "265252859812191058636308480000000"},
+		{expr: "sum(i, 1, 0, i)", want: "0"},
+		{expr: "prod(i, 5, 4, i)", want: "1"},
+		{expr: "n = 3; sum(i, 1, n, i * n)", want: "18"},
+		{expr: "sum(1, 2, 3)", want: "6"},
+		{expr: "integrate(x^2, x, 0, 3)", want: "9"},
+		{expr: "limit(sin(x)/x, x, 0)", want: "1"},
+		{expr: "sum(i, 1, 10, x)", err: ErrUnknownIdentifier},
+		{expr: "sum(i, 1.5, 3, i)", err: ErrNotInteger},
+		{expr: "sum(i, 1, 10^9, i)", err: ErrTooLarge},
+		{expr: "limit(1/x, x, 0)", err: ErrNoLimit},
+		{expr: "integrate(1/x, x, 0, 1)", err: ErrDivergent},
+		{expr: "integrate(1/x^2, x, 0, 1)", err: ErrDivergent},
+		{expr: "integrate(1/x, x, -1, 0)", err: ErrDivergent},
+	})
+
+	// Integrable singularities converge, if slowly.
+	for expr, want := range map[string]float64{
+		"integrate(1/sqrt(x), x, 0, 1)": 2,
+		"integrate(ln(x), x, 0, 1)":     -1,
+		"integrate(x^-0.9, x, 0, 1)":    10,
+	} {
+		res, err := NewEvaluator().Evaluate(context.Background(), expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		u, ok := res.Value.(Uncertain)
+		if !ok || math.Abs(u.Value-want) > 3*u.Sigma()+1e-9 {
+			t.Errorf("%s = %v, want %v within its error estimate", expr, res.Value, want)
+		}
+	}
+
+	res, err := NewEvaluator().Evaluate(context.Background(), "integrate(sin(x), x, 0, 3.141592653589793)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, ok := res.Value.(Uncertain)
+	if !ok || !closeTo(u.Value, 2, 1e-12) || u.Sigma() > 1e-10 {
+		t.Errorf("integral of sin over [0, pi] = %v, want 2 with a tiny error estimate", res.Value)
+	}
+}
+
+func TestSplitSeries(t *testing.T) {
+	tests := []struct {
+		expr  string
+		parts []string
+		op    string
+		ok    bool
+	}{
+		{
+			expr:  "sum(i, 1, 1000, i^2)",
+			parts: []string{"sum(i, 1, 300, i^2)", "sum(i, 301, 600, i^2)", "sum(i, 601, 900, i^2)", "sum(i, 901, 1000, i^2)"},
+			op:    "+",
+			ok:    true,
+		},
+		{
+			expr:  "prod(k, 1, 600, 1 + 1/k)",
+			parts: []string{"prod(k, 1, 300, 1 + 1/k)", "prod(k, 301, 600, 1 + 1/k)"},
+			op:    "*",
+			ok:    true,
+		},
+		{expr: "sum(i, 1, 10, i)"},
+		{expr: "sum(i, 1, n, i)"},
+		{expr: "2 * sum(i, 1, 1000, i)"},
+		{expr: "n = 1000; sum(i, 1, n, i)"},
+		{expr: "max(i, 1, 1000, i)"},
+	}
+	for _, tt := range tests {
+		parts, op, ok := SplitSeries(tt.expr, 300)
+		if ok != tt.ok || op != tt.op || !reflect.DeepEqual(parts, tt.parts) {
+			t.Errorf("SplitSeries(%s) = %q, %q, %v; want %q, %q, %v", tt.expr, parts, op, ok, tt.parts, tt.op, tt.ok)
+		}
+	}
+
+	// The parts add up to the whole.
+	parts, _, _ := SplitSeries("sum(i, 1, 1000, i^2)", 300)
+	var total float64
+	for _, p := range parts {
+		total += evalNumber(t, p)
+	}
+	if want := evalNumber(t, "sum(i, 1, 1000, i^2)"); total != want {
+		t.Errorf("parts add up to %g, want %g", total, want)
+	}
+}
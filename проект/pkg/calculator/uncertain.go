@@ -0,0 +1,132 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+var ErrNegativeUncertainty = errors.New("uncertainty must not be negative")
+
+const KindUncertain Kind = "uncertain"
+
+// plusMinus is the operator that attaches an uncertainty to a measured
+// value, 9.81 ± 0.02. It may also be typed as +/-.
+const plusMinus = "±"
+
+// measurements numbers every ± literal evaluated, so that uncertainties of
+// values derived from the same measurement stay correlated.
+var measurements atomic.Uint64
+
+// Uncertain is a measured value with its standard uncertainty. Errors are
+// propagated to first order: each result keeps its sensitivity to every
+// measurement it was derived from, so g - g is exactly 0 while g1 - g2 of
+// two independent measurements adds their uncertainties in quadrature.
+type Uncertain struct {
+	Value float64
+	// terms maps a measurement to its contribution to the uncertainty,
+	// the partial derivative with respect to it times its uncertainty.
+	terms map[uint64]float64
+}
+
+func (u Uncertain) Kind() Kind { return KindUncertain }
+
+func (u Uncertain) String() string {
+	return Number(u.Value).String() + " " + plusMinus + " " + Number(u.Sigma()).String()
+}
+
+// Sigma returns the standard uncertainty of the value.
+func (u Uncertain) Sigma() float64 {
+	var variance float64
+	for _, t := range u.terms {
+		variance += t * t
+	}
+	return math.Sqrt(variance)
+}
+
+func newUncertain(value, sigma Value) (Value, error) {
+	v, okV := value.(Number)
+	s, okS := sigma.(Number)
+	if !okV || !okS {
+		return nil, ErrTypeMismatch
+	}
+	if s < 0 {
+		return nil, ErrNegativeUncertainty
+	}
+	if s == 0 {
+		return v, nil
+	}
+	return Uncertain{
+		Value: float64(v),
+		terms: map[uint64]float64{measurements.Add(1): float64(s)},
+	}, nil
+}
+
+func toUncertain(v Value) (Uncertain, bool) {
+	switch v := v.(type) {
+	case Uncertain:
+		return v, true
+	case Number:
+		return Uncertain{Value: float64(v)}, true
+	case Integer:
+		return Uncertain{Value: v.Float()}, true
+	}
+	return Uncertain{}, false
+}
+
+// propagate builds the result of f(a, b) from its value and the partial
+// derivatives da and db.
+func propagate(value float64, a Uncertain, da float64, b Uncertain, db float64) Value {
+	terms := make(map[uint64]float64, len(a.terms)+len(b.terms))
+	for id, t := range a.terms {
+		terms[id] += da * t
+	}
+	for id, t := range b.terms {
+		terms[id] += db * t
+	}
+	res := Uncertain{Value: value, terms: terms}
+	if res.Sigma() == 0 {
+		return Number(value)
+	}
+	return res
+}
+
+// uncertainOperator applies a binary operator where at least one operand
+// carries an uncertainty.
+func uncertainOperator(op string, a, b Value) (Value, error) {
+	x, ok := toUncertain(a)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	y, ok := toUncertain(b)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+
+	value, err := arithmetic(op, x.Value, y.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "+":
+		return propagate(value, x, 1, y, 1), nil
+	case "-":
+		return propagate(value, x, 1, y, -1), nil
+	case "*":
+		return propagate(value, x, y.Value, y, x.Value), nil
+	case "/":
+		return propagate(value, x, 1/y.Value, y, -x.Value/(y.Value*y.Value)), nil
+	case "^":
+		var db float64
+		if len(y.terms) > 0 {
+			if x.Value <= 0 {
+				return nil, ErrDomain
+			}
+			db = value * math.Log(x.Value)
+		}
+		return propagate(value, x, y.Value*math.Pow(x.Value, y.Value-1), y, db), nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s", op)
+}
@@ -0,0 +1,189 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidFunction   = errors.New("invalid function definition")
+	ErrReservedName      = errors.New("name is reserved by a built-in function")
+	ErrRecursiveFunction = errors.New("recursive function definition")
+)
+
+var definitionPattern = regexp.MustCompile(`^\s*([A-Za-z_]\w*)\s*\(([^()]*)\)\s*=(.+)$`)
+
+// FunctionDef is a user-defined function "name(params) = body". The body is
+// an expression over the parameters and may call built-ins and other user
+// functions, but never itself, directly or through others.
+type FunctionDef struct {
+	Name    string
+	Params  []string
+	Body    string
+	Version int
+}
+
+func (f FunctionDef) String() string {
+	return fmt.Sprintf("%s(%s) = %s", f.Name, strings.Join(f.Params, ", "), f.Body)
+}
+
+// ParseFunction parses a definition such as "f(x, y) = x^2 + y".
+func ParseFunction(definition string) (FunctionDef, error) {
+	m := definitionPattern.FindStringSubmatch(definition)
+	if m == nil {
+		return FunctionDef{}, ErrInvalidFunction
+	}
+
+	def := FunctionDef{
+		Name: m[1],
+		Body: strings.TrimSpace(m[3]),
+	}
+	if strings.TrimSpace(m[2]) != "" {
+		for _, p := range strings.Split(m[2], ",") {
+			def.Params = append(def.Params, strings.TrimSpace(p))
+		}
+	}
+
+	if err := def.validate(); err != nil {
+		return FunctionDef{}, err
+	}
+	return def, nil
+}
+
+func (f FunctionDef) validate() error {
+	if !isIdentifier(f.Name) {
+		return fmt.Errorf("%w: bad name %q", ErrInvalidFunction, f.Name)
+	}
+	if _, ok := seriesForms[f.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrReservedName, f.Name)
+	}
+	if _, ok := builtinFunctions()[f.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrReservedName, f.Name)
+	}
+	if _, ok := randomFunctions(nil)[f.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrReservedName, f.Name)
+	}
+
+	seen := make(map[string]bool, len(f.Params))
+	for _, p := range f.Params {
+		if !isIdentifier(p) || seen[p] {
+			return fmt.Errorf("%w: bad parameter %q", ErrInvalidFunction, p)
+		}
+		seen[p] = true
+	}
+
+	if strings.ContainsAny(f.Body, ";=") {
+		return fmt.Errorf("%w: body must be a single expression", ErrInvalidFunction)
+	}
+	if _, err := NewEvaluator().compile(f.Body, Standard); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidFunction, f.Name, err)
+	}
+	return nil
+}
+
+// CalledFunctions returns the names of the functions an expression or
+// script calls, each listed once in order of first appearance.
+func CalledFunctions(expr string) ([]string, error) {
+	e := NewEvaluator()
+	if err := e.Validate(expr); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, stmt := range splitStatements(expr) {
+		_, body, err := parseAssignment(stmt)
+		if err != nil {
+			return nil, err
+		}
+		tokens, err := e.tokenize(body, Standard)
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range tokens {
+			if t.kind == tokenIdent && i+1 < len(tokens) && tokens[i+1].kind == tokenLeftParen && !seen[t.text] {
+				seen[t.text] = true
+				names = append(names, t.text)
+			}
+		}
+	}
+	return names, nil
+}
+
+// CheckCycles reports the first chain of user functions that ends up
+// calling itself, such as "f -> g -> f".
+func CheckCycles(defs []FunctionDef) error {
+	calls := make(map[string][]string, len(defs))
+	for _, def := range defs {
+		names, err := CalledFunctions(def.Body)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidFunction, def.Name, err)
+		}
+		calls[def.Name] = names
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(defs))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%w: %s -> %s", ErrRecursiveFunction, strings.Join(path, " -> "), name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, callee := range calls[name] {
+			if _, ok := calls[callee]; !ok {
+				continue
+			}
+			if err := visit(callee); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, def := range defs {
+		if err := visit(def.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userFunction is a user-defined function prepared for evaluation.
+type userFunction struct {
+	def  FunctionDef
+	body []token
+}
+
+func (e *Evaluator) compileFunctions(defs []FunctionDef) (map[string]userFunction, error) {
+	if err := CheckCycles(defs); err != nil {
+		return nil, err
+	}
+
+	functions := make(map[string]userFunction, len(defs))
+	for _, def := range defs {
+		if err := def.validate(); err != nil {
+			return nil, err
+		}
+		body, err := e.compile(def.Body, Standard)
+		if err != nil {
+			return nil, err
+		}
+		functions[def.Name] = userFunction{def: def, body: body}
+	}
+	return functions, nil
+}
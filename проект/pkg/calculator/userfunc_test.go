@@ -0,0 +1,126 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFunction(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       FunctionDef
+		err        error
+	}{
+		{definition: "f(x, y) = x^2 + y", want: FunctionDef{Name: "f", Params: []string{"x", "y"}, Body: "x^2 + y"}},
+		{definition: "  area ( r ) =  3 * r^2 ", want: FunctionDef{Name: "area", Params: []string{"r"}, Body: "3 * r^2"}},
+		{definition: "answer() = 42", want: FunctionDef{Name: "answer", Body: "42"}},
+		{definition: "f(x) x + 1", err: ErrInvalidFunction},
+		{definition: "f(x, x) = x", err: ErrInvalidFunction},
+		{definition: "f(1) = 1", err: ErrInvalidFunction},
+		{definition: "f(x) = x; 2", err: ErrInvalidFunction},
+		{definition: "f(x) = [x + 1", err: ErrInvalidFunction},
+		{definition: "sum(x) = x", err: ErrReservedName},
+		{definition: "rand(x) = x", err: ErrReservedName},
+		{definition: "integrate(x) = x", err: ErrReservedName},
+	}
+	for _, tt := range tests {
+		got, err := ParseFunction(tt.definition)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%q: error = %v, want %v", tt.definition, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.definition, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %+v, want %+v", tt.definition, got, tt.want)
+		}
+		if again, err := ParseFunction(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+			t.Errorf("%q does not parse back: %+v, %v", got.String(), again, err)
+		}
+	}
+}
+
+func TestCalledFunctions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"1 + 2", nil},
+		{"f(1) + g(f(2))", []string{"f", "g"}},
+		{"a = sqrt(4); h(a) + sqrt(a)", []string{"sqrt", "h"}},
+	}
+	for _, tt := range tests {
+		got, err := CalledFunctions(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CalledFunctions(%s) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckCycles(t *testing.T) {
+	parse := func(definitions ...string) []FunctionDef {
+		var defs []FunctionDef
+		for _, d := range definitions {
+			def, err := ParseFunction(d)
+			if err != nil {
+				t.Fatalf("%q: %v", d, err)
+			}
+			defs = append(defs, def)
+		}
+		return defs
+	}
+
+	tests := []struct {
+		name string
+		defs []FunctionDef
+		err  error
+	}{
+		{"independent", parse("f(x) = x + 1", "g(x) = 2 * x"), nil},
+		{"chain", parse("f(x) = g(x) + 1", "g(x) = h(x)", "h(x) = x"), nil},
+		{"self", parse("f(x) = f(x - 1)"), ErrRecursiveFunction},
+		{"mutual", parse("f(x) = g(x)", "g(x) = h(x)", "h(x) = f(x)"), ErrRecursiveFunction},
+	}
+	for _, tt := range tests {
+		if err := CheckCycles(tt.defs); !errors.Is(err, tt.err) {
+			t.Errorf("%s: CheckCycles = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestUserFunctions(t *testing.T) {
+	f, _ := ParseFunction("f(x, y) = x^2 + y")
+	g, _ := ParseFunction("g(x) = f(x, 1) * 2")
+	loop, _ := ParseFunction("loop(x) = loop(x)")
+	with := Options{Functions: []FunctionDef{f, g}}
+
+	runEvalTests(t, []evalTest{
+		{expr: "f(3, 1)", opts: with, want: "10"},
+		{expr: "g(2)", opts: with, want: "10"},
+		{expr: "x = 5; f(2, 0)", opts: with, want: "4"},
+		{expr: "f(1)", opts: with, err: ErrArgumentCount},
+		{expr: "h(1)", opts: with, err: ErrUnknownFunction},
+		{expr: "loop(1)", opts: Options{Functions: []FunctionDef{loop}}, err: ErrRecursiveFunction},
+	})
+
+	// A user function sees its parameters only, not the caller's bindings.
+	y, _ := ParseFunction("y(x) = x + z")
+	_, err := NewEvaluator().EvaluateWithOptions(context.Background(), "z = 1; y(1)", Options{Functions: []FunctionDef{y}})
+	if !errors.Is(err, ErrUnknownIdentifier) {
+		t.Errorf("caller binding seen by a user function: error = %v, want ErrUnknownIdentifier", err)
+	}
+
+	res, err := NewEvaluator(WithDefinitions(f)).Evaluate(context.Background(), "f(2, 2)")
+	if err != nil || res.Value.String() != "6" {
+		t.Errorf("f(2, 2) with WithDefinitions = %v, %v; want 6", res, err)
+	}
+}
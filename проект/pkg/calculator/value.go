@@ -0,0 +1,191 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrTypeMismatch  = errors.New("operand type mismatch")
+	ErrShapeMismatch = errors.New("operand shape mismatch")
+	ErrEmptyList     = errors.New("empty list")
+)
+
+type Kind string
+
+const (
+	KindNumber Kind = "number"
+	KindList   Kind = "list"
+	KindMatrix Kind = "matrix"
+)
+
+// Value is a result of evaluating an expression or any of its parts.
+type Value interface {
+	Kind() Kind
+	String() string
+}
+
+type Number float64
+
+func (n Number) Kind() Kind { return KindNumber }
+
+func (n Number) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+type List []float64
+
+func (l List) Kind() Kind { return KindList }
+
+func (l List) String() string {
+	parts := make([]string, len(l))
+	for i, v := range l {
+		parts[i] = Number(v).String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// newList builds the value of a list literal: numbers make a list, lists
+// of equal length make a matrix.
+func newList(items []Value) (Value, error) {
+	if len(items) == 0 {
+		return List{}, nil
+	}
+	for i, item := range items {
+		items[i] = plainList(item)
+	}
+	if _, ok := items[0].(List); ok {
+		m := make(Matrix, len(items))
+		for i, item := range items {
+			row, ok := item.(List)
+			if !ok {
+				return nil, ErrTypeMismatch
+			}
+			if len(row) == 0 || len(row) != len(items[0].(List)) {
+				return nil, ErrShapeMismatch
+			}
+			m[i] = row
+		}
+		return m, nil
+	}
+
+	list := make(List, len(items))
+	for i, item := range items {
+		num, ok := item.(Number)
+		if !ok {
+			return nil, ErrTypeMismatch
+		}
+		list[i] = float64(num)
+	}
+	return list, nil
+}
+
+// applyOperator applies a binary operator to two values. Lists are combined
+// element-wise, a number is broadcast over every element of a list.
+func applyOperator(op string, a, b Value) (Value, error) {
+	a, b = plainList(a), plainList(b)
+	if op == plusMinus {
+		return newUncertain(a, b)
+	}
+	if isTemporal(a) || isTemporal(b) {
+		return temporalOperator(op, a, b)
+	}
+	if _, ok := a.(Interval); ok {
+		return intervalOperator(op, a, b)
+	}
+	if _, ok := b.(Interval); ok {
+		return intervalOperator(op, a, b)
+	}
+	if _, ok := a.(Uncertain); ok {
+		return uncertainOperator(op, a, b)
+	}
+	if _, ok := b.(Uncertain); ok {
+		return uncertainOperator(op, a, b)
+	}
+	if _, ok := a.(Complex); ok {
+		return complexOperator(op, a, b)
+	}
+	if _, ok := b.(Complex); ok {
+		return complexOperator(op, a, b)
+	}
+	if _, ok := a.(Integer); ok {
+		return integerOperator(op, a, b)
+	}
+	if _, ok := b.(Integer); ok {
+		return integerOperator(op, a, b)
+	}
+	if _, ok := a.(Matrix); ok {
+		return matrixOperator(op, a, b)
+	}
+	if _, ok := b.(Matrix); ok {
+		return matrixOperator(op, a, b)
+	}
+
+	switch a := a.(type) {
+	case Number:
+		switch b := b.(type) {
+		case Number:
+			if exactArithmetic(op, a, b) {
+				return integerOperator(op, a, b)
+			}
+			res, err := arithmetic(op, float64(a), float64(b))
+			return Number(res), err
+		case List:
+			return elementwise(op, len(b), func(int) float64 { return float64(a) }, func(i int) float64 { return b[i] })
+		}
+	case List:
+		switch b := b.(type) {
+		case Number:
+			return elementwise(op, len(a), func(i int) float64 { return a[i] }, func(int) float64 { return float64(b) })
+		case List:
+			if len(a) != len(b) {
+				return nil, ErrShapeMismatch
+			}
+			return elementwise(op, len(a), func(i int) float64 { return a[i] }, func(i int) float64 { return b[i] })
+		}
+	}
+	return nil, ErrTypeMismatch
+}
+
+func elementwise(op string, n int, left, right func(int) float64) (Value, error) {
+	res := make(List, n)
+	for i := range res {
+		v, err := arithmetic(op, left(i), right(i))
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func negate(v Value) (Value, error) {
+	return applyOperator("*", Number(-1), v)
+}
+
+func arithmetic(op string, a, b float64) (float64, error) {
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return a / b, nil
+	case "^":
+		res := math.Pow(a, b)
+		if math.IsNaN(res) && !math.IsNaN(a) && !math.IsNaN(b) {
+			return 0, ErrDomain
+		}
+		return res, nil
+	default:
+		return 0, fmt.Errorf("unknown operator: %s", op)
+	}
+}
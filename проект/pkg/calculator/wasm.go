@@ -0,0 +1,252 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+var (
+	ErrInvalidModule  = errors.New("invalid wasm module")
+	ErrFunctionExists = errors.New("function is already defined")
+	ErrModuleLimit    = errors.New("wasm call exceeded its limits")
+)
+
+// WasmLimits bound the resources of a single call into a module.
+type WasmLimits struct {
+	// MemoryPages caps the linear memory, in 64 KiB pages.
+	MemoryPages uint32
+	// Instructions caps the instructions one call executes, zero for no
+	// cap. Modules are instrumented to count them as they run, so a loop
+	// is stopped long before the Timeout.
+	Instructions uint64
+	// Timeout bounds the execution of one call.
+	Timeout time.Duration
+}
+
+var DefaultWasmLimits = WasmLimits{
+	MemoryPages:  16,
+	Instructions: 10_000_000,
+	Timeout:      100 * time.Millisecond,
+}
+
+// wasmModule is a compiled module. Every call runs in a fresh instance, so
+// calls share no state and may run concurrently.
+type wasmModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	exports  []string
+	limits   WasmLimits
+}
+
+// LoadModule compiles a WebAssembly module and registers its numeric
+// exports as functions callable from expressions. Exports qualify when they
+// take only numbers and return exactly one. Modules may not import anything
+// from the host. Loading a module again under the same name replaces it.
+func (e *Evaluator) LoadModule(ctx context.Context, name string, binary []byte, limits WasmLimits) ([]string, error) {
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true))
+
+	module, functions, err := compileModule(ctx, runtime, binary, limits)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous := e.modules[name]
+	for export := range functions {
+		if _, ok := e.functions[export]; ok && !previous.hasExport(export) {
+			runtime.Close(ctx)
+			return nil, fmt.Errorf("%w: %s", ErrFunctionExists, export)
+		}
+	}
+
+	if previous != nil {
+		for _, export := range previous.exports {
+			delete(e.functions, export)
+		}
+		previous.runtime.Close(ctx)
+	}
+	for export, fn := range functions {
+		e.functions[export] = fn
+	}
+	e.modules[name] = module
+
+	return module.exports, nil
+}
+
+// UnloadModule removes a module loaded under name and the functions it
+// registered, and reports whether there was one.
+func (e *Evaluator) UnloadModule(ctx context.Context, name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	module := e.modules[name]
+	if module == nil {
+		return false
+	}
+	for _, export := range module.exports {
+		delete(e.functions, export)
+	}
+	delete(e.modules, name)
+	module.runtime.Close(ctx)
+	return true
+}
+
+func compileModule(
+	ctx context.Context,
+	runtime wazero.Runtime,
+	binary []byte,
+	limits WasmLimits,
+) (*wasmModule, map[string]Function, error) {
+	compiled, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidModule, err)
+	}
+	if len(compiled.ImportedFunctions()) > 0 || len(compiled.ImportedMemories()) > 0 {
+		return nil, nil, fmt.Errorf("%w: modules must not import from the host", ErrInvalidModule)
+	}
+	if limits.Instructions > 0 {
+		metered, err := meter(binary, limits.Instructions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidModule, err)
+		}
+		compiled.Close(ctx)
+		if compiled, err = runtime.CompileModule(ctx, metered); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidModule, err)
+		}
+	}
+
+	module := &wasmModule{
+		runtime:  runtime,
+		compiled: compiled,
+		limits:   limits,
+	}
+	functions := make(map[string]Function)
+	for export, def := range compiled.ExportedFunctions() {
+		if !isNumericSignature(def) {
+			continue
+		}
+		if !isIdentifier(export) {
+			continue
+		}
+		functions[export] = module.function(export, def)
+		module.exports = append(module.exports, export)
+	}
+	if len(functions) == 0 {
+		return nil, nil, fmt.Errorf("%w: no numeric exports", ErrInvalidModule)
+	}
+	sort.Strings(module.exports)
+
+	return module, functions, nil
+}
+
+func (m *wasmModule) function(export string, def api.FunctionDefinition) Function {
+	params := def.ParamTypes()
+	result := def.ResultTypes()[0]
+
+	return Function{
+		MinArgs: len(params),
+		MaxArgs: len(params),
+		Call: func(args []Value) (Value, error) {
+			stack := make([]uint64, len(args))
+			for i, arg := range args {
+				num, ok := arg.(Number)
+				if !ok {
+					return nil, ErrTypeMismatch
+				}
+				stack[i] = encodeWasm(params[i], float64(num))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), m.limits.Timeout)
+			defer cancel()
+
+			instance, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().
+				WithName("").
+				WithStartFunctions())
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrModuleLimit, export, err)
+			}
+			defer instance.Close(context.Background())
+
+			res, err := instance.ExportedFunction(export).Call(ctx, stack...)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("%w: %s: timed out", ErrModuleLimit, export)
+				}
+				if fuel := instance.ExportedGlobal(fuelExport); fuel != nil && int64(fuel.Get()) < 0 {
+					return nil, fmt.Errorf("%w: %s: more than %d instructions", ErrModuleLimit, export, m.limits.Instructions)
+				}
+				return nil, fmt.Errorf("%s: %v", export, err)
+			}
+			return Number(decodeWasm(result, res[0])), nil
+		},
+	}
+}
+
+func (m *wasmModule) hasExport(name string) bool {
+	if m == nil {
+		return false
+	}
+	for _, export := range m.exports {
+		if export == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumericSignature(def api.FunctionDefinition) bool {
+	if len(def.ResultTypes()) != 1 {
+		return false
+	}
+	for _, t := range def.ParamTypes() {
+		if !isNumericType(t) {
+			return false
+		}
+	}
+	return isNumericType(def.ResultTypes()[0])
+}
+
+func isNumericType(t api.ValueType) bool {
+	switch t {
+	case api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF32, api.ValueTypeF64:
+		return true
+	}
+	return false
+}
+
+func encodeWasm(t api.ValueType, v float64) uint64 {
+	switch t {
+	case api.ValueTypeI32:
+		return api.EncodeI32(int32(v))
+	case api.ValueTypeI64:
+		return api.EncodeI64(int64(v))
+	case api.ValueTypeF32:
+		return api.EncodeF32(float32(v))
+	default:
+		return api.EncodeF64(v)
+	}
+}
+
+func decodeWasm(t api.ValueType, v uint64) float64 {
+	switch t {
+	case api.ValueTypeI32:
+		return float64(api.DecodeI32(v))
+	case api.ValueTypeI64:
+		return float64(int64(v))
+	case api.ValueTypeF32:
+		return float64(api.DecodeF32(v))
+	default:
+		return api.DecodeF64(v)
+	}
+}
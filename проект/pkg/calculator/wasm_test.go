@@ -0,0 +1,258 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Value types of the test modules.
+const (
+	wasmI32 = 0x7F
+	wasmF64 = 0x7C
+)
+
+// wasmFunc is a function of a test module: its export name, type, extra
+// locals and body without the final end.
+type wasmFunc struct {
+	name            string
+	params, results []byte
+	locals          []byte
+	code            []byte
+}
+
+// assemble builds a module exporting the functions. It has a global of its
+// own, so instrumentation has to extend the existing sections.
+func assemble(funcs ...wasmFunc) []byte {
+	section := func(id byte, payload []byte) []byte {
+		return append(appendU32([]byte{id}, uint32(len(payload))), payload...)
+	}
+
+	types := appendU32(nil, uint32(len(funcs)))
+	functions := appendU32(nil, uint32(len(funcs)))
+	exports := appendU32(nil, uint32(len(funcs)))
+	code := appendU32(nil, uint32(len(funcs)))
+	for i, f := range funcs {
+		types = append(types, 0x60)
+		types = append(appendU32(types, uint32(len(f.params))), f.params...)
+		types = append(appendU32(types, uint32(len(f.results))), f.results...)
+		functions = appendU32(functions, uint32(i))
+		exports = append(appendU32(exports, uint32(len(f.name))), f.name...)
+		exports = appendU32(append(exports, 0x00), uint32(i))
+
+		body := appendU32(nil, uint32(len(f.locals)))
+		for _, t := range f.locals {
+			body = append(body, 1, t)
+		}
+		body = append(append(body, f.code...), 0x0B)
+		code = append(appendU32(code, uint32(len(body))), body...)
+	}
+
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = append(module, section(1, types)...)
+	module = append(module, section(3, functions)...)
+	module = append(module, section(6, []byte{1, wasmI32, 0x01, 0x41, 0x00, 0x0B})...)
+	module = append(module, section(7, exports)...)
+	return append(module, section(10, code)...)
+}
+
+var (
+	// add(a, b) = a + b
+	wasmAdd = wasmFunc{
+		name: "add", params: []byte{wasmF64, wasmF64}, results: []byte{wasmF64},
+		code: []byte{0x20, 0, 0x20, 1, 0xA0},
+	}
+	// count(n) counts up to n in a loop and returns n.
+	wasmCount = wasmFunc{
+		name: "count", params: []byte{wasmI32}, results: []byte{wasmI32}, locals: []byte{wasmI32},
+		code: []byte{
+			0x03, 0x40, // loop
+			0x20, 1, 0x41, 1, 0x6A, 0x22, 1, // i = i + 1
+			0x20, 0, 0x48, 0x0D, 0, // br_if (i < n)
+			0x0B,
+			0x20, 1,
+		},
+	}
+	// spin() never returns.
+	wasmSpin = wasmFunc{
+		name: "spin", results: []byte{wasmI32},
+		code: []byte{0x03, 0x40, 0x0C, 0, 0x0B, 0x41, 0},
+	}
+	// pick(c) branches on c and calls add.
+	wasmPick = wasmFunc{
+		name: "pick", params: []byte{wasmF64}, results: []byte{wasmF64},
+		code: []byte{
+			0x20, 0, 0x44, 0, 0, 0, 0, 0, 0, 0, 0, 0x64, // c > 0.0
+			0x04, wasmF64, // if (result f64)
+			0x20, 0, 0x20, 0, 0x10, 0, // add(c, c)
+			0x05,
+			0x44, 0, 0, 0, 0, 0, 0, 0xF0, 0xBF, // -1.0
+			0x0B,
+		},
+	}
+)
+
+func loadTestModule(t *testing.T, limits WasmLimits, funcs ...wasmFunc) *Evaluator {
+	t.Helper()
+	e := NewEvaluator()
+	if _, err := e.LoadModule(context.Background(), "test", assemble(funcs...), limits); err != nil {
+		t.Fatalf("LoadModule: %v", err)
+	}
+	return e
+}
+
+func TestWasmFunctions(t *testing.T) {
+	e := loadTestModule(t, DefaultWasmLimits, wasmAdd, wasmCount, wasmPick)
+
+	tests := []struct {
+		expr string
+		want string
+		err  error
+	}{
+		{expr: "add(1.5, 2)", want: "3.5"},
+		{expr: "count(1000)", want: "1000"},
+		{expr: "pick(2)", want: "4"},
+		{expr: "pick(-2)", want: "-1"},
+		{expr: "add(1)", err: ErrArgumentCount},
+		{expr: "add([1], 2)", err: ErrTypeMismatch},
+	}
+	for _, tt := range tests {
+		res, err := e.Evaluate(context.Background(), tt.expr)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: error = %v, want %v", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := res.Value.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWasmInstructionLimit(t *testing.T) {
+	limits := WasmLimits{MemoryPages: 1, Instructions: 10_000, Timeout: time.Minute}
+	e := loadTestModule(t, limits, wasmCount, wasmSpin)
+
+	// Every iteration of count executes 7 instructions.
+	tests := []struct {
+		expr string
+		err  error
+	}{
+		{"count(1000)", nil},
+		{"count(2000)", ErrModuleLimit},
+		{"spin()", ErrModuleLimit},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		_, err := e.Evaluate(context.Background(), tt.expr)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.expr, err, tt.err)
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Errorf("%s ran for %v, the instruction limit did not stop it", tt.expr, time.Since(start))
+		}
+	}
+
+	// Every call gets the whole budget again.
+	for i := 0; i < 3; i++ {
+		if _, err := e.Evaluate(context.Background(), "count(1000)"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+}
+
+func TestWasmTimeout(t *testing.T) {
+	e := loadTestModule(t, WasmLimits{MemoryPages: 1, Timeout: 10 * time.Millisecond}, wasmSpin)
+	if _, err := e.Evaluate(context.Background(), "spin()"); !errors.Is(err, ErrModuleLimit) {
+		t.Errorf("spin() without an instruction limit = %v, want ErrModuleLimit", err)
+	}
+}
+
+func TestLoadModule(t *testing.T) {
+	e := NewEvaluator()
+	ctx := context.Background()
+
+	exports, err := e.LoadModule(ctx, "test", assemble(wasmAdd, wasmCount), DefaultWasmLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exports) != 2 || exports[0] != "add" || exports[1] != "count" {
+		t.Errorf("exports = %v, want [add count]", exports)
+	}
+
+	// Reloading replaces the module's functions.
+	if _, err := e.LoadModule(ctx, "test", assemble(wasmAdd), DefaultWasmLimits); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Evaluate(ctx, "count(1)"); !errors.Is(err, ErrUnknownFunction) {
+		t.Errorf("count after reload = %v, want ErrUnknownFunction", err)
+	}
+
+	tests := []struct {
+		name   string
+		binary []byte
+		err    error
+	}{
+		{"garbage", []byte("not wasm"), ErrInvalidModule},
+		{"built-in name", assemble(wasmFunc{name: "sum", params: []byte{wasmF64}, results: []byte{wasmF64}, code: []byte{0x20, 0}}), ErrFunctionExists},
+		{"another module's export", assemble(wasmAdd), ErrFunctionExists},
+		{"no numeric exports", assemble(wasmFunc{name: "nothing"}), ErrInvalidModule},
+	}
+	for _, tt := range tests {
+		if _, err := e.LoadModule(ctx, "other", tt.binary, DefaultWasmLimits); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestUnloadModule(t *testing.T) {
+	e := loadTestModule(t, DefaultWasmLimits, wasmAdd)
+	ctx := context.Background()
+
+	if !e.UnloadModule(ctx, "test") {
+		t.Fatal("UnloadModule of a loaded module = false")
+	}
+	if _, err := e.Evaluate(ctx, "add(1, 2)"); !errors.Is(err, ErrUnknownFunction) {
+		t.Errorf("add after unloading = %v, want ErrUnknownFunction", err)
+	}
+	if e.UnloadModule(ctx, "test") {
+		t.Error("UnloadModule of an unloaded module = true")
+	}
+
+	// The exports are free for another module again.
+	if _, err := e.LoadModule(ctx, "other", assemble(wasmAdd), DefaultWasmLimits); err != nil {
+		t.Errorf("LoadModule after unloading: %v", err)
+	}
+}
+
+func TestMeterLEB(t *testing.T) {
+	signed := []struct {
+		v    int64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{63, []byte{0x3F}},
+		{64, []byte{0xC0, 0x00}},
+		{10_000, []byte{0x90, 0xCE, 0x00}},
+		{-1, []byte{0x7F}},
+		{-64, []byte{0x40}},
+		{-65, []byte{0xBF, 0x7F}},
+	}
+	for _, tt := range signed {
+		if got := appendS64(nil, tt.v); string(got) != string(tt.want) {
+			t.Errorf("appendS64(%d) = % x, want % x", tt.v, got, tt.want)
+		}
+	}
+	for _, v := range []uint32{0, 127, 128, 1 << 20, 1<<32 - 1} {
+		r := &wasmReader{b: appendU32(nil, v)}
+		if got, err := r.u32(); err != nil || got != v || !r.done() {
+			t.Errorf("appendU32(%d) reads back as %d, %v", v, got, err)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// fuelExport is the name an instrumented module exports its instruction
+// counter under. It is not an identifier, so it never clashes with a
+// function callable from expressions.
+const fuelExport = "calculator.fuel"
+
+var errMalformedModule = errors.New("malformed module")
+
+// Section ids of the binary format, and the order sections must come in.
+const (
+	sectionImport = 2
+	sectionGlobal = 6
+	sectionExport = 7
+	sectionCode   = 10
+)
+
+var sectionOrder = map[byte]int{
+	1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 12: 10, 10: 11, 11: 12,
+}
+
+type wasmSection struct {
+	id      byte
+	payload []byte
+}
+
+// meter instruments a module so each instance executes at most limit
+// instructions. The module gets an exported global holding the
+// instructions left. Every run of instructions up to the next control
+// instruction first takes its length off the global and traps when it
+// drops below zero; runs end at block, loop and branch instructions, so
+// every loop iteration is charged.
+func meter(binary []byte, limit uint64) ([]byte, error) {
+	if len(binary) < 8 || string(binary[:4]) != "\x00asm" {
+		return nil, errMalformedModule
+	}
+	r := &wasmReader{b: binary, pos: 8}
+	var sections []wasmSection
+	for !r.done() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		payload, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, wasmSection{id: id, payload: payload})
+	}
+
+	if imports := findSection(sections, sectionImport); imports != nil && len(imports.payload) > 0 && imports.payload[0] != 0 {
+		return nil, fmt.Errorf("%w: modules must not import from the host", ErrInvalidModule)
+	}
+
+	if limit > math.MaxInt64 {
+		limit = math.MaxInt64
+	}
+	global := []byte{0x7E, 0x01, 0x42} // mutable i64 = limit
+	global = append(appendS64(global, int64(limit)), 0x0B)
+	globals, fuel, err := appendEntry(findSection(sections, sectionGlobal), global)
+	if err != nil {
+		return nil, err
+	}
+	sections = putSection(sections, sectionGlobal, globals)
+
+	export := appendU32(nil, uint32(len(fuelExport)))
+	export = append(append(export, fuelExport...), 0x03)
+	exports, _, err := appendEntry(findSection(sections, sectionExport), appendU32(export, fuel))
+	if err != nil {
+		return nil, err
+	}
+	sections = putSection(sections, sectionExport, exports)
+
+	if code := findSection(sections, sectionCode); code != nil {
+		if code.payload, err = meterCode(code.payload, fuel); err != nil {
+			return nil, err
+		}
+	}
+
+	out := append([]byte(nil), binary[:8]...)
+	for _, s := range sections {
+		out = append(out, s.id)
+		out = appendU32(out, uint32(len(s.payload)))
+		out = append(out, s.payload...)
+	}
+	return out, nil
+}
+
+func findSection(sections []wasmSection, id byte) *wasmSection {
+	for i := range sections {
+		if sections[i].id == id {
+			return &sections[i]
+		}
+	}
+	return nil
+}
+
+// putSection replaces the section with the payload's id or inserts it
+// where the binary format wants it.
+func putSection(sections []wasmSection, id byte, payload []byte) []wasmSection {
+	if s := findSection(sections, id); s != nil {
+		s.payload = payload
+		return sections
+	}
+	at := len(sections)
+	for i, s := range sections {
+		if order, ok := sectionOrder[s.id]; ok && order > sectionOrder[id] {
+			at = i
+			break
+		}
+	}
+	sections = append(sections[:at], append([]wasmSection{{id: id, payload: payload}}, sections[at:]...)...)
+	return sections
+}
+
+// appendEntry adds an entry to a vector section, which may be missing, and
+// returns the new payload and the index of the entry.
+func appendEntry(s *wasmSection, entry []byte) ([]byte, uint32, error) {
+	if s == nil {
+		return append([]byte{1}, entry...), 0, nil
+	}
+	r := &wasmReader{b: s.payload}
+	n, err := r.u32()
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := appendU32(nil, n+1)
+	payload = append(payload, s.payload[r.pos:]...)
+	return append(payload, entry...), n, nil
+}
+
+// meterCode instruments every function body of the code section.
+func meterCode(payload []byte, fuel uint32) ([]byte, error) {
+	r := &wasmReader{b: payload}
+	n, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	out := appendU32(nil, n)
+	for i := uint32(0); i < n; i++ {
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		body, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = meterBody(body, fuel); err != nil {
+			return nil, err
+		}
+		out = appendU32(out, uint32(len(body)))
+		out = append(out, body...)
+	}
+	return out, nil
+}
+
+func meterBody(body []byte, fuel uint32) ([]byte, error) {
+	r := &wasmReader{b: body}
+	locals, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < locals; i++ {
+		if _, err := r.u32(); err != nil {
+			return nil, err
+		}
+		if _, err := r.byte(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := append([]byte(nil), body[:r.pos]...)
+	start, count := r.pos, 0
+	for !r.done() {
+		op, err := r.instruction()
+		if err != nil {
+			return nil, err
+		}
+		count++
+		if endsRun(op) {
+			out = appendCharge(out, fuel, count)
+			out = append(out, body[start:r.pos]...)
+			start, count = r.pos, 0
+		}
+	}
+	if count > 0 {
+		return nil, errMalformedModule
+	}
+	return out, nil
+}
+
+// endsRun reports whether control may leave or enter the code after op
+// other than by falling through.
+func endsRun(op byte) bool {
+	switch op {
+	case 0x00, 0x02, 0x03, 0x04, 0x05, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F:
+		return true
+	}
+	return false
+}
+
+// appendCharge takes n instructions off the fuel global and traps when it
+// runs out. It leaves the operand stack as it found it.
+func appendCharge(out []byte, fuel uint32, n int) []byte {
+	out = appendU32(append(out, 0x23), fuel) // global.get
+	out = appendS64(append(out, 0x42), int64(n))
+	out = append(out, 0x7D)                  // i64.sub
+	out = appendU32(append(out, 0x24), fuel) // global.set
+	out = appendU32(append(out, 0x23), fuel)
+	out = append(out, 0x42, 0x00, 0x53) // i64.const 0, i64.lt_s
+	return append(out, 0x04, 0x40, 0x00, 0x0B)
+}
+
+// wasmReader decodes the binary format.
+type wasmReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *wasmReader) done() bool { return r.pos >= len(r.b) }
+
+func (r *wasmReader) byte() (byte, error) {
+	if r.done() {
+		return 0, errMalformedModule
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *wasmReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.b) {
+		return nil, errMalformedModule
+	}
+	r.pos += n
+	return r.b[r.pos-n : r.pos], nil
+}
+
+func (r *wasmReader) u32() (uint32, error) {
+	var v uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint32(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errMalformedModule
+}
+
+// skip passes over n LEB128 numbers of any width.
+func (r *wasmReader) skip(n int) error {
+	for i := 0; i < n; i++ {
+		for {
+			b, err := r.byte()
+			if err != nil {
+				return err
+			}
+			if b&0x80 == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// instruction reads one instruction and returns its opcode.
+func (r *wasmReader) instruction() (byte, error) {
+	op, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case op == 0x02 || op == 0x03 || op == 0x04:
+		err = r.blockType()
+	case op == 0x0C || op == 0x0D || op == 0x10 || op >= 0x20 && op <= 0x26 || op == 0x3F || op == 0x40 ||
+		op == 0x41 || op == 0x42 || op == 0xD2:
+		err = r.skip(1)
+	case op == 0x0E:
+		var n uint32
+		if n, err = r.u32(); err == nil {
+			err = r.skip(int(n) + 1)
+		}
+	case op == 0x11 || op >= 0x28 && op <= 0x3E:
+		err = r.skip(2)
+	case op == 0x1C:
+		var n uint32
+		if n, err = r.u32(); err == nil {
+			_, err = r.bytes(int(n))
+		}
+	case op == 0x43:
+		_, err = r.bytes(4)
+	case op == 0x44:
+		_, err = r.bytes(8)
+	case op == 0xD0:
+		_, err = r.byte()
+	case op == 0xFC:
+		err = r.miscInstruction()
+	case op == 0xFD:
+		err = r.vectorInstruction()
+	case op <= 0x01 || op == 0x05 || op == 0x0B || op == 0x0F || op == 0x1A || op == 0x1B ||
+		op >= 0x45 && op <= 0xC4 || op == 0xD1:
+	default:
+		err = fmt.Errorf("%w: unsupported instruction 0x%02x", errMalformedModule, op)
+	}
+	return op, err
+}
+
+func (r *wasmReader) blockType() error {
+	if r.done() {
+		return errMalformedModule
+	}
+	switch r.b[r.pos] {
+	case 0x40, 0x7F, 0x7E, 0x7D, 0x7C, 0x7B, 0x70, 0x6F:
+		r.pos++
+		return nil
+	}
+	return r.skip(1)
+}
+
+// miscInstruction reads the rest of a 0xFC instruction: saturating
+// truncations, bulk memory and table operations.
+func (r *wasmReader) miscInstruction() error {
+	op, err := r.u32()
+	if err != nil {
+		return err
+	}
+	switch {
+	case op <= 7:
+		return nil
+	case op == 8 || op == 10 || op == 12 || op == 14:
+		return r.skip(2)
+	case op <= 17:
+		return r.skip(1)
+	}
+	return fmt.Errorf("%w: unsupported instruction 0xfc %d", errMalformedModule, op)
+}
+
+// vectorInstruction reads the rest of a 0xFD instruction.
+func (r *wasmReader) vectorInstruction() error {
+	op, err := r.u32()
+	if err != nil {
+		return err
+	}
+	switch {
+	case op <= 11 || op == 92 || op == 93:
+		return r.skip(2)
+	case op == 12 || op == 13:
+		_, err = r.bytes(16)
+	case op >= 21 && op <= 34:
+		_, err = r.byte()
+	case op >= 84 && op <= 91:
+		if err = r.skip(2); err == nil {
+			_, err = r.byte()
+		}
+	}
+	return err
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendS64(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
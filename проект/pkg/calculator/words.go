@@ -0,0 +1,579 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrUnknownWords    = errors.New("words not understood")
+	ErrUnknownLanguage = errors.New("unknown language")
+)
+
+// Language is a language numbers can be written out in.
+type Language string
+
+const (
+	LanguageEnglish Language = "en"
+	LanguageRussian Language = "ru"
+)
+
+// maxSpelledDigits bounds the integer part of numbers written in words,
+// the largest scale word being quintillion.
+const maxSpelledDigits = 21
+
+// maxSpelledDecimals is the number of decimal places SpellOut writes; it
+// matches the millionths, the smallest Russian fraction it names.
+const maxSpelledDecimals = 6
+
+// Translate turns an expression written out in words, such as "двести
+// плюс тридцать пять" or "two hundred times three", into the expression
+// grammar: "200 + 35", "200 * 3". Russian and English may be mixed. Digits,
+// operator signs and the names of functions and variables are kept as
+// they are. Words that are neither are listed in the error.
+func Translate(text string) (string, error) {
+	words := splitWords(text)
+	names := assignedNames(text, words)
+
+	var b strings.Builder
+	var unknown []string
+	last := 0
+	afterNumber := false
+	for i := 0; i < len(words); {
+		w := words[i]
+		replacement, n, number := translateAt(text, words, i, names)
+		// Two numbers in a row, as in "five six", would be read as one
+		// once the spaces are gone.
+		if n == 0 || number && afterNumber && strings.TrimSpace(text[last:w.start]) == "" {
+			unknown = append(unknown, text[w.start:w.end])
+			i++
+			continue
+		}
+		b.WriteString(text[last:w.start])
+		b.WriteString(replacement)
+		last = words[i+n-1].end
+		afterNumber = number
+		i += n
+	}
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownWords, strings.Join(unknown, ", "))
+	}
+	b.WriteString(text[last:])
+	return b.String(), nil
+}
+
+// word is a run of letters in the text, or a number written in digits,
+// which may be followed by a scale word as in "1.5 million".
+type word struct {
+	text       string
+	start, end int
+	numeral    bool
+}
+
+func splitWords(text string) []word {
+	var words []word
+	start := -1
+	numeral := false
+	flush := func(end int) {
+		if start >= 0 {
+			w := word{text: text[start:end], start: start, end: end, numeral: numeral}
+			if !numeral {
+				w.text = strings.ReplaceAll(strings.ToLower(w.text), "ё", "е")
+			}
+			words = append(words, w)
+			start = -1
+		}
+	}
+
+	for i, c := range text {
+		letter := unicode.IsLetter(c) || c == '_'
+		digit := isDigit(c) || c == '.' && numeral
+		switch {
+		case start >= 0 && !numeral && (letter || isDigit(c)):
+		case start >= 0 && numeral && digit:
+		case letter || isDigit(c):
+			flush(i)
+			start, numeral = i, !letter
+		case c == '-' && start >= 0 && !numeral:
+			// twenty-five
+			flush(i)
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return words
+}
+
+// assignedNames returns the variables a script assigns to, which are kept
+// as they are wherever they appear.
+func assignedNames(text string, words []word) map[string]bool {
+	names := make(map[string]bool)
+	for _, w := range words {
+		if strings.HasPrefix(strings.TrimSpace(text[w.end:]), "=") {
+			names[w.text] = true
+		}
+	}
+	return names
+}
+
+// adjacent reports whether words[i] and words[i+1] are separated by
+// nothing but spaces, so they can belong to one phrase.
+func adjacent(text string, words []word, i int) bool {
+	return i+1 < len(words) && strings.TrimSpace(strings.ReplaceAll(text[words[i].end:words[i+1].start], "-", "")) == ""
+}
+
+// translateAt translates the phrase starting at words[i] and returns it
+// with the number of words it spans, 0 when the word is not understood,
+// and whether the phrase is a number.
+func translateAt(text string, words []word, i int, names map[string]bool) (string, int, bool) {
+	if value, n := numberAt(text, words, i); n > 0 {
+		return value, n, true
+	}
+
+	for _, phrase := range operatorPhrases {
+		if matchPhrase(text, words, i, phrase.words) {
+			return phrase.symbol, len(phrase.words), false
+		}
+	}
+
+	w := words[i]
+	if w.numeral {
+		return w.text, 1, true
+	}
+	if isIdentifier(w.text) && keepsName(text, w, names) {
+		return text[w.start:w.end], 1, false
+	}
+	return "", 0, false
+}
+
+func matchPhrase(text string, words []word, i int, phrase []string) bool {
+	if i+len(phrase) > len(words) {
+		return false
+	}
+	for j, p := range phrase {
+		if words[i+j].text != p || j > 0 && !adjacent(text, words, i+j-1) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepsName reports whether a word is the name of a function or variable
+// rather than a word to translate.
+func keepsName(text string, w word, names map[string]bool) bool {
+	if len(w.text) == 1 || names[w.text] {
+		return true
+	}
+	if _, ok := seriesForms[w.text]; ok {
+		return true
+	}
+	if _, ok := builtinFunctions()[w.text]; ok {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(text[w.end:]), "(")
+}
+
+var operatorPhrases = []struct {
+	words  []string
+	symbol string
+}{
+	{[]string{"to", "the", "power", "of"}, "^"},
+	{[]string{"multiplied", "by"}, "*"},
+	{[]string{"divided", "by"}, "/"},
+	{[]string{"plus"}, "+"},
+	{[]string{"minus"}, "-"},
+	{[]string{"negative"}, "-"},
+	{[]string{"times"}, "*"},
+	{[]string{"over"}, "/"},
+	{[]string{"squared"}, "^2"},
+	{[]string{"cubed"}, "^3"},
+	{[]string{"умножить", "на"}, "*"},
+	{[]string{"умноженное", "на"}, "*"},
+	{[]string{"разделить", "на"}, "/"},
+	{[]string{"делить", "на"}, "/"},
+	{[]string{"деленное", "на"}, "/"},
+	{[]string{"в", "степени"}, "^"},
+	{[]string{"в", "квадрате"}, "^2"},
+	{[]string{"в", "кубе"}, "^3"},
+	{[]string{"плюс"}, "+"},
+	{[]string{"минус"}, "-"},
+}
+
+// numberWords are the words that add to a group of three digits.
+var numberWords = map[string]int64{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	"eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+	"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+	"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+
+	"ноль": 0, "нуль": 0, "один": 1, "одна": 1, "одно": 1, "два": 2, "две": 2,
+	"три": 3, "четыре": 4, "пять": 5, "шесть": 6, "семь": 7, "восемь": 8,
+	"девять": 9, "десять": 10, "одиннадцать": 11, "двенадцать": 12,
+	"тринадцать": 13, "четырнадцать": 14, "пятнадцать": 15, "шестнадцать": 16,
+	"семнадцать": 17, "восемнадцать": 18, "девятнадцать": 19,
+	"двадцать": 20, "тридцать": 30, "сорок": 40, "пятьдесят": 50,
+	"шестьдесят": 60, "семьдесят": 70, "восемьдесят": 80, "девяносто": 90,
+	"сто": 100, "двести": 200, "триста": 300, "четыреста": 400, "пятьсот": 500,
+	"шестьсот": 600, "семьсот": 700, "восемьсот": 800, "девятьсот": 900,
+}
+
+// scaleWords multiply the group before them by a power of ten.
+var scaleWords = map[string]int{
+	"thousand": 3, "million": 6, "billion": 9, "trillion": 12,
+	"quadrillion": 15, "quintillion": 18,
+
+	"тысяча": 3, "тысячи": 3, "тысяч": 3,
+	"миллион": 6, "миллиона": 6, "миллионов": 6,
+	"миллиард": 9, "миллиарда": 9, "миллиардов": 9,
+	"триллион": 12, "триллиона": 12, "триллионов": 12,
+	"квадриллион": 15, "квадриллиона": 15, "квадриллионов": 15,
+	"квинтиллион": 18, "квинтиллиона": 18, "квинтиллионов": 18,
+}
+
+// fractionWords name the decimal fraction after "целых", by the number of
+// decimal places: "пять десятых", "двадцать пять сотых".
+var fractionWords = map[string]int{
+	"десятая": 1, "десятых": 1, "сотая": 2, "сотых": 2,
+	"тысячная": 3, "тысячных": 3, "десятитысячная": 4, "десятитысячных": 4,
+	"стотысячная": 5, "стотысячных": 5, "миллионная": 6, "миллионных": 6,
+}
+
+// numberAt reads a number written in words starting at words[i], such as
+// "two hundred and five", "три целых пять десятых" or "1.5 million", and
+// returns it in digits with the number of words it spans.
+func numberAt(text string, words []word, i int) (string, int) {
+	value, decimals, n := integerAt(text, words, i)
+	if n == 0 {
+		return "", 0
+	}
+
+	// three point one four, три запятая один четыре
+	if j := i + n; j+1 < len(words) && adjacent(text, words, j-1) &&
+		(words[j].text == "point" || words[j].text == "запятая") {
+		var digits strings.Builder
+		k := j + 1
+		for ; k < len(words) && adjacent(text, words, k-1); k++ {
+			d, ok := numberWords[words[k].text]
+			if !ok || d > 9 {
+				break
+			}
+			digits.WriteString(strconv.FormatInt(d, 10))
+		}
+		if digits.Len() > 0 && decimals == 0 {
+			return value.FloatString(0) + "." + digits.String(), k - i
+		}
+	}
+
+	// три целых двадцать пять сотых
+	if j := i + n; j+1 < len(words) && adjacent(text, words, j-1) &&
+		(words[j].text == "целых" || words[j].text == "целая") {
+		fraction, _, m := integerAt(text, words, j+1)
+		if k := j + 1 + m; m > 0 && k < len(words) && adjacent(text, words, k-1) {
+			if places, ok := fractionWords[words[k].text]; ok && fraction.IsInt() && decimals == 0 {
+				digits := fraction.Num().String()
+				if len(digits) <= places {
+					digits = strings.Repeat("0", places-len(digits)) + digits
+					return value.FloatString(0) + "." + digits, k + 1 - i
+				}
+			}
+		}
+	}
+
+	return formatRat(value, decimals), n
+}
+
+// integerAt reads a whole number in words, or a numeral with scale words
+// after it, and returns it with the number of decimal places it needs.
+func integerAt(text string, words []word, i int) (*big.Rat, int, int) {
+	total := new(big.Rat)
+	var group int64
+	decimals := 0
+	lastScale := maxSpelledDigits
+	inGroup := false
+	j := i
+
+	if words[i].numeral {
+		r, ok := new(big.Rat).SetString(words[i].text)
+		if !ok || !adjacent(text, words, i) {
+			return nil, 0, 0
+		}
+		if _, ok := scaleWords[words[i+1].text]; !ok {
+			return nil, 0, 0
+		}
+		if dot := strings.IndexByte(words[i].text, '.'); dot >= 0 {
+			decimals = len(words[i].text) - dot - 1
+		}
+		total.Set(r)
+		j++
+	}
+
+	for ; j < len(words); j++ {
+		if j > i && !adjacent(text, words, j-1) {
+			break
+		}
+		w := words[j].text
+
+		if v, ok := numberWords[w]; ok && fitsGroup(group, v, inGroup) {
+			group += v
+			inGroup = true
+			continue
+		}
+		if w == "hundred" && inGroup && group < 10 {
+			group *= 100
+			continue
+		}
+		if w == "a" && j+1 < len(words) && !inGroup && total.Sign() == 0 &&
+			(words[j+1].text == "hundred" || scaleWords[words[j+1].text] > 0) {
+			group, inGroup = 1, true
+			continue
+		}
+		if w == "and" && (inGroup || total.Sign() != 0) && adjacent(text, words, j) {
+			if v, ok := numberWords[words[j+1].text]; ok && fitsGroup(group, v, inGroup) {
+				continue
+			}
+		}
+		if scale, ok := scaleWords[w]; ok && scale < lastScale && (inGroup || j == i+1 && words[i].numeral) {
+			if !inGroup {
+				// 1.5 million
+				total.Mul(total, pow10(scale))
+				decimals = max(decimals-scale, 0)
+			} else {
+				// тысяча on its own is one thousand
+				if group == 0 {
+					group = 1
+				}
+				total.Add(total, new(big.Rat).Mul(new(big.Rat).SetInt64(group), pow10(scale)))
+			}
+			group, inGroup, lastScale = 0, false, scale
+			continue
+		}
+		if scale, ok := scaleWords[w]; ok && j == i && strings.HasPrefix(w, "тысяч") {
+			total.Add(total, pow10(scale))
+			lastScale = scale
+			continue
+		}
+		break
+	}
+
+	if j == i {
+		return nil, 0, 0
+	}
+	total.Add(total, new(big.Rat).SetInt64(group))
+	return total, decimals, j - i
+}
+
+// fitsGroup reports whether v can follow the digits already in group, so
+// "twenty five" is 25 while "five five" are two numbers.
+func fitsGroup(group, v int64, inGroup bool) bool {
+	switch {
+	case !inGroup:
+		return true
+	case v >= 100:
+		return false
+	case v >= 20:
+		return group%100 == 0
+	default:
+		return group%100 == 0 || group%100 >= 20 && group%10 == 0 && v < 10
+	}
+}
+
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+func formatRat(r *big.Rat, decimals int) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	return strings.TrimRight(r.FloatString(decimals), "0")
+}
+
+// SpellOut writes a real or integer value out in words, as on invoices:
+// "two hundred thirty-five", "двести тридцать пять". Fractions are
+// rounded to millionths.
+func SpellOut(v Value, lang Language) (string, error) {
+	if lang != LanguageEnglish && lang != LanguageRussian {
+		return "", ErrUnknownLanguage
+	}
+
+	var digits, fraction string
+	negative := false
+	switch v := v.(type) {
+	case Number:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return "", ErrTypeMismatch
+		}
+		negative = v < 0
+		text := strconv.FormatFloat(math.Abs(float64(v)), 'f', maxSpelledDecimals, 64)
+		digits, fraction, _ = strings.Cut(text, ".")
+		fraction = strings.TrimRight(fraction, "0")
+	case Integer:
+		negative = v.Sign() < 0
+		digits = new(big.Int).Abs(v.Int).String()
+	default:
+		return "", ErrTypeMismatch
+	}
+	if len(digits) > maxSpelledDigits {
+		return "", ErrTooLarge
+	}
+
+	var words []string
+	if negative && strings.Trim(digits+fraction, "0") != "" {
+		words = append(words, map[Language]string{LanguageEnglish: "minus", LanguageRussian: "минус"}[lang])
+	}
+	if lang == LanguageEnglish {
+		words = append(words, spellEnglish(digits))
+		if fraction != "" {
+			words = append(words, "point")
+			for _, d := range fraction {
+				words = append(words, englishUnits[d-'0'])
+			}
+		}
+		return strings.Join(words, " "), nil
+	}
+
+	if fraction == "" {
+		words = append(words, spellRussian(digits, false))
+		return strings.Join(words, " "), nil
+	}
+	whole := spellRussian(digits, true)
+	part := spellRussian(fraction, true)
+	words = append(words,
+		whole, russianPlural(digits, "целая", "целых", "целых"),
+		part, russianPlural(fraction, russianFractions[len(fraction)]+"ая", russianFractions[len(fraction)]+"ых", russianFractions[len(fraction)]+"ых"))
+	return strings.Join(words, " "), nil
+}
+
+var (
+	englishUnits = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	englishScales = []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+)
+
+// spellEnglish writes a whole number given as decimal digits.
+func spellEnglish(digits string) string {
+	var words []string
+	groups := splitGroups(digits)
+	for i, g := range groups {
+		if g == 0 {
+			continue
+		}
+		if g >= 100 {
+			words = append(words, englishUnits[g/100], "hundred")
+		}
+		switch r := g % 100; {
+		case r >= 20 && r%10 != 0:
+			words = append(words, englishTens[r/10]+"-"+englishUnits[r%10])
+		case r >= 20:
+			words = append(words, englishTens[r/10])
+		case r > 0:
+			words = append(words, englishUnits[r])
+		}
+		if scale := englishScales[len(groups)-1-i]; scale != "" {
+			words = append(words, scale)
+		}
+	}
+	if len(words) == 0 {
+		return "zero"
+	}
+	return strings.Join(words, " ")
+}
+
+var (
+	russianUnits = []string{"ноль", "один", "два", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять",
+		"десять", "одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать", "шестнадцать",
+		"семнадцать", "восемнадцать", "девятнадцать"}
+	russianTens     = []string{"", "", "двадцать", "тридцать", "сорок", "пятьдесят", "шестьдесят", "семьдесят", "восемьдесят", "девяносто"}
+	russianHundreds = []string{"", "сто", "двести", "триста", "четыреста", "пятьсот", "шестьсот", "семьсот", "восемьсот", "девятьсот"}
+	// russianScales holds the singular, few and many forms of each scale.
+	russianScales = [][3]string{
+		{},
+		{"тысяча", "тысячи", "тысяч"},
+		{"миллион", "миллиона", "миллионов"},
+		{"миллиард", "миллиарда", "миллиардов"},
+		{"триллион", "триллиона", "триллионов"},
+		{"квадриллион", "квадриллиона", "квадриллионов"},
+		{"квинтиллион", "квинтиллиона", "квинтиллионов"},
+	}
+	russianFractions = []string{"", "десят", "сот", "тысячн", "десятитысячн", "стотысячн", "миллионн"}
+)
+
+// spellRussian writes a whole number given as decimal digits. Thousands
+// are feminine, as is the whole number when it counts feminine nouns such
+// as "целая" and "сотая".
+func spellRussian(digits string, feminine bool) string {
+	var words []string
+	groups := splitGroups(digits)
+	for i, g := range groups {
+		if g == 0 {
+			continue
+		}
+		scale := len(groups) - 1 - i
+		words = append(words, russianGroup(g, scale == 1 || scale == 0 && feminine)...)
+		if scale > 0 {
+			forms := russianScales[scale]
+			words = append(words, russianPlural(strconv.Itoa(g), forms[0], forms[1], forms[2]))
+		}
+	}
+	if len(words) == 0 {
+		return "ноль"
+	}
+	return strings.Join(words, " ")
+}
+
+func russianGroup(g int, feminine bool) []string {
+	var words []string
+	if g >= 100 {
+		words = append(words, russianHundreds[g/100])
+	}
+	r := g % 100
+	if r >= 20 {
+		words = append(words, russianTens[r/10])
+		r %= 10
+	}
+	switch {
+	case r == 1 && feminine:
+		words = append(words, "одна")
+	case r == 2 && feminine:
+		words = append(words, "две")
+	case r > 0:
+		words = append(words, russianUnits[r])
+	}
+	return words
+}
+
+// russianPlural picks the form of a noun counted by the number given as
+// decimal digits: 1 тысяча, 2 тысячи, 5 тысяч, 11 тысяч, 21 тысяча.
+func russianPlural(digits, one, few, many string) string {
+	n, _ := strconv.Atoi(digits[max(len(digits)-2, 0):])
+	switch {
+	case n%100 >= 11 && n%100 <= 14:
+		return many
+	case n%10 == 1:
+		return one
+	case n%10 >= 2 && n%10 <= 4:
+		return few
+	}
+	return many
+}
+
+// splitGroups splits decimal digits into groups of three, most
+// significant first.
+func splitGroups(digits string) []int {
+	digits = strings.Repeat("0", (3-len(digits)%3)%3) + digits
+	groups := make([]int, 0, len(digits)/3)
+	for i := 0; i < len(digits); i += 3 {
+		g, _ := strconv.Atoi(digits[i : i+3])
+		groups = append(groups, g)
+	}
+	return groups
+}
@@ -0,0 +1,106 @@
+package calculator
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		err  error
+	}{
+		{text: "двести плюс три", want: "200 + 3"},
+		{text: "сто двадцать пять разделить на пять", want: "125 / 5"},
+		{text: "две тысячи двадцать шесть", want: "2026"},
+		{text: "три в квадрате", want: "3 ^2"},
+		{text: "минус пять", want: "- 5"},
+		{text: "two plus two", want: "2 + 2"},
+		{text: "twenty one times three", want: "21 * 3"},
+		{text: "two hundred and five", want: "205"},
+		{text: "one million minus one", want: "1000000 - 1"},
+		{text: "one point five plus one", want: "1.5 + 1"},
+		{text: "2 plus three", want: "2 + 3"},
+		{text: "banana", err: ErrUnknownWords},
+		{text: "square root of sixteen", err: ErrUnknownWords},
+	}
+	for _, tt := range tests {
+		got, err := Translate(tt.text)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%q: error = %v, want %v", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Translate(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestSpellOut(t *testing.T) {
+	nines, _ := new(big.Int).SetString("999999999999999999999", 10)
+	tests := []struct {
+		value   Value
+		english string
+		russian string
+	}{
+		{Number(0), "zero", "ноль"},
+		{Number(21), "twenty-one", "двадцать один"},
+		{Number(-7), "minus seven", "минус семь"},
+		{Number(1001), "one thousand one", "одна тысяча один"},
+		{Number(2.5), "two point five", "две целых пять десятых"},
+		{Number(1234567), "one million two hundred thirty-four thousand five hundred sixty-seven",
+			"один миллион двести тридцать четыре тысячи пятьсот шестьдесят семь"},
+		{Integer{nines}, "nine hundred ninety-nine quintillion nine hundred ninety-nine quadrillion " +
+			"nine hundred ninety-nine trillion nine hundred ninety-nine billion nine hundred ninety-nine million " +
+			"nine hundred ninety-nine thousand nine hundred ninety-nine", ""},
+	}
+	for _, tt := range tests {
+		if got, err := SpellOut(tt.value, LanguageEnglish); err != nil || got != tt.english {
+			t.Errorf("SpellOut(%v, en) = %q, %v; want %q", tt.value, got, err, tt.english)
+		}
+		if tt.russian == "" {
+			continue
+		}
+		if got, err := SpellOut(tt.value, LanguageRussian); err != nil || got != tt.russian {
+			t.Errorf("SpellOut(%v, ru) = %q, %v; want %q", tt.value, got, err, tt.russian)
+		}
+	}
+
+	errorTests := []struct {
+		value Value
+		lang  Language
+		err   error
+	}{
+		{Number(1), "fr", ErrUnknownLanguage},
+		{Number(1e30), LanguageEnglish, ErrTooLarge},
+		{Integer{new(big.Int).Add(nines, big.NewInt(1))}, LanguageRussian, ErrTooLarge},
+		{List{1}, LanguageEnglish, ErrTypeMismatch},
+	}
+	for _, tt := range errorTests {
+		if _, err := SpellOut(tt.value, tt.lang); !errors.Is(err, tt.err) {
+			t.Errorf("SpellOut(%v, %s): error = %v, want %v", tt.value, tt.lang, err, tt.err)
+		}
+	}
+}
+
+// TestSpellOutRoundTrip checks that numbers written out in words translate
+// back to themselves.
+func TestSpellOutRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 7, 13, 40, 101, 999, 1001, 2026, 15000, 700011, 1234567} {
+		for _, lang := range []Language{LanguageEnglish, LanguageRussian} {
+			words, err := SpellOut(Number(n), lang)
+			if err != nil {
+				t.Errorf("SpellOut(%d, %s): %v", n, lang, err)
+				continue
+			}
+			if got, err := Translate(words); err != nil || got != strconv.Itoa(n) {
+				t.Errorf("Translate(%q) = %q, %v; want %d", words, got, err, n)
+			}
+		}
+	}
+}